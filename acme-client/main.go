@@ -1,13 +1,17 @@
 package main
 
 import (
+	"github.com/stbuehler/go-acme-client/command_apply"
 	"github.com/stbuehler/go-acme-client/command_authorize"
 	"github.com/stbuehler/go-acme-client/command_authorize_batch"
 	"github.com/stbuehler/go-acme-client/command_authorize_import"
 	"github.com/stbuehler/go-acme-client/command_certificate"
 	"github.com/stbuehler/go-acme-client/command_certificate_batch"
 	"github.com/stbuehler/go-acme-client/command_certificate_get"
+	"github.com/stbuehler/go-acme-client/command_export"
+	"github.com/stbuehler/go-acme-client/command_offline_submit"
 	"github.com/stbuehler/go-acme-client/command_register"
+	"github.com/stbuehler/go-acme-client/command_renew"
 	"github.com/stbuehler/go-acme-client/ui"
 	"os"
 )
@@ -24,6 +28,10 @@ func main() {
 		println("\tcertificate: show and edit certificates")
 		println("\tcertificate-batch: batch create certificates")
 		println("\tcertificate-get: create single certificate")
+		println("\trenew: renew expiring certificates")
+		println("\tapply: issue and renew certificates listed in a config file")
+		println("\texport: write stored certificates and keys to files")
+		println("\toffline-submit: send requests queued with -offline (on an online host)")
 		os.Exit(1)
 	} else {
 		switch os.Args[1] {
@@ -41,6 +49,14 @@ func main() {
 			command_certificate_get.Run(ui.CLI, os.Args[2:])
 		case "certificate-batch":
 			command_certificate_batch.Run(ui.CLI, os.Args[2:])
+		case "apply":
+			command_apply.Run(ui.CLI, os.Args[2:])
+		case "export":
+			command_export.Run(ui.CLI, os.Args[2:])
+		case "renew":
+			command_renew.Run(ui.CLI, os.Args[2:])
+		case "offline-submit":
+			command_offline_submit.Run(ui.CLI, os.Args[2:])
 		default:
 			println("Unknown subcommand: " + os.Args[1])
 			os.Exit(1)
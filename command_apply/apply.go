@@ -0,0 +1,254 @@
+package command_apply
+
+import (
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/command_base"
+	"github.com/stbuehler/go-acme-client/config"
+	"github.com/stbuehler/go-acme-client/model"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var apply_flags = flag.NewFlagSet("apply", flag.ExitOnError)
+
+var configFile string
+var dryRun bool
+var ignoreARI bool
+
+func init() {
+	apply_flags.StringVar(&configFile, "config", "acme-client.toml", "Config file listing the certificates")
+	apply_flags.BoolVar(&dryRun, "dry-run", false, "Only show what would be done")
+	apply_flags.BoolVar(&ignoreARI, "ignore-ari", false, "Ignore the renewal window suggested by the server (ACME Renewal Information), only use renew-days")
+	command_base.AddStorageFlags(apply_flags)
+	utils.AddLogFlags(apply_flags)
+}
+
+// normalized and sorted, to compare with the names in a certificate
+func namesKey(names []string) string {
+	normalized := make([]string, len(names))
+	for ndx, name := range names {
+		normalized[ndx] = types.NewIdentifier(name).Value
+	}
+	sort.Strings(normalized)
+	return strings.Join(normalized, " ")
+}
+
+// why a (new) certificate needs to be issued, or "" if the existing one
+// is fine
+func issueReason(UI ui.UserInterface, certConfig *config.Certificate, existingCert model.CertificateModel) string {
+	if nil == existingCert {
+		return "no certificate yet"
+	}
+	certData := existingCert.Certificate()
+	if certData.Revoked {
+		return "certificate was revoked"
+	} else if namesKey(command_base.CertificateNames(certData.Certificate)) != namesKey(certConfig.Domains) {
+		return "domains changed"
+	} else if nil == certData.PrivateKey {
+		return "no private key stored"
+	} else if privKey, err := utils.DecodePrivateKey(*certData.PrivateKey); nil != err {
+		return fmt.Sprintf("stored private key invalid: %s", err)
+	} else if !utils.PrivateKeyMatches(privKey, utils.KeyType(certConfig.KeyType), utils.Curve(certConfig.Curve), certConfig.RsaBits) {
+		return "key parameters changed"
+	} else if command_base.RenewalDue(UI, existingCert, certConfig.RenewDays, !ignoreARI, time.Now()) {
+		return fmt.Sprintf("expires in %s", utils.FormatDuration(certData.Certificate.NotAfter.Sub(time.Now())))
+	}
+	return ""
+}
+
+func issue(UI ui.UserInterface, reg model.RegistrationModel, certConfig *config.Certificate, existingCert model.CertificateModel) (model.CertificateModel, error) {
+	for _, domain := range certConfig.Domains {
+		if config.ChallengeNone == certConfig.Challenge {
+			if err := command_base.RequireAuthorization(reg, domain); nil != err {
+				return nil, err
+			}
+		} else if err := command_base.AuthorizeHttp01(UI, reg, domain); nil != err {
+			return nil, err
+		}
+	}
+
+	// reuse the existing key if it has the configured parameters
+	var privKey interface{}
+	if nil != existingCert && nil != existingCert.Certificate().PrivateKey {
+		if key, err := utils.DecodePrivateKey(*existingCert.Certificate().PrivateKey); nil == err &&
+			utils.PrivateKeyMatches(key, utils.KeyType(certConfig.KeyType), utils.Curve(certConfig.Curve), certConfig.RsaBits) {
+			privKey = key
+		}
+	}
+	if nil == privKey {
+		UI.Messagef("Generating private key for certificate %s", certConfig.Name)
+		var err error
+		if privKey, err = utils.CreatePrivateKey(utils.KeyType(certConfig.KeyType), utils.Curve(certConfig.Curve), &certConfig.RsaBits); nil != err {
+			return nil, fmt.Errorf("Couldn't create private key for certificate %s: %v", certConfig.Name, err)
+		}
+	}
+
+	dnsNames, ipAddresses := types.SplitDNSNamesAndIPs(certConfig.Domains)
+	csr, err := utils.MakeCertificateRequest(utils.CertificateRequestParameters{
+		PrivateKey:  privKey,
+		DNSNames:    dnsNames,
+		IPAddresses: ipAddresses,
+	})
+	if nil != err {
+		return nil, fmt.Errorf("Couldn't create certificate request: %s", err)
+	}
+
+	utils.Debugf("CSR:\n%s", pem.EncodeToMemory(csr))
+
+	return command_base.ReplaceCertificate(reg, certConfig.Name, *csr, privKey)
+}
+
+func writeFiles(UI ui.UserInterface, certConfig *config.Certificate, cert model.CertificateModel) error {
+	uid, gid, err := utils.LookupOwner(certConfig.Owner, certConfig.Group)
+	if nil != err {
+		return err
+	}
+	return command_base.WriteCertificateFiles(UI, cert, command_base.CertificateFiles{
+		CertFile:         certConfig.CertFile,
+		KeyFile:          certConfig.KeyFile,
+		ChainFile:        certConfig.ChainFile,
+		FullchainFile:    certConfig.FullchainFile,
+		KeyFullchainFile: certConfig.KeyFullchainFile,
+		URLFile:          certConfig.URLFile,
+		CertMode:         certConfig.CertFileMode(),
+		KeyMode:          certConfig.KeyFileMode(),
+		Uid:              uid,
+		Gid:              gid,
+		PKCS8:            certConfig.PKCS8,
+		PreferredChain:   certConfig.PreferredChain,
+	})
+}
+
+func apply(UI ui.UserInterface, reg model.RegistrationModel, certConfig *config.Certificate) error {
+	cert, err := reg.LoadCertificate(certConfig.Name)
+	if nil != err {
+		return fmt.Errorf("Loading certificate with name %#v failed: %v", certConfig.Name, err)
+	}
+
+	env := command_base.HookEnvironment{
+		Name:             certConfig.Name,
+		Domains:          certConfig.Domains,
+		CertFile:         certConfig.CertFile,
+		KeyFile:          certConfig.KeyFile,
+		ChainFile:        certConfig.ChainFile,
+		FullchainFile:    certConfig.FullchainFile,
+		KeyFullchainFile: certConfig.KeyFullchainFile,
+		URLFile:          certConfig.URLFile,
+	}
+	if nil != cert {
+		env.OldSerial = command_base.SerialString(cert.Certificate().Certificate)
+	}
+
+	issued := false
+	if reason := issueReason(UI, certConfig, cert); 0 != len(reason) {
+		if dryRun {
+			UI.Messagef("Would issue certificate %s: %s", certConfig.Name, reason)
+			return nil
+		}
+		UI.Messagef("Issuing certificate %s: %s", certConfig.Name, reason)
+		hooksOk := true
+		if 0 != len(certConfig.PreHook) {
+			hooksOk = command_base.RunHooks([]string{certConfig.PreHook}, "pre", env)
+		}
+		if cert, err = issue(UI, reg, certConfig, cert); nil != err {
+			// the post hook needs to undo whatever the pre hook did
+			env.Status = "failed"
+			runPostHook(certConfig, env)
+			return err
+		}
+		issued = true
+		env.NewSerial = command_base.SerialString(cert.Certificate().Certificate)
+		UI.Messagef("New certificate for %s is available at %s", certConfig.Name, cert.Certificate().Location)
+		if !hooksOk {
+			err = fmt.Errorf("pre-hook failed")
+		}
+	} else {
+		utils.Infof("Certificate %s is up to date", certConfig.Name)
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	writeErr := writeFiles(UI, certConfig, cert)
+
+	if issued {
+		if nil != writeErr {
+			env.Status = "failed"
+		} else {
+			env.Status = "success"
+		}
+		// keep a pre-hook failure as the first error
+		if !runPostHook(certConfig, env) && nil == err {
+			err = fmt.Errorf("post-hook failed")
+		}
+	}
+
+	if nil != writeErr {
+		return writeErr
+	}
+
+	// hook failures are reported, but don't stop the certificate from
+	// being deployed
+	return err
+}
+
+func runPostHook(certConfig *config.Certificate, env command_base.HookEnvironment) bool {
+	if 0 == len(certConfig.PostHook) {
+		return true
+	}
+	return command_base.RunHooks([]string{certConfig.PostHook}, "post", env)
+}
+
+func Run(UI ui.UserInterface, args []string) {
+	apply_flags.Parse(args)
+
+	if 0 != len(apply_flags.Args()) {
+		utils.Fatalf("Unexpected arguments: %v", apply_flags.Args())
+	}
+
+	conf, err := config.Load(configFile)
+	if nil != err {
+		utils.Fatalf("%s", err)
+	}
+
+	_, _, reg := command_base.OpenStorageFromFlags(UI)
+	if nil == reg {
+		utils.Fatalf("You need to register first")
+	}
+
+	failed := 0
+	managed := make(map[string]bool)
+	for ndx := range conf.Certificates {
+		certConfig := &conf.Certificates[ndx]
+		managed[certConfig.Name] = true
+		if err := apply(UI, reg, certConfig); nil != err {
+			utils.Errorf("Certificate %s: %s", certConfig.Name, err)
+			failed++
+		}
+	}
+
+	// certificates are never removed from the storage, only mention them
+	if infos, err := reg.CertificateInfos(); nil != err {
+		utils.Errorf("Couldn't list certificates: %s", err)
+		failed++
+	} else {
+		for _, info := range infos {
+			if !managed[info.Name] && -1 == strings.IndexByte(info.Name, '#') {
+				UI.Messagef("Certificate %#v is not listed in %s", info.Name, configFile)
+			}
+		}
+	}
+
+	if 0 != failed {
+		utils.Errorf("Applying %d certificate(s) failed", failed)
+		os.Exit(1)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"github.com/stbuehler/go-acme-client/command_base"
+	"github.com/stbuehler/go-acme-client/model"
 	"github.com/stbuehler/go-acme-client/types"
 	"github.com/stbuehler/go-acme-client/ui"
 	"github.com/stbuehler/go-acme-client/utils"
@@ -12,9 +13,49 @@ import (
 
 var register_flags = flag.NewFlagSet("authorize", flag.ExitOnError)
 
+var deactivate bool
+
 func init() {
+	register_flags.BoolVar(&deactivate, "deactivate", false, "Deactivate the given authorization (or all active authorizations for the given domain)")
 	command_base.AddStorageFlags(register_flags)
 	utils.AddLogFlags(register_flags)
+	utils.AddDNSFlags(register_flags)
+}
+
+func deactivateAuthorizations(UI ui.UserInterface, reg model.RegistrationModel, locationOrDnsName string) {
+	var auths []model.AuthorizationModel
+	if auth, err := reg.LoadAuthorizationByURL(locationOrDnsName); nil != err {
+		utils.Fatalf("Couldn't load authorization %v: %v", locationOrDnsName, err)
+	} else if nil != auth {
+		auths = append(auths, auth)
+	} else if infos, err := reg.AuthorizationInfos(); nil != err {
+		utils.Fatalf("Couldn't retrieve list of authorizations: %s", err)
+	} else {
+		for _, info := range infos[locationOrDnsName] {
+			switch info.Status {
+			case "", "processing", "valid":
+			default:
+				continue
+			}
+			if auth, err := reg.LoadAuthorizationByURL(info.Location); nil != err {
+				utils.Fatalf("Couldn't load authorization %v: %v", info.Location, err)
+			} else if nil != auth {
+				auths = append(auths, auth)
+			}
+		}
+	}
+
+	if 0 == len(auths) {
+		utils.Fatalf("No active authorization found for %v", locationOrDnsName)
+	}
+
+	for _, auth := range auths {
+		location := auth.Authorization().Location
+		if err := auth.Deactivate(); nil != err {
+			utils.Fatalf("Couldn't deactivate authorization %v: %s", location, err)
+		}
+		UI.Messagef("Authorization %v: %s", location, auth.Authorization().Resource.Status)
+	}
 }
 
 func Run(UI ui.UserInterface, args []string) {
@@ -47,6 +88,11 @@ func Run(UI ui.UserInterface, args []string) {
 	}
 	locationOrDnsName := register_flags.Arg(0)
 
+	if deactivate {
+		deactivateAuthorizations(UI, reg, locationOrDnsName)
+		return
+	}
+
 	auth, err := reg.LoadAuthorizationByURL(locationOrDnsName)
 	if nil != err {
 		utils.Fatalf("Couldn't load authorization %v: %v", locationOrDnsName, err)
@@ -74,8 +120,11 @@ func Run(UI ui.UserInterface, args []string) {
 			} else {
 				msg += fmt.Sprintf("Challenge: %d (%s, %s)\n", ndx, challenge.GetType(), challenge.GetStatus())
 			}
+			if problem := challenge.GetError(); nil != problem {
+				msg += fmt.Sprintf("  Error: %s\n", problem)
+			}
 		}
-		msg += fmt.Sprintf("Valid combinations: %v", authData.Resource.Combinations)
+		msg += "Completing any one of the challenges is sufficient"
 		UI.Message(msg)
 
 		if 0 != len(authData.Resource.Status) {
@@ -101,6 +150,11 @@ func Run(UI ui.UserInterface, args []string) {
 				continue
 			}
 
+			if authData.Resource.Wildcard && "dns-01" != authData.Resource.Challenges[selCh].GetType() {
+				UI.Messagef("Wildcard names can only be authorized with dns-01, try again")
+				continue
+			}
+
 			chResp, err := authData.Respond(reg.Registration(), selCh)
 			if nil != err {
 				utils.Fatalf("Error trying to create response: %s", err)
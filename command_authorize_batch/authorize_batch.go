@@ -15,6 +15,7 @@ var arg_refresh bool
 func init() {
 	command_base.AddStorageFlags(register_flags)
 	utils.AddLogFlags(register_flags)
+	utils.AddDNSFlags(register_flags)
 	register_flags.BoolVar(&arg_refresh, "refresh", false, "refresh status of locally known authorizations")
 }
 
@@ -51,6 +52,10 @@ func Run(UI ui.UserInterface, args []string) {
 				UI.Messagef("Status for %v: %s", domain, authData.Resource.Status)
 				continue
 			}
+			if authData.Resource.Wildcard {
+				UI.Messagef("Cannot batch authorize wildcard name %v (requires dns-01), use authorize instead", domain)
+				continue
+			}
 
 			tryingCombs := make(map[int]bool)
 			for ndx, challenge := range authData.Resource.Challenges {
@@ -87,22 +92,8 @@ func Run(UI ui.UserInterface, args []string) {
 					tryingCombs[ndx] = true
 				}
 			}
-			possible := false
-			for _, comb := range authData.Resource.Combinations {
-				possibleComb := true
-				for _, combElem := range comb {
-					if !tryingCombs[combElem] {
-						possibleComb = false
-						break
-					}
-				}
-				if possibleComb {
-					possible = true
-					break
-				}
-			}
-
-			if !possible {
+			// any single challenge is sufficient
+			if 0 == len(tryingCombs) {
 				UI.Messagef("Cannot batch authorize %v due to unsupported challenge types", domain)
 			}
 
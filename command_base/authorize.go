@@ -0,0 +1,100 @@
+package command_base
+
+import (
+	"fmt"
+	"github.com/stbuehler/go-acme-client/model"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"time"
+)
+
+// only check there already is a valid authorization for the domain
+func RequireAuthorization(reg model.RegistrationModel, domain string) error {
+	if auth, err := reg.GetAuthorizationByDNS(domain, false); nil != err {
+		return fmt.Errorf("Couldn't load authorization for %v: %s", domain, err)
+	} else if nil == auth || "valid" != string(auth.Authorization().Resource.Status) {
+		return fmt.Errorf("No valid authorization for %v", domain)
+	}
+	return nil
+}
+
+// make sure there is a valid authorization for the domain, completing
+// the http-01 challenge without asking if needed (the web server needs to
+// be set up as for authorize-batch)
+func AuthorizeHttp01(UI ui.UserInterface, reg model.RegistrationModel, domain string) error {
+	if auth, err := reg.GetAuthorizationByDNS(domain, false); nil != err {
+		return fmt.Errorf("Couldn't load authorization for %v: %s", domain, err)
+	} else if nil != auth && "valid" == string(auth.Authorization().Resource.Status) {
+		return nil
+	}
+
+	if types.IsWildcard(domain) {
+		return fmt.Errorf("Cannot authorize wildcard name %v (requires dns-01), use authorize instead", domain)
+	}
+
+	auth, err := reg.AuthorizeDNS(domain)
+	if nil != err {
+		return fmt.Errorf("Couldn't get authorization for %v: %s", domain, err)
+	}
+
+	authData := auth.Authorization()
+	switch string(authData.Resource.Status) {
+	case "valid":
+		return nil
+	case "", "pending":
+	default:
+		return fmt.Errorf("Authorization for %v has status %s", domain, authData.Resource.Status)
+	}
+
+	responded := false
+	for ndx, challenge := range authData.Resource.Challenges {
+		if challenge.GetType() != "http-01" {
+			continue
+		}
+		chResp, err := authData.Respond(reg.Registration(), ndx)
+		if nil != err {
+			return fmt.Errorf("Error trying to create response for %v: %s", domain, err)
+		} else if nil == chResp {
+			panic("http-01 not supported")
+		}
+		if err = chResp.InitializeResponse(UI); nil != err {
+			return fmt.Errorf("Failed to initialize response for %v: %s", domain, err)
+		}
+		if err = chResp.Verify(); nil != err {
+			return fmt.Errorf("Failed to verify challenge for %v: %s", domain, err)
+		}
+		// update refreshes auth automatically
+		if err = auth.UpdateChallenge(chResp); nil != err {
+			return fmt.Errorf("Failed to update challenge for %v: %s", domain, err)
+		}
+		responded = true
+		break
+	}
+	if !responded {
+		return fmt.Errorf("Cannot authorize %v: no http-01 challenge offered", domain)
+	}
+
+	for i := 0; i < 30; i++ {
+		authData = auth.Authorization()
+		switch string(authData.Resource.Status) {
+		case "valid":
+			UI.Messagef("Authorized %v", domain)
+			return nil
+		case "", "pending", "processing":
+		default:
+			for _, challenge := range authData.Resource.Challenges {
+				if nil != challenge.GetError() {
+					return fmt.Errorf("Authorization for %v failed: %s", domain, challenge.GetError())
+				}
+			}
+			return fmt.Errorf("Authorization for %v has status %s", domain, authData.Resource.Status)
+		}
+
+		time.Sleep(time.Second)
+		if err := auth.Refresh(); nil != err {
+			utils.Errorf("Couldn't update authorization for %v: %s", domain, err)
+		}
+	}
+	return fmt.Errorf("Waiting for authorization for %v timed out", domain)
+}
@@ -0,0 +1,83 @@
+package command_base
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/model"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"time"
+)
+
+var FlagsPreferredChain string
+
+func AddChainFlags(flags *flag.FlagSet) {
+	flags.StringVar(&FlagsPreferredChain, "preferred-chain", "", "If the server offers alternate chains, use the one whose root has this Common Name (or the chain containing a key with SubjectPublicKeyInfo hash \"sha256:<hex or base64>\")")
+}
+
+// all identifiers (DNS names and IP addresses) of a certificate, the
+// first DNS name stays first (it is used as Common Name)
+func CertificateNames(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return names
+}
+
+// whether the certificate should be renewed now; uses the window suggested
+// by the server if available (and useARI is set), otherwise renews if it
+// expires within the given number of days
+func RenewalDue(UI ui.UserInterface, cert model.CertificateModel, days int, useARI bool, now time.Time) bool {
+	certData := cert.Certificate()
+	if useARI {
+		if renewalInfo, err := cert.UpdateRenewalInfo(false); nil != err {
+			utils.Warningf("Couldn't get renewal information for %s, using expiry date: %s", certData.Name, err)
+		} else if nil != renewalInfo {
+			window := renewalInfo.SuggestedWindow
+			utils.Infof("Suggested renewal window for %s: %v - %v", certData.Name, window.Start, window.End)
+			if 0 != len(renewalInfo.ExplanationURL) {
+				UI.Messagef("Server explains renewal window for %s at %s", certData.Name, renewalInfo.ExplanationURL)
+			}
+			utils.Infof("Selected renewal time for %s: %v", certData.Name, renewalInfo.RenewalTime)
+			return !renewalInfo.RenewalTime.After(now)
+		}
+	}
+	return !certData.Certificate.NotAfter.After(now.Add(time.Duration(days) * 24 * time.Hour))
+}
+
+// issue a new certificate with the given name; an existing certificate
+// with the same name gets renamed to "<name>#<expires>" (and back, if the
+// issuance fails)
+func ReplaceCertificate(reg model.RegistrationModel, name string, csr pem.Block, privateKey interface{}) (model.CertificateModel, error) {
+	existingCert, err := reg.LoadCertificate(name)
+	if nil != err {
+		return nil, fmt.Errorf("Loading certificate with name %#v failed: %v", name, err)
+	}
+
+	var newName string
+	if nil != existingCert {
+		newName = name + "#" + existingCert.Certificate().Certificate.NotAfter.Format(time.RFC3339)
+		if err := existingCert.SetName(newName); nil != err {
+			return nil, fmt.Errorf("Couldn't change name of existing certificate %#v to %#v", name, newName)
+		}
+	}
+
+	cert, err := reg.NewCertificate(name, csr)
+	if nil != err {
+		if nil != existingCert {
+			if err := existingCert.SetName(name); nil != err {
+				utils.Errorf("Couldn't restore name of existing certificate %#v: %s", newName, err)
+			}
+		}
+		return nil, fmt.Errorf("Certificate request for %s failed: %s", name, err)
+	}
+
+	if err := cert.SetPrivateKey(privateKey); nil != err {
+		return nil, fmt.Errorf("Couldn't store private key for %s: %s", name, err)
+	}
+
+	return cert, nil
+}
@@ -0,0 +1,97 @@
+package command_base
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/model"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"os"
+)
+
+// files to write for a certificate (used by export and apply); empty file
+// names are skipped. the key-fullchain file uses the key mode, the URL
+// file the certificate mode.
+type CertificateFiles struct {
+	CertFile         string
+	KeyFile          string
+	ChainFile        string
+	FullchainFile    string
+	KeyFullchainFile string
+	URLFile          string
+	CertMode         os.FileMode
+	KeyMode          os.FileMode
+	// -1: don't change
+	Uid int
+	Gid int
+	// write the private key as PKCS#8 ("PRIVATE KEY")
+	PKCS8          bool
+	PreferredChain string
+}
+
+func writeCertificateFile(UI ui.UserInterface, description string, name string, filename string, data []byte, mode os.FileMode, files *CertificateFiles) error {
+	if 0 == len(filename) {
+		return nil
+	}
+	if changed, err := utils.WriteFileAtomic(filename, data, mode, files.Uid, files.Gid); nil != err {
+		return fmt.Errorf("Couldn't write %s for %s to %#v: %s", description, name, filename, err)
+	} else if changed {
+		UI.Messagef("Wrote %s for %s to %s", description, name, filename)
+	} else {
+		utils.Infof("%s for %s in %s is up to date", description, name, filename)
+	}
+	return nil
+}
+
+// write the configured files (atomically, only if they changed)
+func WriteCertificateFiles(UI ui.UserInterface, cert model.CertificateModel, files CertificateFiles) error {
+	certData := cert.Certificate()
+	name := certData.Name
+
+	var keyPem []byte
+	if 0 != len(files.KeyFile) || 0 != len(files.KeyFullchainFile) {
+		if nil == certData.PrivateKey {
+			return fmt.Errorf("No private key stored for certificate %s", name)
+		}
+		keyBlock := certData.PrivateKey
+		if files.PKCS8 {
+			if privKey, err := utils.DecodePrivateKey(*keyBlock); nil != err {
+				return fmt.Errorf("Couldn't decode stored private key of %s: %s", name, err)
+			} else if keyBlock, err = utils.EncodePrivateKeyPKCS8(privKey); nil != err {
+				return fmt.Errorf("Couldn't encode private key of %s: %s", name, err)
+			}
+		}
+		keyPem = pem.EncodeToMemory(keyBlock)
+	}
+
+	var chain []*x509.Certificate
+	if 0 != len(files.ChainFile) || 0 != len(files.FullchainFile) || 0 != len(files.KeyFullchainFile) {
+		var err error
+		if chain, err = cert.Chain(files.PreferredChain, false); nil != err {
+			return fmt.Errorf("Couldn't fetch issuer chain for %s: %s", name, err)
+		}
+	}
+	fullchainPem := utils.CertificatesToPem(append([]*x509.Certificate{certData.Certificate}, chain...))
+
+	if err := writeCertificateFile(UI, "private key", name, files.KeyFile, keyPem, files.KeyMode, &files); nil != err {
+		return err
+	}
+	if err := writeCertificateFile(UI, "certificate", name, files.CertFile, pem.EncodeToMemory(utils.CertificateToPem(certData.Certificate)), files.CertMode, &files); nil != err {
+		return err
+	}
+	if err := writeCertificateFile(UI, "chain", name, files.ChainFile, utils.CertificatesToPem(chain), files.CertMode, &files); nil != err {
+		return err
+	}
+	if err := writeCertificateFile(UI, "full chain", name, files.FullchainFile, fullchainPem, files.CertMode, &files); nil != err {
+		return err
+	}
+	if err := writeCertificateFile(UI, "private key and full chain", name, files.KeyFullchainFile, append(keyPem, fullchainPem...), files.KeyMode, &files); nil != err {
+		return err
+	}
+	if err := writeCertificateFile(UI, "URL", name, files.URLFile, []byte(certData.Location+"\n"), files.CertMode, &files); nil != err {
+		return err
+	}
+
+	return nil
+}
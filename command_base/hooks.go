@@ -0,0 +1,153 @@
+package command_base
+
+import (
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/config"
+	"github.com/stbuehler/go-acme-client/utils"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+type hookList []string
+
+func (hooks *hookList) String() string {
+	return strings.Join(*hooks, "; ")
+}
+
+func (hooks *hookList) Set(v string) error {
+	*hooks = append(*hooks, v)
+	return nil
+}
+
+var flagsPreHooks hookList
+var flagsPostHooks hookList
+var flagsHookConfig string
+
+// hooks are shell commands (run through /bin/sh -c) executed before and
+// after a certificate is issued
+func AddHookFlags(flags *flag.FlagSet) {
+	flags.Var(&flagsPreHooks, "pre-hook", "Command to run before a certificate is requested (can be given multiple times)")
+	flags.Var(&flagsPostHooks, "post-hook", "Command to run after a certificate was issued (can be given multiple times)")
+	flags.StringVar(&flagsHookConfig, "hook-config", "", "Config file (see apply) to load per-certificate pre-hook and post-hook settings from")
+}
+
+// information about the certificate passed to the hooks
+type HookEnvironment struct {
+	Name             string
+	Domains          []string
+	CertFile         string
+	KeyFile          string
+	URLFile          string
+	ChainFile        string
+	FullchainFile    string
+	KeyFullchainFile string
+	// empty if unknown (no old certificate / not issued yet)
+	OldSerial string
+	NewSerial string
+	// for post hooks: "success" or "failed"
+	Status string
+}
+
+func SerialString(cert *x509.Certificate) string {
+	if nil == cert || nil == cert.SerialNumber {
+		return ""
+	}
+	return cert.SerialNumber.Text(16)
+}
+
+func (env HookEnvironment) variables(phase string) []string {
+	return []string{
+		"ACME_HOOK=" + phase,
+		"ACME_CERT_NAME=" + env.Name,
+		"ACME_DOMAINS=" + strings.Join(env.Domains, " "),
+		"ACME_CERT_FILE=" + env.CertFile,
+		"ACME_KEY_FILE=" + env.KeyFile,
+		"ACME_URL_FILE=" + env.URLFile,
+		"ACME_CHAIN_FILE=" + env.ChainFile,
+		"ACME_FULLCHAIN_FILE=" + env.FullchainFile,
+		"ACME_KEY_FULLCHAIN_FILE=" + env.KeyFullchainFile,
+		"ACME_OLD_SERIAL=" + env.OldSerial,
+		"ACME_NEW_SERIAL=" + env.NewSerial,
+		"ACME_STATUS=" + env.Status,
+	}
+}
+
+// run a shell command with additional environment variables; output goes
+// to our stdout/stderr
+func RunHook(command string, phase string, env HookEnvironment) error {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), env.variables(phase)...)
+	if err := cmd.Run(); nil != err {
+		return fmt.Errorf("Hook %#v failed: %s", command, err)
+	}
+	return nil
+}
+
+// run all hooks for a phase; failures are reported, but don't stop the
+// remaining hooks. returns false if any hook failed.
+func RunHooks(commands []string, phase string, env HookEnvironment) bool {
+	ok := true
+	for _, command := range commands {
+		utils.Infof("Running %s hook for %s: %s", phase, env.Name, command)
+		if err := RunHook(command, phase, env); nil != err {
+			utils.Errorf("%s hook for certificate %s: %s", phase, env.Name, err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+var hookConfig *config.Config
+
+// load the -hook-config file (only once); a broken config is fatal, as
+// hooks silently missing (e.g. a post-hook starting a web server again)
+// are worse than not issuing at all
+func LoadHookConfigFromFlags() *config.Config {
+	if nil == hookConfig && 0 != len(flagsHookConfig) {
+		conf, err := config.Load(flagsHookConfig)
+		if nil != err {
+			utils.Fatalf("%s", err)
+		}
+		hookConfig = conf
+	}
+	return hookConfig
+}
+
+// hooks from the command line and the -hook-config file for a certificate;
+// certificate-get names certificates "<domain>#<timestamp>", the config is
+// matched against "<domain>"
+func hooksFromFlags(name string) (preHooks []string, postHooks []string) {
+	if pos := strings.IndexByte(name, '#'); -1 != pos {
+		name = name[:pos]
+	}
+	preHooks = append(preHooks, flagsPreHooks...)
+	postHooks = append(postHooks, flagsPostHooks...)
+	if conf := LoadHookConfigFromFlags(); nil != conf {
+		for _, certConfig := range conf.Certificates {
+			if certConfig.Name == name {
+				if 0 != len(certConfig.PreHook) {
+					preHooks = append(preHooks, certConfig.PreHook)
+				}
+				if 0 != len(certConfig.PostHook) {
+					postHooks = append(postHooks, certConfig.PostHook)
+				}
+			}
+		}
+	}
+	return
+}
+
+func RunPreHooksFromFlags(env HookEnvironment) bool {
+	preHooks, _ := hooksFromFlags(env.Name)
+	return RunHooks(preHooks, "pre", env)
+}
+
+func RunPostHooksFromFlags(env HookEnvironment) bool {
+	_, postHooks := hooksFromFlags(env.Name)
+	return RunHooks(postHooks, "post", env)
+}
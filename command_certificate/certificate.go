@@ -1,6 +1,7 @@
 package command_certificate
 
 import (
+	"crypto/x509"
 	"encoding/pem"
 	"flag"
 	"github.com/stbuehler/go-acme-client/command_base"
@@ -17,17 +18,44 @@ var register_flags = flag.NewFlagSet("certificate", flag.ExitOnError)
 var arg_set_name string
 var arg_check_ocsp bool
 var arg_revoke bool
+var arg_renewal_info bool
+var arg_chain bool
+var arg_export_p12 string
+var arg_export_jks string
+var arg_p12_legacy bool
+var arg_alias string
 
 func init() {
 	command_base.AddStorageFlags(register_flags)
+	command_base.AddChainFlags(register_flags)
 	utils.AddLogFlags(register_flags)
 	register_flags.StringVar(&arg_set_name, "set-name", "", "Set certificate name")
 	register_flags.BoolVar(&arg_check_ocsp, "check-ocsp", false, "Check OCSP status")
 	register_flags.BoolVar(&arg_revoke, "revoke", false, "Revoke certificate")
+	register_flags.BoolVar(&arg_chain, "chain", false, "Also show the issuer chain (fetched if not known yet)")
+	register_flags.BoolVar(&arg_renewal_info, "renewal-info", false, "Fetch suggested renewal window (ACME Renewal Information)")
+	register_flags.StringVar(&arg_export_p12, "export-p12", "", "Export certificate, issuer chain and private key to a PKCS#12 file")
+	register_flags.StringVar(&arg_export_jks, "export-jks", "", "Export certificate, issuer chain and private key to a Java keystore (JKS) file")
+	register_flags.BoolVar(&arg_p12_legacy, "p12-legacy", false, "Use legacy PKCS#12 encryption (3DES/RC2, SHA1) for old Java and Windows versions")
+	register_flags.StringVar(&arg_alias, "alias", "", "Alias of the key entry in the exported keystore (default: certificate name)")
+}
+
+func showRenewalInfo(UI ui.UserInterface, renewalInfo *types.RenewalInfo) {
+	if nil == renewalInfo {
+		return
+	}
+	window := renewalInfo.SuggestedWindow
+	UI.Messagef("\tSuggested renewal: %v - %v (starts in %v)", window.Start, window.End, utils.FormatDuration(window.Start.Sub(time.Now())))
+	if !renewalInfo.RenewalTime.IsZero() {
+		UI.Messagef("\tSelected renewal time: %v (in %v)", renewalInfo.RenewalTime, utils.FormatDuration(renewalInfo.RenewalTime.Sub(time.Now())))
+	}
+	if 0 != len(renewalInfo.ExplanationURL) {
+		UI.Messagef("\tRenewal explanation: %s", renewalInfo.ExplanationURL)
+	}
 }
 
 func showInfo(UI ui.UserInterface, certInfo storage_interface.CertificateInfo) {
-	UI.Messagef("Certificate %#v from %s (DER encoded)", certInfo.Name, certInfo.Location)
+	UI.Messagef("Certificate %#v from %s", certInfo.Name, certInfo.Location)
 	if nil != certInfo.Certificate {
 		UI.Messagef("\tCommon Name: %s", certInfo.Certificate.Subject.CommonName)
 		UI.Messagef("\tAlternative Domain Names: %v", strings.Join(certInfo.Certificate.DNSNames, ","))
@@ -36,10 +64,11 @@ func showInfo(UI ui.UserInterface, certInfo storage_interface.CertificateInfo) {
 	if 0 != len(certInfo.LinkIssuer) {
 		UI.Messagef("\tIssued by %s", certInfo.LinkIssuer)
 	}
+	showRenewalInfo(UI, certInfo.RenewalInfo)
 }
 
 func showData(UI ui.UserInterface, certData types.Certificate) {
-	UI.Messagef("Certificate %#v from %s (DER encoded)", certData.Name, certData.Location)
+	UI.Messagef("Certificate %#v from %s", certData.Name, certData.Location)
 	if nil != certData.Certificate {
 		UI.Messagef("\tCommon Name: %s", certData.Certificate.Subject.CommonName)
 		UI.Messagef("\tAlternative Domain Names: %v", strings.Join(certData.Certificate.DNSNames, ","))
@@ -48,6 +77,69 @@ func showData(UI ui.UserInterface, certData types.Certificate) {
 	if 0 != len(certData.LinkIssuer) {
 		UI.Messagef("\tIssued by %s", certData.LinkIssuer)
 	}
+	if 0 != len(certData.AlternateChains) {
+		var roots []string
+		for _, chain := range append([][]*x509.Certificate{certData.Chain}, certData.AlternateChains...) {
+			if 0 != len(chain) {
+				roots = append(roots, chain[len(chain)-1].Issuer.CommonName)
+			}
+		}
+		UI.Messagef("\tAvailable chains (by root): %s", strings.Join(roots, ", "))
+	}
+	showRenewalInfo(UI, certData.RenewalInfo)
+}
+
+func updateRenewalInfo(UI ui.UserInterface, cert model.CertificateModel) {
+	if renewalInfo, err := cert.UpdateRenewalInfo(true); nil != err {
+		UI.Messagef("Couldn't fetch renewal information for %#v: %v", cert.Certificate().Name, err)
+	} else if nil == renewalInfo {
+		utils.Fatalf("The server doesn't provide renewal information")
+	} else {
+		showData(UI, cert.Certificate())
+	}
+}
+
+func exportKeystore(UI ui.UserInterface, cert model.CertificateModel) {
+	certData := cert.Certificate()
+	if nil == certData.PrivateKey {
+		utils.Fatalf("No private key stored for certificate %#v", certData.Name)
+	}
+	privKey, err := utils.DecodePrivateKey(*certData.PrivateKey)
+	if nil != err {
+		utils.Fatalf("Couldn't decode stored private key: %v", err)
+	}
+	chain, err := cert.Chain(command_base.FlagsPreferredChain, false)
+	if nil != err {
+		utils.Fatalf("Couldn't fetch issuer chain: %v", err)
+	}
+
+	password, err := UI.NewPasswordPrompt("Enter export password", "Enter password again")
+	if nil != err {
+		utils.Fatalf("Couldn't read export password: %v", err)
+	}
+
+	alias := arg_alias
+	if 0 == len(alias) {
+		alias = certData.Name
+	}
+
+	var filename string
+	var data []byte
+	if 0 != len(arg_export_p12) {
+		filename = arg_export_p12
+		data, err = utils.EncodePKCS12(privKey, certData.Certificate, chain, password, arg_p12_legacy)
+	} else {
+		filename = arg_export_jks
+		data, err = utils.EncodeJKS(alias, privKey, certData.Certificate, chain, password, time.Now())
+	}
+	if nil != err {
+		utils.Fatalf("Couldn't encode keystore: %v", err)
+	}
+
+	if _, err := utils.WriteFileAtomic(filename, data, 0600, -1, -1); nil != err {
+		utils.Fatalf("Couldn't write %#v: %v", filename, err)
+	}
+	UI.Messagef("Exported certificate %#v to %s", certData.Name, filename)
 }
 
 func try_load(reg model.RegistrationModel, locationOrName string) model.CertificateModel {
@@ -100,6 +192,22 @@ func Run(UI ui.UserInterface, args []string) {
 		}
 		have_mode = true
 	}
+	if arg_renewal_info {
+		if have_mode {
+			utils.Fatalf("Only one command mode can be given")
+		}
+		have_mode = true
+	}
+	arg_export := 0 != len(arg_export_p12) || 0 != len(arg_export_jks)
+	if arg_export {
+		if have_mode || (0 != len(arg_export_p12) && 0 != len(arg_export_jks)) {
+			utils.Fatalf("Only one command mode can be given")
+		}
+		have_mode = true
+		if 1 != len(register_flags.Args()) {
+			utils.Fatalf("Require a certificate name or location to export")
+		}
+	}
 
 	if len(arg_set_name) > 0 {
 		if len(register_flags.Args()) > 1 {
@@ -133,6 +241,10 @@ func Run(UI ui.UserInterface, args []string) {
 					}
 				}
 			}
+		} else if arg_renewal_info {
+			for _, certInfo := range certs {
+				updateRenewalInfo(UI, load(reg, certInfo.Name))
+			}
 		} else if arg_revoke {
 			UI.Message("Searching for replaced certificates")
 			// when a certificate gets renewed, the old one is renamed:
@@ -185,6 +297,10 @@ func Run(UI ui.UserInterface, args []string) {
 				} else {
 					UI.Messagef("Not revoking certificate")
 				}
+			} else if arg_renewal_info {
+				updateRenewalInfo(UI, cert)
+			} else if arg_export {
+				exportKeystore(UI, cert)
 			} else if arg_check_ocsp {
 				if status, err := CheckOCSP(certData.LinkIssuer, certData.Certificate); nil != err {
 					UI.Messagef("Couldn't check OCSP status: %v", err)
@@ -198,6 +314,13 @@ func Run(UI ui.UserInterface, args []string) {
 				}
 			} else {
 				UI.Messagef("%s", pem.EncodeToMemory(utils.CertificateToPem(certData.Certificate)))
+				if arg_chain {
+					if chain, err := cert.Chain(command_base.FlagsPreferredChain, false); nil != err {
+						utils.Fatalf("Couldn't fetch issuer chain: %v", err)
+					} else {
+						UI.Messagef("%s", utils.CertificatesToPem(chain))
+					}
+				}
 				if nil != certData.PrivateKey {
 					UI.Messagef("%s", pem.EncodeToMemory(certData.PrivateKey))
 				}
@@ -120,7 +120,7 @@ func CheckOCSP(link_issuer string, cert *x509.Certificate) (OCSPStatus, error) {
 		return Unknown, fmt.Errorf("No OCSP server defined")
 	}
 
-	issuerCert, err := requests.FetchCertificate(link_issuer)
+	issuerCert, err := requests.FetchIssuerCertificate(link_issuer)
 	if nil != err {
 		return Unknown, fmt.Errorf("Failed to fetch issuer certificate: %v", err)
 	}
@@ -1,10 +1,12 @@
 package command_certificate_batch
 
 import (
+	"crypto/x509"
 	"encoding/pem"
 	"flag"
 	"fmt"
 	"github.com/stbuehler/go-acme-client/command_base"
+	"github.com/stbuehler/go-acme-client/model"
 	"github.com/stbuehler/go-acme-client/types"
 	"github.com/stbuehler/go-acme-client/ui"
 	"github.com/stbuehler/go-acme-client/utils"
@@ -16,21 +18,91 @@ import (
 var certificate_batch_flags = flag.NewFlagSet("certificate-batch", flag.ExitOnError)
 
 var rsabits int = 2048
-var curve utils.Curve = utils.CurveP521
-var keyType utils.KeyType = utils.KeyRSA
+var curve utils.Curve = utils.CurveP256
+var keyType utils.KeyType = utils.KeyEcdsa
 var filePrefix string
+var noChain bool
 
 func init() {
 	certificate_batch_flags.IntVar(&rsabits, "rsa-bits", 2048, "Number of bits to generate the RSA key with (if selected)")
 	certificate_batch_flags.Var(&curve, "curve", "Elliptic curve to generate ECDSA key with (if selected), one of P-256, P-384, P-521")
-	certificate_batch_flags.Var(&keyType, "key-type", "Key type to generate, RSA or ECDSA")
-	certificate_batch_flags.StringVar(&filePrefix, "prefix", "", "Prefix for generated <name-key.pem>, <name-cert.pem>, <name.url> files")
+	certificate_batch_flags.Var(&keyType, "key-type", "Key type to generate, RSA, ECDSA or Ed25519")
+	certificate_batch_flags.StringVar(&filePrefix, "prefix", "", "Prefix for generated <name-key.pem>, <name-cert.pem>, <name-chain.pem>, <name-fullchain.pem>, <name-key-fullchain.pem>, <name.url> files")
+	certificate_batch_flags.BoolVar(&noChain, "no-chain", false, "Don't fetch the issuer chain and don't write the chain files")
 	command_base.AddStorageFlags(certificate_batch_flags)
+	command_base.AddHookFlags(certificate_batch_flags)
+	command_base.AddChainFlags(certificate_batch_flags)
 	utils.AddLogFlags(certificate_batch_flags)
 }
 
+// chain files are simply replaced; failures are only reported
+func writeChainFiles(cert model.CertificateModel, privKey interface{}, chainFilename string, fullchainFilename string, keyFullchainFilename string) {
+	certData := cert.Certificate()
+	chain, err := cert.Chain(command_base.FlagsPreferredChain, false)
+	if nil != err {
+		utils.Errorf("Couldn't fetch issuer chain for %s: %s", certData.Name, err)
+		return
+	}
+
+	if _, err := utils.WriteFileAtomic(chainFilename, utils.CertificatesToPem(chain), 0644, -1, -1); nil != err {
+		utils.Errorf("Couldn't write chain for %s to %#v: %s", certData.Name, chainFilename, err)
+	}
+	fullchain := utils.CertificatesToPem(append([]*x509.Certificate{certData.Certificate}, chain...))
+	if _, err := utils.WriteFileAtomic(fullchainFilename, fullchain, 0644, -1, -1); nil != err {
+		utils.Errorf("Couldn't write full chain for %s to %#v: %s", certData.Name, fullchainFilename, err)
+	}
+	if privKeyBlock, err := utils.EncodePrivateKey(privKey); nil != err {
+		utils.Errorf("Couldn't serialize private key for %s: %v", certData.Name, err)
+	} else if _, err := utils.WriteFileAtomic(keyFullchainFilename, append(pem.EncodeToMemory(privKeyBlock), fullchain...), 0600, -1, -1); nil != err {
+		utils.Errorf("Couldn't write private key and full chain for %s to %#v: %s", certData.Name, keyFullchainFilename, err)
+	}
+}
+
+// request the certificate and write the URL, certificate and chain files;
+// returns the certificate if it was issued, even if writing files failed
+func issue(UI ui.UserInterface, reg model.RegistrationModel, name string, csr pem.Block, privKey interface{}, urlFilename string, certFilename string, chainFilename string, fullchainFilename string, keyFullchainFilename string) (model.CertificateModel, error) {
+	cert, err := reg.NewCertificate(name, csr)
+	if nil != err {
+		return nil, fmt.Errorf("Certificate request failed: %s", err)
+	}
+
+	if err := cert.SetPrivateKey(privKey); nil != err {
+		utils.Errorf("Couldn't store private key: %s", err)
+	}
+
+	certData := cert.Certificate()
+	UI.Messagef("New certificate for %s is available at %s", name, certData.Location)
+
+	if urlFile, err := os.OpenFile(urlFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644); nil != err {
+		return cert, fmt.Errorf("Couldn't create URL file for %s at %#v", name, urlFilename)
+	} else if _, err := urlFile.WriteString(certData.Location + "\n"); nil != err {
+		urlFile.Close()
+		os.Remove(urlFilename)
+		return cert, fmt.Errorf("Couldn't write URL file for %s to %#v", name, urlFilename)
+	} else {
+		urlFile.Close()
+	}
+
+	if certFile, err := os.OpenFile(certFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644); nil != err {
+		return cert, fmt.Errorf("Couldn't create certificate file for %s at %#v", name, certFilename)
+	} else if err := pem.Encode(certFile, utils.CertificateToPem(certData.Certificate)); nil != err {
+		certFile.Close()
+		os.Remove(certFilename)
+		return cert, fmt.Errorf("Couldn't write certificate for %s to %#v", name, certFilename)
+	} else {
+		certFile.Close()
+	}
+
+	if !noChain {
+		writeChainFiles(cert, privKey, chainFilename, fullchainFilename, keyFullchainFilename)
+	}
+
+	return cert, nil
+}
+
 func Run(UI ui.UserInterface, args []string) {
 	certificate_batch_flags.Parse(args)
+	command_base.LoadHookConfigFromFlags()
 
 	_, _, reg := command_base.OpenStorageFromFlags(UI)
 	if nil == reg {
@@ -82,6 +154,7 @@ func Run(UI ui.UserInterface, args []string) {
 		}
 
 		overwrite := false
+		oldSerial := ""
 
 		if existingCert, err := reg.LoadCertificate(name); nil != err {
 			utils.Fatalf("Loading certificate with name %#v failed: %v", name, err)
@@ -92,6 +165,7 @@ func Run(UI ui.UserInterface, args []string) {
 				utils.Fatalf("Prompt failed: %v", err)
 			} else if result == "Y" || result == "y" {
 				overwrite = true
+				oldSerial = command_base.SerialString(existingCert.Certificate().Certificate)
 				newName := name + "#" + expires.Format(time.RFC3339)
 				if err := existingCert.SetName(newName); nil != err {
 					utils.Fatalf("Couldn't change name of existing certificate %#v to %#v", name, newName)
@@ -109,10 +183,14 @@ func Run(UI ui.UserInterface, args []string) {
 			}
 		}
 
-		basename := filePrefix + name
+		// no "*" from wildcard names in filenames
+		basename := filePrefix + strings.Replace(name, "*", "_", -1)
 		privKeyFilename := basename + "-key.pem"
 		certFilename := basename + "-cert.pem"
 		urlFilename := basename + ".url"
+		chainFilename := basename + "-chain.pem"
+		fullchainFilename := basename + "-fullchain.pem"
+		keyFullchainFilename := basename + "-key-fullchain.pem"
 
 		var privKey interface{}
 
@@ -169,9 +247,11 @@ func Run(UI ui.UserInterface, args []string) {
 			privKeyFile.Close()
 		}
 
+		dnsNames, ipAddresses := types.SplitDNSNamesAndIPs(selectedDomains)
 		csr, err := utils.MakeCertificateRequest(utils.CertificateRequestParameters{
-			PrivateKey: privKey,
-			DNSNames:   selectedDomains,
+			PrivateKey:  privKey,
+			DNSNames:    dnsNames,
+			IPAddresses: ipAddresses,
 		})
 		if nil != err {
 			utils.Fatalf("Couldn't create certificate request: %s", err)
@@ -179,41 +259,35 @@ func Run(UI ui.UserInterface, args []string) {
 
 		utils.Debugf("CSR:\n%s", pem.EncodeToMemory(csr))
 
-		cert, err := reg.NewCertificate(name, *csr)
-		if nil != err {
-			utils.Fatalf("Certificate request failed: %s", err)
-			panic(nil)
+		hookEnv := command_base.HookEnvironment{
+			Name:      name,
+			Domains:   selectedDomains,
+			CertFile:  certFilename,
+			KeyFile:   privKeyFilename,
+			URLFile:   urlFilename,
+			OldSerial: oldSerial,
 		}
-
-		if err := cert.SetPrivateKey(privKey); nil != err {
-			utils.Errorf("Couldn't store private key: %s", err)
+		if !noChain {
+			hookEnv.ChainFile = chainFilename
+			hookEnv.FullchainFile = fullchainFilename
+			hookEnv.KeyFullchainFile = keyFullchainFilename
 		}
+		command_base.RunPreHooksFromFlags(hookEnv)
 
-		certData := cert.Certificate()
-		UI.Messagef("New certificate for %s is available at %s (DER encoded)", name, certData.Location)
-
-		if urlFile, err := os.OpenFile(urlFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644); nil != err {
-			utils.Fatalf("Couldn't create URL file for %s at %#v", name, urlFilename)
-			panic(nil)
-		} else if _, err := urlFile.WriteString(certData.Location + "\n"); nil != err {
-			urlFile.Close()
-			os.Remove(urlFilename)
-			utils.Fatalf("Couldn't write URL file for %s to %#v", name, urlFilename)
-			panic(nil)
-		} else {
-			urlFile.Close()
+		// the post hooks always run (to undo whatever the pre hooks did),
+		// even if the certificate couldn't be issued or written
+		cert, err := issue(UI, reg, name, *csr, privKey, urlFilename, certFilename, chainFilename, fullchainFilename, keyFullchainFilename)
+		if nil != cert {
+			hookEnv.NewSerial = command_base.SerialString(cert.Certificate().Certificate)
 		}
-
-		if certFile, err := os.OpenFile(certFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644); nil != err {
-			utils.Fatalf("Couldn't create certificate file for %s at %#v", name, certFilename)
-			panic(nil)
-		} else if err := pem.Encode(certFile, utils.CertificateToPem(certData.Certificate)); nil != err {
-			certFile.Close()
-			os.Remove(certFilename)
-			utils.Fatalf("Couldn't write certificate for %s to %#v", name, certFilename)
-			panic(nil)
+		if nil != err {
+			hookEnv.Status = "failed"
 		} else {
-			certFile.Close()
+			hookEnv.Status = "success"
+		}
+		command_base.RunPostHooksFromFlags(hookEnv)
+		if nil != err {
+			utils.Fatalf("%s", err)
 		}
 	}
 }
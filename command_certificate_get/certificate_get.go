@@ -14,21 +14,24 @@ import (
 var register_flags = flag.NewFlagSet("certificate-get", flag.ExitOnError)
 
 var rsabits int = 2048
-var curve utils.Curve = utils.CurveP521
-var keyType utils.KeyType = utils.KeyRSA
+var curve utils.Curve = utils.CurveP256
+var keyType utils.KeyType = utils.KeyEcdsa
 var loadPrivKey string
 
 func init() {
 	register_flags.IntVar(&rsabits, "rsa-bits", 2048, "Number of bits to generate the RSA key with (if selected)")
 	register_flags.Var(&curve, "curve", "Elliptic curve to generate ECDSA key with (if selected), one of P-256, P-384, P-521")
-	register_flags.Var(&keyType, "key-type", "Key type to generate, RSA or ECDSA")
+	register_flags.Var(&keyType, "key-type", "Key type to generate, RSA, ECDSA or Ed25519")
 	register_flags.StringVar(&loadPrivKey, "import-key", "", "Import private key")
 	command_base.AddStorageFlags(register_flags)
+	command_base.AddChainFlags(register_flags)
+	command_base.AddHookFlags(register_flags)
 	utils.AddLogFlags(register_flags)
 }
 
 func Run(UI ui.UserInterface, args []string) {
 	register_flags.Parse(args)
+	command_base.LoadHookConfigFromFlags()
 
 	_, _, reg := command_base.OpenStorageFromFlags(UI)
 	if nil == reg {
@@ -116,9 +119,11 @@ func Run(UI ui.UserInterface, args []string) {
 		return
 	}
 
+	dnsNames, ipAddresses := types.SplitDNSNamesAndIPs(selectedDomains)
 	csr, err := utils.MakeCertificateRequest(utils.CertificateRequestParameters{
-		PrivateKey: pkey,
-		DNSNames:   selectedDomains,
+		PrivateKey:  pkey,
+		DNSNames:    dnsNames,
+		IPAddresses: ipAddresses,
 	})
 	if nil != err {
 		utils.Fatalf("Couldn't create certificate request: %s", err)
@@ -127,8 +132,17 @@ func Run(UI ui.UserInterface, args []string) {
 	utils.Debugf("CSR:\n%s", pem.EncodeToMemory(csr))
 
 	name := selectedDomains[0] + "#" + time.Now().Format(time.RFC3339)
+	hookEnv := command_base.HookEnvironment{
+		Name:    name,
+		Domains: selectedDomains,
+	}
+	command_base.RunPreHooksFromFlags(hookEnv)
+
 	cert, err := reg.NewCertificate(name, *csr)
 	if nil != err {
+		// the post hooks need to undo whatever the pre hooks did
+		hookEnv.Status = "failed"
+		command_base.RunPostHooksFromFlags(hookEnv)
 		utils.Fatalf("Certificate request failed: %s", err)
 	}
 
@@ -137,12 +151,21 @@ func Run(UI ui.UserInterface, args []string) {
 	}
 	certData := cert.Certificate()
 
-	UI.Messagef("New certificate is available under: %s (DER encoded)", certData.Location)
+	UI.Messagef("New certificate is available under: %s", certData.Location)
 	if 0 != len(certData.LinkIssuer) {
 		UI.Messagef("Issueing certificate available at: %s", certData.LinkIssuer)
 	}
 	UI.Messagef("%s", pem.EncodeToMemory(utils.CertificateToPem(certData.Certificate)))
+	if chain, err := cert.Chain(command_base.FlagsPreferredChain, false); nil != err {
+		utils.Errorf("Couldn't fetch issuer chain: %s", err)
+	} else if 0 != len(chain) {
+		UI.Messagef("Issuer chain:\n%s", utils.CertificatesToPem(chain))
+	}
 	if nil != certData.PrivateKey {
 		UI.Messagef("%s", pem.EncodeToMemory(certData.PrivateKey))
 	}
+
+	hookEnv.NewSerial = command_base.SerialString(certData.Certificate)
+	hookEnv.Status = "success"
+	command_base.RunPostHooksFromFlags(hookEnv)
 }
@@ -0,0 +1,135 @@
+package command_export
+
+import (
+	"flag"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/command_base"
+	"github.com/stbuehler/go-acme-client/config"
+	"github.com/stbuehler/go-acme-client/model"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var export_flags = flag.NewFlagSet("export", flag.ExitOnError)
+
+var targetDir string
+var certFile string
+var keyFile string
+var chainFile string
+var fullchainFile string
+var keyFullchainFile string
+var urlFile string
+var certMode string
+var keyMode string
+var owner string
+var group string
+var pkcs8 bool
+
+func init() {
+	export_flags.StringVar(&targetDir, "dir", ".", "Directory to write the files to")
+	export_flags.StringVar(&certFile, "cert-file", "{name}-cert.pem", "Certificate file name (\"{name}\" is replaced with the certificate name, empty to skip)")
+	export_flags.StringVar(&keyFile, "key-file", "{name}-key.pem", "Private key file name (empty to skip)")
+	export_flags.StringVar(&chainFile, "chain-file", "{name}-chain.pem", "Issuer chain file name (empty to skip)")
+	export_flags.StringVar(&fullchainFile, "fullchain-file", "{name}-fullchain.pem", "Certificate and issuer chain file name (empty to skip)")
+	export_flags.StringVar(&keyFullchainFile, "key-fullchain-file", "", "Private key, certificate and issuer chain file name (empty to skip)")
+	export_flags.StringVar(&urlFile, "url-file", "{name}.url", "Certificate URL file name (empty to skip)")
+	export_flags.StringVar(&certMode, "cert-mode", "0644", "Permissions of the certificate, chain and URL files")
+	export_flags.StringVar(&keyMode, "key-mode", "0600", "Permissions of the files containing the private key")
+	export_flags.StringVar(&owner, "owner", "", "Owner (user name or uid) of the written files")
+	export_flags.StringVar(&group, "group", "", "Group (name or gid) of the written files")
+	export_flags.BoolVar(&pkcs8, "pkcs8", false, "Write private keys as PKCS#8 (\"PRIVATE KEY\") instead of the key type specific format")
+	command_base.AddChainFlags(export_flags)
+	command_base.AddStorageFlags(export_flags)
+	utils.AddLogFlags(export_flags)
+}
+
+func outputPath(name string, pattern string) string {
+	if 0 == len(pattern) {
+		return ""
+	}
+	// no "*" from wildcard names in filenames
+	path := strings.Replace(pattern, "{name}", strings.Replace(name, "*", "_", -1), -1)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(targetDir, path)
+	}
+	return path
+}
+
+func export(UI ui.UserInterface, cert model.CertificateModel, files command_base.CertificateFiles) error {
+	certData := cert.Certificate()
+	name := certData.Name
+	if 0 == len(name) {
+		return fmt.Errorf("Certificate %s has no name (set one with certificate -set-name)", certData.Location)
+	} else if strings.ContainsRune(name, filepath.Separator) {
+		return fmt.Errorf("Certificate name %#v can't be used in a file name", name)
+	}
+
+	files.CertFile = outputPath(name, certFile)
+	files.KeyFile = outputPath(name, keyFile)
+	files.ChainFile = outputPath(name, chainFile)
+	files.FullchainFile = outputPath(name, fullchainFile)
+	files.KeyFullchainFile = outputPath(name, keyFullchainFile)
+	files.URLFile = outputPath(name, urlFile)
+	return command_base.WriteCertificateFiles(UI, cert, files)
+}
+
+func Run(UI ui.UserInterface, args []string) {
+	export_flags.Parse(args)
+
+	files := command_base.CertificateFiles{
+		PKCS8:          pkcs8,
+		PreferredChain: command_base.FlagsPreferredChain,
+	}
+	var err error
+	if files.CertMode, err = config.ParseMode(certMode); nil != err {
+		utils.Fatalf("-cert-mode: %s", err)
+	}
+	if files.KeyMode, err = config.ParseMode(keyMode); nil != err {
+		utils.Fatalf("-key-mode: %s", err)
+	}
+	if files.Uid, files.Gid, err = utils.LookupOwner(owner, group); nil != err {
+		utils.Fatalf("%s", err)
+	}
+
+	_, _, reg := command_base.OpenStorageFromFlags(UI)
+	if nil == reg {
+		utils.Fatalf("You need to register first")
+	}
+
+	names := export_flags.Args()
+	if 0 == len(names) {
+		// all current certificates; replaced ones are renamed to
+		// "<name>#<expires>"
+		infos, err := reg.CertificateInfos()
+		if nil != err {
+			utils.Fatalf("Couldn't list certificates: %s", err)
+		}
+		for _, info := range infos {
+			if 0 != len(info.Name) && -1 == strings.IndexByte(info.Name, '#') {
+				names = append(names, info.Name)
+			}
+		}
+	}
+
+	failed := 0
+	for _, locationOrName := range names {
+		if cert, err := reg.LoadCertificate(locationOrName); nil != err {
+			utils.Errorf("Loading certificate %#v failed: %v", locationOrName, err)
+			failed++
+		} else if nil == cert {
+			utils.Errorf("Certificate %#v not found", locationOrName)
+			failed++
+		} else if err := export(UI, cert, files); nil != err {
+			utils.Errorf("Exporting certificate %#v failed: %s", locationOrName, err)
+			failed++
+		}
+	}
+
+	if 0 != failed {
+		utils.Errorf("Exporting %d certificate(s) failed", failed)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,49 @@
+package command_offline_submit
+
+import (
+	"flag"
+	"github.com/stbuehler/go-acme-client/requests"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"os"
+)
+
+var offline_submit_flags = flag.NewFlagSet("offline-submit", flag.ExitOnError)
+
+var nonceCount int
+
+func init() {
+	offline_submit_flags.IntVar(&nonceCount, "nonces", 10, "Number of fresh nonces to put into the bundle for each server")
+	utils.AddLogFlags(offline_submit_flags)
+}
+
+// runs on the online host, doesn't need the storage
+func Run(UI ui.UserInterface, args []string) {
+	offline_submit_flags.Parse(args)
+
+	if 1 != len(offline_submit_flags.Args()) {
+		utils.Fatalf("Require exactly one offline bundle file")
+	}
+	filename := offline_submit_flags.Arg(0)
+
+	bundle, err := requests.LoadOfflineBundle(filename)
+	if nil != err {
+		utils.Fatalf("Couldn't load offline bundle: %s", err)
+	}
+
+	if 0 == len(bundle.Requests) && 0 == len(bundle.Nonces) {
+		UI.Messagef("No requests queued in %s", filename)
+	}
+
+	sent, failed := requests.SubmitOfflineBundle(bundle, nonceCount)
+
+	if err := bundle.Save(); nil != err {
+		utils.Fatalf("Couldn't write offline bundle: %s", err)
+	}
+	UI.Messagef("Sent %d request(s); copy %s back to the offline host and run the command again", sent, filename)
+
+	if 0 != failed {
+		utils.Errorf("%d request(s) or nonce fetches failed; failed requests stay queued", failed)
+		os.Exit(1)
+	}
+}
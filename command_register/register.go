@@ -1,43 +1,185 @@
 package command_register
 
 import (
+	"encoding/pem"
 	"flag"
 	"fmt"
 	"github.com/stbuehler/go-acme-client/command_base"
+	"github.com/stbuehler/go-acme-client/model"
 	"github.com/stbuehler/go-acme-client/types"
 	"github.com/stbuehler/go-acme-client/ui"
 	"github.com/stbuehler/go-acme-client/utils"
+	"io/ioutil"
+	"os"
 	"reflect"
+	"time"
 )
 
 var register_flags = flag.NewFlagSet("register", flag.ExitOnError)
 
 var rsabits int = 2048
-var curve utils.Curve = utils.CurveP521
-var keyType utils.KeyType = utils.KeyRSA
+var curve utils.Curve = utils.CurveP256
+var keyType utils.KeyType = utils.KeyEcdsa
 var storagePath string
 var no_refresh bool
 var show_tos bool
 var agree_tos bool
 var modify bool
+var rollover bool
+var rolloverKeyFile string
+var signerReference string
+var deactivate bool
+var purge bool
 var directoryURL string
 
-const lifeDirectoryURL = "https://acme-v01.api.letsencrypt.org/directory"
-const demoDirectoryURL = "https://acme-staging.api.letsencrypt.org/directory"
+const lifeDirectoryURL = "https://acme-v02.api.letsencrypt.org/directory"
+const demoDirectoryURL = "https://acme-staging-v02.api.letsencrypt.org/directory"
 
 func init() {
 	register_flags.IntVar(&rsabits, "rsa-bits", 2048, "Number of bits to generate the RSA key with (if selected)")
 	register_flags.Var(&curve, "curve", "Elliptic curve to generate ECDSA key with (if selected), one of P-256, P-384, P-521")
-	register_flags.Var(&keyType, "key-type", "Key type to generate, RSA or ECDSA")
+	register_flags.Var(&keyType, "key-type", "Key type to generate, RSA, ECDSA or Ed25519")
 	register_flags.StringVar(&directoryURL, "url", lifeDirectoryURL, "ACME Directory URL")
 	register_flags.BoolVar(&no_refresh, "no-refresh", false, "Disable automatically fetching an updated registration")
 	register_flags.BoolVar(&show_tos, "show-tos", false, "Show Terms of service if available, even when already agreed to something")
 	register_flags.BoolVar(&agree_tos, "agree-tos", false, "Automatically agree to terms of service")
 	register_flags.BoolVar(&modify, "modify", false, "Modify contact information")
+	register_flags.BoolVar(&rollover, "rollover", false, "Replace the account key with a new key (generated with -key-type/-curve/-rsa-bits or loaded from -rollover-key)")
+	register_flags.StringVar(&rolloverKeyFile, "rollover-key", "", "Load the new account key for -rollover from a PEM file")
+	register_flags.StringVar(&signerReference, "signer", "", "Use an external signer for the new account key (e.g. \"pkcs11:token=acme;object=account?module-path=/usr/lib/softhsm/libsofthsm2.so\"); only the reference and the public key are stored")
+	register_flags.BoolVar(&deactivate, "deactivate", false, "Deactivate the registration on the server")
+	register_flags.BoolVar(&purge, "purge", false, "Delete a deactivated registration with all its authorizations and certificates from the storage")
 	command_base.AddStorageFlags(register_flags)
 	utils.AddLogFlags(register_flags)
 }
 
+// returns nil if nothing was agreed to
+func askTermsOfService(UI ui.UserInterface, termsOfService string, agreementURL string) *string {
+	if 0 == len(termsOfService) || !(show_tos || 0 == len(agreementURL)) {
+		return nil
+	}
+	if agreementURL == termsOfService {
+		UI.Messagef("The terms of service at %s are marked as already agreed to.", termsOfService)
+		return nil
+	} else if agree_tos {
+		UI.Messagef("Automatically accepting the terms of service at %s as requested:", termsOfService)
+		return &termsOfService
+	}
+
+	var title string
+	if 0 == len(agreementURL) {
+		title = "The server asks for confirmation of the terms of service at %s"
+	} else {
+		title = "There are new terms of service at %s"
+	}
+	ack, err := UI.YesNoDialog(fmt.Sprintf(title, termsOfService), "", "Agree?", false)
+	if err != nil {
+		utils.Fatalf("Couldn't read acknowledge for terms of service: %s", err)
+	}
+	if ack {
+		return &termsOfService
+	} else if 0 == len(agreementURL) {
+		utils.Infof("Terms of service not accepted")
+	} else {
+		utils.Infof("New terms of service not accepted")
+	}
+	return nil
+}
+
+func externalSigningKey() types.SigningKey {
+	signingKey, err := types.NewExternalSigningKey(signerReference)
+	if nil != err {
+		utils.Fatalf("Couldn't use external signer: %s", err)
+	}
+	return signingKey
+}
+
+func rolloverKey(UI ui.UserInterface, reg model.RegistrationModel) {
+	var signingKey types.SigningKey
+	if 0 != len(signerReference) {
+		signingKey = externalSigningKey()
+	} else if 0 != len(rolloverKeyFile) {
+		pkeyPrompt, _ := UI.PasswordPromptOnce("Enter private key password")
+		if pkeyFile, err := os.Open(rolloverKeyFile); nil != err {
+			utils.Fatalf("%s", err)
+		} else if pkey, err := utils.LoadFirstPrivateKey(pkeyFile, pkeyPrompt); nil != err {
+			utils.Fatalf("%s", err)
+		} else if signingKey, err = types.NewSigningKey(pkey); nil != err {
+			utils.Fatalf("Couldn't use private key from %s: %s", rolloverKeyFile, err)
+		}
+	} else {
+		UI.Message("Generating new private key, might take some time")
+		var err error
+		if signingKey, err = types.CreateSigningKey(keyType, curve, &rsabits); nil != err {
+			utils.Fatalf("Couldn't create private key for registration: %s", err)
+		}
+	}
+
+	if err := reg.RolloverKey(signingKey); nil != err {
+		if notStored, ok := err.(*model.KeyNotStoredError); ok {
+			rescueKey(UI, reg, notStored.NewKey)
+		}
+		utils.Fatalf("Couldn't replace the account key: %s", err)
+	}
+	UI.Message("Replaced the account key")
+}
+
+// the new account key couldn't be stored; write it to a file (or, if that
+// fails too, to stdout) so the account isn't lost
+func rescueKey(UI ui.UserInterface, reg model.RegistrationModel, signingKey types.SigningKey) {
+	keyBlock, err := signingKey.ExportPem("", utils.PemDefaultCipher)
+	if nil != err {
+		utils.Errorf("Couldn't export the new account key: %s", err)
+		return
+	}
+	data := pem.EncodeToMemory(keyBlock)
+	filename := fmt.Sprintf("%s-account-key-%s.pem", reg.Registration().Name, time.Now().UTC().Format("20060102T150405Z"))
+	if err := ioutil.WriteFile(filename, data, 0600); nil != err {
+		utils.Errorf("Couldn't write the new account key to %s: %s", filename, err)
+		UI.Messagef("The new account key is:\n%s", string(data))
+	} else {
+		UI.Messagef("Wrote the new account key to %s; keep it, the old key isn't valid anymore", filename)
+	}
+}
+
+func deactivateRegistration(UI ui.UserInterface, reg model.RegistrationModel) {
+	regData := reg.Registration()
+	if "deactivated" == regData.Resource.Status {
+		UI.Messagef("Registration %s already is deactivated", regData.Location)
+	} else {
+		ack, err := UI.YesNoDialog(
+			fmt.Sprintf("Deactivating registration %s", regData.Location),
+			"A deactivated registration can't be used anymore; this can't be undone.",
+			"Deactivate?", false)
+		if nil != err {
+			utils.Fatalf("Couldn't read confirmation: %s", err)
+		} else if !ack {
+			utils.Fatalf("Deactivation not confirmed")
+		}
+		if err := reg.Deactivate(); nil != err {
+			utils.Fatalf("Couldn't deactivate registration: %s", err)
+		}
+		UI.Messagef("Deactivated registration %s", regData.Location)
+	}
+
+	if purge {
+		ack, err := UI.YesNoDialog(
+			fmt.Sprintf("Deleting registration %#v from storage", regData.Name),
+			"This deletes the account key and all authorizations and certificates (including their private keys) of the registration.",
+			"Delete?", false)
+		if nil != err {
+			utils.Fatalf("Couldn't read confirmation: %s", err)
+		} else if !ack {
+			UI.Message("Keeping registration in storage")
+			return
+		}
+		if err := reg.Delete(); nil != err {
+			utils.Fatalf("Couldn't delete registration: %s", err)
+		}
+		UI.Messagef("Deleted registration %#v from storage", regData.Name)
+	}
+}
+
 func Run(UI ui.UserInterface, args []string) {
 	register_flags.Parse(args)
 
@@ -45,6 +187,14 @@ func Run(UI ui.UserInterface, args []string) {
 
 	var newContact []string
 	var newAgreementURL *string
+	// new registrations already asked before creation
+	askTos := true
+
+	if nil != reg && (deactivate || "deactivated" == reg.Registration().Resource.Status) {
+		// the server won't answer requests for deactivated registrations
+		deactivateRegistration(UI, reg)
+		return
+	}
 
 	if nil != reg {
 		if !no_refresh {
@@ -65,7 +215,15 @@ func Run(UI ui.UserInterface, args []string) {
 				newContact = nil // no changes
 			}
 		}
+
+		if rollover {
+			rolloverKey(UI, reg)
+		}
 	} else {
+		if rollover || deactivate {
+			utils.Fatalf("There is no registration %#v", command_base.FlagsStorageRegistrationName)
+		}
+
 		UI.Message("Creating new registration")
 
 		dir, err := controller.GetDirectory(directoryURL, false)
@@ -73,54 +231,43 @@ func Run(UI ui.UserInterface, args []string) {
 			utils.Fatalf("Couldn't fetch directory for '%s': %s", directoryURL, err)
 		}
 
-		UI.Message("Generating private key, might take some time")
-		signingKey, err := types.CreateSigningKey(keyType, curve, &rsabits)
-		if nil != err {
-			utils.Fatalf("Couldn't create private key for registration: %s", err)
+		var signingKey types.SigningKey
+		if 0 != len(signerReference) {
+			signingKey = externalSigningKey()
+		} else {
+			UI.Message("Generating private key, might take some time")
+			if signingKey, err = types.CreateSigningKey(keyType, curve, &rsabits); nil != err {
+				utils.Fatalf("Couldn't create private key for registration: %s", err)
+			}
 		}
 		contact, err := EnterNewContact(UI)
 		if nil != err {
 			utils.Fatalf("Couldn't get contact information for registration: %s", err)
 		}
 
+		// the server might require agreeing to the terms of service
+		// when creating the registration
+		var agreementURL string
+		if agreed := askTermsOfService(UI, dir.Directory().Resource.Meta.TermsOfService, ""); nil != agreed {
+			agreementURL = *agreed
+		}
+		askTos = false
+
 		if password, err := UI.NewPasswordPrompt("Enter new password for account", "Enter password again"); nil != err {
 			utils.Fatalf("Couldn't read new password for storage file: %s", err)
 		} else {
 			st.SetPassword(password)
 		}
 
-		if reg, err = dir.NewRegistration(command_base.FlagsStorageRegistrationName, signingKey, contact); nil != err {
+		if reg, err = dir.NewRegistration(command_base.FlagsStorageRegistrationName, signingKey, contact, agreementURL); nil != err {
 			utils.Fatalf("Couldn't create registration: %s", err)
 		}
 	}
 
 	regData := reg.Registration()
 
-	if 0 != len(regData.LinkTermsOfService) && (show_tos || 0 == len(regData.Resource.AgreementURL)) {
-		if regData.Resource.AgreementURL == regData.LinkTermsOfService {
-			UI.Messagef("The terms of service at %s are marked as already agreed to.", regData.LinkTermsOfService)
-		} else if agree_tos {
-			UI.Messagef("Automatically accepting the terms of service at %s as requested:", regData.LinkTermsOfService)
-			newAgreementURL = &regData.LinkTermsOfService
-		} else {
-			var title string
-			if 0 == len(regData.Resource.AgreementURL) {
-				title = "The server asks for confirmation of the terms of service at %s"
-			} else {
-				title = "There are new terms of service at %s"
-			}
-			ack, err := UI.YesNoDialog(fmt.Sprintf(title, regData.LinkTermsOfService), "", "Agree?", false)
-			if err != nil {
-				utils.Fatalf("Couldn't read acknowledge for terms of service: %s", err)
-			}
-			if ack {
-				newAgreementURL = &regData.LinkTermsOfService
-			} else if 0 == len(regData.Resource.AgreementURL) {
-				utils.Infof("Terms of service not accepted")
-			} else {
-				utils.Infof("New terms of service not accepted")
-			}
-		}
+	if askTos {
+		newAgreementURL = askTermsOfService(UI, regData.LinkTermsOfService, regData.AgreementURL)
 	}
 
 	if err := reg.Update(newContact, newAgreementURL); err != nil {
@@ -130,10 +277,9 @@ func Run(UI ui.UserInterface, args []string) {
 
 	UI.Messagef("Your registration URL is %s", regData.Location)
 	UI.Messagef("Your registered contact information is: %v", regData.Resource.Contact)
-	if 0 != len(regData.Resource.AgreementURL) {
-		UI.Messagef("You agreed to the terms of service at %s", regData.Resource.AgreementURL)
+	if 0 != len(regData.AgreementURL) {
+		UI.Messagef("You agreed to the terms of service at %s", regData.AgreementURL)
 	} else {
 		UI.Messagef("You didn't agree to the terms of service at %s", regData.LinkTermsOfService)
 	}
-	UI.Messagef("Your recovery token is: %s", regData.RecoveryToken)
 }
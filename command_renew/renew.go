@@ -0,0 +1,176 @@
+package command_renew
+
+import (
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/command_base"
+	"github.com/stbuehler/go-acme-client/model"
+	"github.com/stbuehler/go-acme-client/storage_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var renew_flags = flag.NewFlagSet("renew", flag.ExitOnError)
+
+var days int = 30
+var rotateKey bool
+var ignoreARI bool
+var rsabits int = 2048
+var curve utils.Curve = utils.CurveP256
+var keyType utils.KeyType = utils.KeyEcdsa
+
+func init() {
+	renew_flags.IntVar(&days, "days", 30, "Renew certificates expiring within this number of days")
+	renew_flags.BoolVar(&ignoreARI, "ignore-ari", false, "Ignore the renewal window suggested by the server (ACME Renewal Information), only use -days")
+	renew_flags.BoolVar(&rotateKey, "rotate-key", false, "Generate a new private key instead of reusing the stored one")
+	renew_flags.IntVar(&rsabits, "rsa-bits", 2048, "Number of bits to generate the RSA key with (if selected)")
+	renew_flags.Var(&curve, "curve", "Elliptic curve to generate ECDSA key with (if selected), one of P-256, P-384, P-521")
+	renew_flags.Var(&keyType, "key-type", "Key type to generate, RSA, ECDSA or Ed25519")
+	command_base.AddStorageFlags(renew_flags)
+	utils.AddLogFlags(renew_flags)
+}
+
+func namesKey(names []string) string {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, " ")
+}
+
+// only the latest named certificate for a set of names is renewed;
+// imported certificates without a name and old certificates renamed to
+// "<name>#<expires>" are ignored
+func selectLatest(infos []storage_interface.CertificateInfo) []storage_interface.CertificateInfo {
+	latest := make(map[string]int)
+	var keys []string
+	for ndx, info := range infos {
+		if 0 == len(info.Name) || -1 != strings.IndexByte(info.Name, '#') {
+			continue
+		}
+		key := namesKey(command_base.CertificateNames(info.Certificate))
+		if prev, ok := latest[key]; !ok {
+			latest[key] = ndx
+			keys = append(keys, key)
+		} else if info.Certificate.NotAfter.After(infos[prev].Certificate.NotAfter) {
+			latest[key] = ndx
+		}
+	}
+	var result []storage_interface.CertificateInfo
+	for _, key := range keys {
+		result = append(result, infos[latest[key]])
+	}
+	return result
+}
+
+// whether the certificate should be renewed now
+func renewalDue(UI ui.UserInterface, reg model.RegistrationModel, info storage_interface.CertificateInfo, now time.Time) (bool, error) {
+	if cert, err := reg.LoadCertificate(info.Name); nil != err {
+		return false, fmt.Errorf("Loading certificate with name %#v failed: %v", info.Name, err)
+	} else if nil == cert {
+		return false, fmt.Errorf("Certificate with name %#v not found", info.Name)
+	} else {
+		return command_base.RenewalDue(UI, cert, days, !ignoreARI, now), nil
+	}
+}
+
+func renew(UI ui.UserInterface, reg model.RegistrationModel, name string) error {
+	existingCert, err := reg.LoadCertificate(name)
+	if nil != err {
+		return fmt.Errorf("Loading certificate with name %#v failed: %v", name, err)
+	} else if nil == existingCert {
+		return fmt.Errorf("Certificate with name %#v not found", name)
+	}
+	existingData := existingCert.Certificate()
+	names := command_base.CertificateNames(existingData.Certificate)
+
+	for _, domain := range names {
+		if err := command_base.AuthorizeHttp01(UI, reg, domain); nil != err {
+			return err
+		}
+	}
+
+	var privKey interface{}
+	if !rotateKey && nil != existingData.PrivateKey {
+		if privKey, err = utils.DecodePrivateKey(*existingData.PrivateKey); nil != err {
+			return fmt.Errorf("Couldn't decode stored private key of %#v: %v", name, err)
+		}
+	} else {
+		UI.Messagef("Generating private key for certificate %s", name)
+		if privKey, err = utils.CreatePrivateKey(keyType, curve, &rsabits); nil != err {
+			return fmt.Errorf("Couldn't create private key for certificate %s: %v", name, err)
+		}
+	}
+
+	dnsNames, ipAddresses := types.SplitDNSNamesAndIPs(names)
+	csr, err := utils.MakeCertificateRequest(utils.CertificateRequestParameters{
+		PrivateKey:  privKey,
+		DNSNames:    dnsNames,
+		IPAddresses: ipAddresses,
+	})
+	if nil != err {
+		return fmt.Errorf("Couldn't create certificate request: %s", err)
+	}
+
+	utils.Debugf("CSR:\n%s", pem.EncodeToMemory(csr))
+
+	cert, err := command_base.ReplaceCertificate(reg, name, *csr, privKey)
+	if nil != err {
+		return err
+	}
+
+	certData := cert.Certificate()
+	UI.Messagef("Renewed certificate for %s is available at %s (expires in %s)",
+		name, certData.Location, utils.FormatDuration(certData.Certificate.NotAfter.Sub(time.Now())))
+	return nil
+}
+
+func Run(UI ui.UserInterface, args []string) {
+	renew_flags.Parse(args)
+
+	_, _, reg := command_base.OpenStorageFromFlags(UI)
+	if nil == reg {
+		utils.Fatalf("You need to register first")
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range renew_flags.Args() {
+		selected[name] = true
+	}
+
+	infos, err := reg.CertificateInfos()
+	if nil != err {
+		utils.Fatalf("Couldn't list certificates: %s", err)
+	}
+
+	now := time.Now()
+	failed := 0
+	for _, info := range selectLatest(infos) {
+		if 0 != len(selected) && !selected[info.Name] {
+			continue
+		}
+		if due, err := renewalDue(UI, reg, info, now); nil != err {
+			utils.Errorf("%s", err)
+			failed++
+			continue
+		} else if !due {
+			utils.Infof("Certificate %s expires in %s, not renewing", info.Name, utils.FormatDuration(info.Certificate.NotAfter.Sub(time.Now())))
+			continue
+		}
+
+		UI.Messagef("Renewing certificate %s (expires in %s)", info.Name, utils.FormatDuration(info.Certificate.NotAfter.Sub(time.Now())))
+		if err := renew(UI, reg, info.Name); nil != err {
+			utils.Errorf("Renewing certificate %s failed: %s", info.Name, err)
+			failed++
+		}
+	}
+
+	if 0 != failed {
+		utils.Errorf("Renewing %d certificate(s) failed", failed)
+		os.Exit(1)
+	}
+}
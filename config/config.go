@@ -0,0 +1,228 @@
+package config
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	ChallengeHttp01 = "http-01"
+	// don't authorize automatically, require existing valid authorizations
+	ChallengeNone = "none"
+)
+
+// all settings can be given in [defaults] and overwritten per certificate
+type Certificate struct {
+	Name    string   `toml:"name"`
+	Domains []string `toml:"domains"`
+
+	KeyType string `toml:"key-type"`
+	Curve   string `toml:"curve"`
+	RsaBits int    `toml:"rsa-bits"`
+
+	Challenge string `toml:"challenge"`
+	RenewDays int    `toml:"renew-days"`
+
+	// output files; relative paths are relative to the config file, and
+	// "{name}" is replaced with the certificate name
+	CertFile         string `toml:"cert-file"`
+	KeyFile          string `toml:"key-file"`
+	ChainFile        string `toml:"chain-file"`
+	FullchainFile    string `toml:"fullchain-file"`
+	KeyFullchainFile string `toml:"key-fullchain-file"`
+	URLFile          string `toml:"url-file"`
+	// write private keys as PKCS#8 ("PRIVATE KEY")
+	PKCS8 bool `toml:"pkcs8"`
+	// root Common Name or "sha256:<SPKI hash>" to select among the
+	// chains offered by the server
+	PreferredChain string `toml:"preferred-chain"`
+	// the key-fullchain-file uses the key-mode, the url-file the cert-mode
+	CertMode string `toml:"cert-mode"`
+	KeyMode  string `toml:"key-mode"`
+	Owner    string `toml:"owner"`
+	Group    string `toml:"group"`
+
+	// shell commands run before a certificate is requested and after a
+	// new certificate was issued
+	PreHook  string `toml:"pre-hook"`
+	PostHook string `toml:"post-hook"`
+}
+
+type Config struct {
+	Defaults     Certificate   `toml:"defaults"`
+	Certificates []Certificate `toml:"certificate"`
+}
+
+var builtinDefaults = Certificate{
+	KeyType:   string(utils.KeyEcdsa),
+	Curve:     string(utils.CurveP256),
+	RsaBits:   2048,
+	Challenge: ChallengeHttp01,
+	RenewDays: 30,
+	CertMode:  "0644",
+	KeyMode:   "0600",
+}
+
+func (cert *Certificate) applyDefaults(defaults Certificate) {
+	if 0 == len(cert.KeyType) {
+		cert.KeyType = defaults.KeyType
+	}
+	if 0 == len(cert.Curve) {
+		cert.Curve = defaults.Curve
+	}
+	if 0 == cert.RsaBits {
+		cert.RsaBits = defaults.RsaBits
+	}
+	if 0 == len(cert.Challenge) {
+		cert.Challenge = defaults.Challenge
+	}
+	if 0 == cert.RenewDays {
+		cert.RenewDays = defaults.RenewDays
+	}
+	if 0 == len(cert.CertFile) {
+		cert.CertFile = defaults.CertFile
+	}
+	if 0 == len(cert.KeyFile) {
+		cert.KeyFile = defaults.KeyFile
+	}
+	if 0 == len(cert.ChainFile) {
+		cert.ChainFile = defaults.ChainFile
+	}
+	if 0 == len(cert.FullchainFile) {
+		cert.FullchainFile = defaults.FullchainFile
+	}
+	if 0 == len(cert.KeyFullchainFile) {
+		cert.KeyFullchainFile = defaults.KeyFullchainFile
+	}
+	if 0 == len(cert.URLFile) {
+		cert.URLFile = defaults.URLFile
+	}
+	if !cert.PKCS8 {
+		cert.PKCS8 = defaults.PKCS8
+	}
+	if 0 == len(cert.PreferredChain) {
+		cert.PreferredChain = defaults.PreferredChain
+	}
+	if 0 == len(cert.CertMode) {
+		cert.CertMode = defaults.CertMode
+	}
+	if 0 == len(cert.KeyMode) {
+		cert.KeyMode = defaults.KeyMode
+	}
+	if 0 == len(cert.Owner) {
+		cert.Owner = defaults.Owner
+	}
+	if 0 == len(cert.Group) {
+		cert.Group = defaults.Group
+	}
+	if 0 == len(cert.PreHook) {
+		cert.PreHook = defaults.PreHook
+	}
+	if 0 == len(cert.PostHook) {
+		cert.PostHook = defaults.PostHook
+	}
+}
+
+// octal file permissions like "0640"
+func ParseMode(mode string) (os.FileMode, error) {
+	if m, err := strconv.ParseUint(mode, 8, 32); nil != err || m > 0777 {
+		return 0, fmt.Errorf("Invalid file mode %#v", mode)
+	} else {
+		return os.FileMode(m), nil
+	}
+}
+
+func (cert *Certificate) validate() error {
+	if 0 == len(cert.Name) {
+		return fmt.Errorf("Certificate without name")
+	}
+	if 0 == len(cert.Domains) {
+		return fmt.Errorf("Certificate %#v without domains", cert.Name)
+	}
+	for _, domain := range cert.Domains {
+		if types.IsWildcard(domain) && ChallengeHttp01 == cert.Challenge {
+			return fmt.Errorf("Certificate %#v: wildcard name %v can't be authorized with http-01, use challenge = \"none\" and authorize it manually", cert.Name, domain)
+		}
+	}
+	if !utils.KeyType(cert.KeyType).IsValid() {
+		return fmt.Errorf("Certificate %#v: %s %#v", cert.Name, utils.UnknownKeyType, cert.KeyType)
+	}
+	if !utils.Curve(cert.Curve).IsValid() {
+		return fmt.Errorf("Certificate %#v: %s %#v", cert.Name, utils.UnknownCurve, cert.Curve)
+	}
+	if cert.RsaBits < 2048 || cert.RsaBits > 4096 {
+		return fmt.Errorf("Certificate %#v: %s", cert.Name, utils.InvalidRsaBits)
+	}
+	switch cert.Challenge {
+	case ChallengeHttp01, ChallengeNone:
+	default:
+		return fmt.Errorf("Certificate %#v: unsupported challenge %#v (use %s or %s)", cert.Name, cert.Challenge, ChallengeHttp01, ChallengeNone)
+	}
+	if _, err := ParseMode(cert.CertMode); nil != err {
+		return fmt.Errorf("Certificate %#v: %s", cert.Name, err)
+	}
+	if _, err := ParseMode(cert.KeyMode); nil != err {
+		return fmt.Errorf("Certificate %#v: %s", cert.Name, err)
+	}
+	return nil
+}
+
+func (cert *Certificate) CertFileMode() os.FileMode {
+	mode, _ := ParseMode(cert.CertMode)
+	return mode
+}
+
+func (cert *Certificate) KeyFileMode() os.FileMode {
+	mode, _ := ParseMode(cert.KeyMode)
+	return mode
+}
+
+func (cert *Certificate) outputPath(baseDir string, path string) string {
+	if 0 == len(path) {
+		return ""
+	}
+	// no "*" from wildcard names in filenames
+	path = strings.Replace(path, "{name}", strings.Replace(cert.Name, "*", "_", -1), -1)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	return path
+}
+
+func Load(filename string) (*Config, error) {
+	var config Config
+	if meta, err := toml.DecodeFile(filename, &config); nil != err {
+		return nil, fmt.Errorf("Couldn't parse config file %#v: %s", filename, err)
+	} else if undecoded := meta.Undecoded(); 0 != len(undecoded) {
+		return nil, fmt.Errorf("Unknown settings in config file %#v: %v", filename, undecoded)
+	}
+
+	config.Defaults.applyDefaults(builtinDefaults)
+	baseDir := filepath.Dir(filename)
+	names := make(map[string]bool)
+	for ndx := range config.Certificates {
+		cert := &config.Certificates[ndx]
+		cert.applyDefaults(config.Defaults)
+		if err := cert.validate(); nil != err {
+			return nil, err
+		}
+		if names[cert.Name] {
+			return nil, fmt.Errorf("Duplicate certificate name %#v", cert.Name)
+		}
+		names[cert.Name] = true
+		cert.CertFile = cert.outputPath(baseDir, cert.CertFile)
+		cert.KeyFile = cert.outputPath(baseDir, cert.KeyFile)
+		cert.ChainFile = cert.outputPath(baseDir, cert.ChainFile)
+		cert.FullchainFile = cert.outputPath(baseDir, cert.FullchainFile)
+		cert.KeyFullchainFile = cert.outputPath(baseDir, cert.KeyFullchainFile)
+		cert.URLFile = cert.outputPath(baseDir, cert.URLFile)
+	}
+
+	return &config, nil
+}
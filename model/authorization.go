@@ -9,6 +9,8 @@ import (
 
 type AuthorizationModel interface {
 	Refresh() error
+	// give up the authorization; it can't be used for new certificates
+	Deactivate() error
 
 	Authorization() types.Authorization
 
@@ -22,12 +24,24 @@ type authorization struct {
 }
 
 func (auth *authorization) Refresh() error {
-	if newAuth, err := requests.FetchAuthorization(auth.Authorization().Location); nil != err {
+	sreg := auth.reg.sreg
+	if newAuth, err := requests.FetchAuthorization(sreg.Directory(), sreg.Registration(), auth.Authorization().Location); nil != err {
 		return err
 	} else {
 		authData := *auth.sauth.Authorization()
 		authData.Resource = *newAuth
-		return auth.sauth.SetAuthorization(authData)
+		return stored(auth.sauth.SetAuthorization(authData))
+	}
+}
+
+func (auth *authorization) Deactivate() error {
+	sreg := auth.reg.sreg
+	if newAuth, err := requests.DeactivateAuthorization(sreg.Directory(), sreg.Registration(), auth.Authorization().Location); nil != err {
+		return err
+	} else {
+		authData := *auth.sauth.Authorization()
+		authData.Resource = *newAuth
+		return stored(auth.sauth.SetAuthorization(authData))
 	}
 }
 
@@ -38,7 +52,7 @@ func (auth *authorization) Authorization() types.Authorization {
 func (auth *authorization) UpdateChallenge(challengeResponse types.ChallengeResponding) error {
 	if err := auth.SaveChallengeData(challengeResponse); nil != err {
 		return err
-	} else if err := requests.UpdateChallenge(challengeResponse); nil != err {
+	} else if err := requests.UpdateChallenge(auth.reg.sreg.Directory(), challengeResponse); nil != err {
 		return err
 	} else {
 		return auth.Refresh()
@@ -87,7 +101,7 @@ func (reg *registration) importAuthorization(authURL string, refresh bool) (*aut
 		}
 		return authM, nil
 	} else {
-		if newAuth, err := requests.FetchAuthorization(authURL); nil != err {
+		if newAuth, err := requests.FetchAuthorization(reg.sreg.Directory(), reg.sreg.Registration(), authURL); nil != err {
 			return nil, err
 		} else if auth, err := reg.sreg.NewAuthorization(
 			types.Authorization{
@@ -95,6 +109,8 @@ func (reg *registration) importAuthorization(authURL string, refresh bool) (*aut
 				Location: authURL,
 			}); nil != err {
 			return nil, err
+		} else if err := stored(nil); nil != err {
+			return nil, err
 		} else {
 			return &authorization{reg: reg, sauth: auth}, nil
 		}
@@ -135,14 +151,17 @@ func (reg *registration) LoadAuthorizationByURL(authURL string) (AuthorizationMo
 }
 
 func (reg *registration) FetchAllAuthorizations(updateAll bool) error {
-	authUrls, err := requests.FetchAuthorizations(reg.sreg.Registration().Resource.AuthorizationsURL)
+	// there is no list of authorizations; find them through the orders
+	orders, err := reg.fetchAllOrders()
 	if nil != err {
 		return err
 	}
 
-	for _, authURL := range authUrls {
-		if _, err := reg.ImportAuthorizationByURL(authURL, updateAll); nil != err {
-			return err
+	for _, order := range orders {
+		for _, authURL := range order.Resource.Authorizations {
+			if _, err := reg.ImportAuthorizationByURL(authURL, updateAll); nil != err {
+				return err
+			}
 		}
 	}
 	return nil
@@ -180,10 +199,26 @@ func (reg *registration) GetAuthorizationByDNS(dnsIdentifier string, refresh boo
 }
 
 func (reg *registration) NewAuthorization(dnsIdentifier string) (AuthorizationModel, error) {
-	if authData, err := requests.NewDNSAuthorization(reg.sreg.Directory(), reg.sreg.Registration().SigningKey, dnsIdentifier); nil != err {
+	if 0 == len(reg.sreg.Directory().Resource.NewAuthorization) || types.IsWildcard(dnsIdentifier) {
+		// no pre-authorization (not possible for wildcard names); create an
+		// order for the domain instead and use its authorization
+		if order, err := requests.NewOrder(reg.sreg.Directory(), reg.sreg.Registration(), []string{dnsIdentifier}); nil != err {
+			return nil, err
+		} else if err := reg.saveOrder(order); nil != err {
+			return nil, err
+		} else if 1 != len(order.Resource.Authorizations) {
+			return nil, fmt.Errorf("Expected exactly one authorization in order %s, got %d", order.Location, len(order.Resource.Authorizations))
+		} else {
+			return reg.ImportAuthorizationByURL(order.Resource.Authorizations[0], true)
+		}
+	}
+
+	if authData, err := requests.NewAuthorization(reg.sreg.Directory(), reg.sreg.Registration(), dnsIdentifier); nil != err {
 		return nil, err
 	} else if auth, err := reg.sreg.NewAuthorization(*authData); nil != err {
 		return nil, err
+	} else if err := stored(nil); nil != err {
+		return nil, err
 	} else {
 		return &authorization{reg: reg, sauth: auth}, nil
 	}
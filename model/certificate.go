@@ -1,11 +1,13 @@
 package model
 
 import (
+	"crypto/x509"
 	"encoding/pem"
 	"github.com/stbuehler/go-acme-client/requests"
 	"github.com/stbuehler/go-acme-client/storage_interface"
 	"github.com/stbuehler/go-acme-client/types"
 	"github.com/stbuehler/go-acme-client/utils"
+	"time"
 )
 
 type CertificateModel interface {
@@ -18,6 +20,17 @@ type CertificateModel interface {
 	SetName(name string) error
 	SetRevoked(revoked bool) error // this just sets the internal revoked state, it doesn't actually revoke anything
 	SetPrivateKey(privateKey interface{}) error
+
+	// fetch ACME renewal information (unless the last Retry-After hasn't
+	// passed yet and force isn't set); returns nil if the server doesn't
+	// support it
+	UpdateRenewalInfo(force bool) (*types.RenewalInfo, error)
+
+	// issuer chain (without the leaf); fetched (following the "up"
+	// links if the server didn't send it with the certificate) and stored
+	// if not known yet or refresh is set. picks the chain matching the
+	// preferred root (see types.ChainMatches) if there are alternatives.
+	Chain(preferred string, refresh bool) ([]*x509.Certificate, error)
 }
 
 type certificate struct {
@@ -26,10 +39,17 @@ type certificate struct {
 }
 
 func (cert *certificate) Refresh() error {
-	if certData, err := requests.FetchCertificate(cert.Certificate().Location); nil != err {
+	sreg := cert.reg.sreg
+	if certData, err := requests.FetchCertificate(sreg.Directory(), sreg.Registration(), cert.Certificate().Location); nil != err {
 		return err
 	} else {
-		return cert.scert.SetCertificate(*certData)
+		// keep local data
+		oldData := cert.Certificate()
+		certData.Name = oldData.Name
+		certData.Revoked = oldData.Revoked
+		certData.PrivateKey = oldData.PrivateKey
+		certData.RenewalInfo = oldData.RenewalInfo
+		return stored(cert.scert.SetCertificate(*certData))
 	}
 }
 
@@ -44,15 +64,11 @@ func (cert *certificate) Revoke() error {
 	}
 
 	sreg := cert.reg.sreg
-	if err := requests.RevokeCertificate(sreg.Directory(), sreg.Registration().SigningKey, cert.scert.Certificate()); nil != err {
-		return err
-	}
-
-	if err := cert.SetRevoked(true); nil != err {
+	if err := requests.RevokeCertificate(sreg.Directory(), sreg.Registration(), cert.scert.Certificate()); nil != err {
 		return err
 	}
 
-	return nil
+	return stored(cert.SetRevoked(true))
 }
 
 func (cert *certificate) SetName(name string) error {
@@ -91,6 +107,49 @@ func (cert *certificate) SetPrivateKey(privateKey interface{}) error {
 	}
 }
 
+func (cert *certificate) UpdateRenewalInfo(force bool) (*types.RenewalInfo, error) {
+	sreg := cert.reg.sreg
+	certData := cert.Certificate()
+	if 0 == len(sreg.Directory().Resource.RenewalInfo) {
+		return nil, nil
+	} else if !force && nil != certData.RenewalInfo && !certData.RenewalInfo.NeedsUpdate(time.Now()) && !certData.RenewalInfo.RenewalTime.IsZero() {
+		return certData.RenewalInfo, nil
+	} else if info, err := requests.FetchRenewalInfo(sreg.Directory(), certData.Certificate); nil != err {
+		return nil, err
+	} else {
+		info.UpdateRenewalTime(certData.RenewalInfo)
+		certData.RenewalInfo = info
+		if err := stored(cert.scert.SetCertificate(certData)); nil != err {
+			return nil, err
+		}
+		return info, nil
+	}
+}
+
+func (cert *certificate) Chain(preferred string, refresh bool) ([]*x509.Certificate, error) {
+	if certData := cert.Certificate(); !refresh && 0 != len(certData.Chain) {
+		return certData.SelectChain(preferred), nil
+	}
+	if refresh {
+		// RFC 8555 servers send the chain with the certificate
+		if err := cert.Refresh(); nil != err {
+			return nil, err
+		}
+	}
+	certData := cert.Certificate()
+	if 0 == len(certData.Chain) {
+		if chain, err := requests.FetchIssuerChain(&certData); nil != err {
+			return nil, err
+		} else if 0 != len(chain) {
+			certData.Chain = chain
+			if err := stored(cert.scert.SetCertificate(certData)); nil != err {
+				return nil, err
+			}
+		}
+	}
+	return certData.SelectChain(preferred), nil
+}
+
 func (reg *registration) importCertificate(certURL string, refresh bool) (*certificate, error) {
 	if cert, err := reg.sreg.LoadCertificate(certURL); nil != err {
 		return nil, err
@@ -103,10 +162,12 @@ func (reg *registration) importCertificate(certURL string, refresh bool) (*certi
 		}
 		return certM, nil
 	} else {
-		if certData, err := requests.FetchCertificate(certURL); nil != err {
+		if certData, err := requests.FetchCertificate(reg.sreg.Directory(), reg.sreg.Registration(), certURL); nil != err {
 			return nil, err
 		} else if cert, err := reg.sreg.NewCertificate(*certData); nil != err {
 			return nil, err
+		} else if err := stored(nil); nil != err {
+			return nil, err
 		} else {
 			return &certificate{reg: reg, scert: cert}, nil
 		}
@@ -162,13 +223,17 @@ func (reg *registration) LoadCertificate(locationOrName string) (CertificateMode
 }
 
 func (reg *registration) FetchAllCertificates(updateAll bool) error {
-	certUrls, err := requests.FetchCertificates(reg.sreg.Registration().Resource.CertificatesURL)
+	// there is no list of certificates; find them through the orders
+	orders, err := reg.fetchAllOrders()
 	if nil != err {
 		return err
 	}
 
-	for _, certURL := range certUrls {
-		if _, err := reg.ImportCertificate(certURL, updateAll); nil != err {
+	for _, order := range orders {
+		if 0 == len(order.Resource.Certificate) {
+			continue
+		}
+		if _, err := reg.ImportCertificate(order.Resource.Certificate, updateAll); nil != err {
 			return err
 		}
 	}
@@ -185,12 +250,14 @@ func (reg *registration) ImportCertificate(certURL string, refresh bool) (Certif
 }
 
 func (reg *registration) NewCertificate(name string, csr pem.Block) (CertificateModel, error) {
-	if certData, err := requests.NewCertificate(reg.sreg.Directory(), reg.sreg.Registration().SigningKey, csr); nil != err {
+	if certData, err := reg.issueCertificate(csr); nil != err {
 		return nil, err
 	} else {
 		certData.Name = name
 		if cert, err := reg.sreg.NewCertificate(*certData); nil != err {
 			return nil, err
+		} else if err := stored(nil); nil != err {
+			return nil, err
 		} else {
 			return &certificate{reg: reg, scert: cert}, nil
 		}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"github.com/stbuehler/go-acme-client/requests"
 	"github.com/stbuehler/go-acme-client/storage_interface"
 )
 
@@ -14,6 +15,15 @@ type controller struct {
 	storage storage_interface.Storage
 }
 
+// responses replayed from an offline bundle are only dropped after their
+// results were stored
+func stored(err error) error {
+	if nil == err {
+		err = requests.CommitOfflineBundle()
+	}
+	return err
+}
+
 func MakeController(storage storage_interface.Storage) Controller {
 	return &controller{
 		storage: storage,
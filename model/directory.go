@@ -11,7 +11,8 @@ type DirectoryModel interface {
 
 	Directory() types.Directory
 
-	NewRegistration(name string, signingKey types.SigningKey, contact []string) (RegistrationModel, error)
+	// empty agreementURL: didn't agree to terms of service
+	NewRegistration(name string, signingKey types.SigningKey, contact []string, agreementURL string) (RegistrationModel, error)
 }
 
 type directory struct {
@@ -26,7 +27,7 @@ func (dir *directory) Refresh() error {
 	if dirData, err := requests.FetchDirectory(dir.Directory().RootURL); nil != err {
 		return err
 	} else {
-		return dir.sdir.SetDirectory(*dirData)
+		return stored(dir.sdir.SetDirectory(*dirData))
 	}
 }
 
@@ -35,7 +36,8 @@ func (c *controller) getDirectory(rootURL string, refresh bool) (*directory, err
 		return nil, err
 	} else if nil != dir {
 		dirM := &directory{sdir: dir}
-		if refresh {
+		// directories stored by older versions lack the newNonce URL
+		if refresh || 0 == len(dir.Directory().Resource.NewNonce) {
 			if err := dirM.Refresh(); nil != err {
 				return nil, err
 			}
@@ -46,6 +48,8 @@ func (c *controller) getDirectory(rootURL string, refresh bool) (*directory, err
 			return nil, err
 		} else if dir, err := c.storage.NewDirectory(*dirData); nil != err {
 			return nil, err
+		} else if err := stored(nil); nil != err {
+			return nil, err
 		} else {
 			return &directory{sdir: dir}, nil
 		}
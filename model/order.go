@@ -0,0 +1,216 @@
+package model
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/requests"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	"time"
+)
+
+// how often to refresh an order while waiting for the server
+const orderPollRounds = 30
+
+func (reg *registration) fetchAllOrders() ([]types.Order, error) {
+	orderUrls, err := requests.FetchOrderList(reg.sreg.Directory(), reg.sreg.Registration(), reg.sreg.Registration().Resource.OrdersURL)
+	if nil != err {
+		return nil, err
+	}
+
+	orders := []types.Order{}
+	for _, orderURL := range orderUrls {
+		if resource, err := requests.FetchOrder(reg.sreg.Directory(), reg.sreg.Registration(), orderURL); nil != err {
+			return nil, err
+		} else {
+			orders = append(orders, types.Order{
+				Resource: *resource,
+				Location: orderURL,
+			})
+		}
+	}
+	return orders, nil
+}
+
+// store a new order or update the stored copy
+func (reg *registration) saveOrder(order *types.Order) error {
+	if sorder, err := reg.sreg.LoadOrder(order.Location); nil != err {
+		return err
+	} else if nil != sorder {
+		return stored(sorder.SetOrder(*order))
+	} else {
+		_, err := reg.sreg.NewOrder(*order)
+		return stored(err)
+	}
+}
+
+// refresh order while it has the given status
+func (reg *registration) pollOrder(order *types.Order, status types.OrderStatus) error {
+	for i := 0; i < orderPollRounds && status == order.Resource.Status; i++ {
+		time.Sleep(time.Second)
+		if resource, err := requests.FetchOrder(reg.sreg.Directory(), reg.sreg.Registration(), order.Location); nil != err {
+			return err
+		} else {
+			order.Resource = *resource
+		}
+	}
+	return reg.saveOrder(order)
+}
+
+func sameIdentifiers(order *types.Order, names []string) bool {
+	if len(order.Resource.Identifiers) != len(names) {
+		return false
+	}
+	values := make(map[string]bool)
+	for _, name := range names {
+		// normalizes IP addresses
+		values[types.NewIdentifier(name).Value] = true
+	}
+	for _, identifier := range order.Resource.Identifiers {
+		if !values[identifier.Value] {
+			return false
+		}
+	}
+	return true
+}
+
+// find a stored order for the same names which didn't finish yet (for
+// example because the process got interrupted); orders that already got
+// finalized can only be resumed with the same CSR.
+func (reg *registration) findResumableOrder(names []string, csr pem.Block) (*types.Order, error) {
+	orderInfos, err := reg.sreg.OrderInfos()
+	if nil != err {
+		return nil, err
+	}
+
+	for _, orderInfo := range orderInfos {
+		switch orderInfo.Status {
+		case "pending", "ready", "processing", "valid":
+		default:
+			continue
+		}
+		if nil != orderInfo.Expires && orderInfo.Expires.Before(time.Now()) {
+			continue
+		}
+
+		sorder, err := reg.sreg.LoadOrder(orderInfo.Location)
+		if nil != err {
+			return nil, err
+		} else if nil == sorder {
+			continue
+		}
+		order := *sorder.Order()
+		if !sameIdentifiers(&order, names) {
+			continue
+		}
+
+		if resource, err := requests.FetchOrder(reg.sreg.Directory(), reg.sreg.Registration(), order.Location); nil != err {
+			utils.Debugf("Couldn't refresh order %s: %s", order.Location, err)
+			continue
+		} else {
+			order.Resource = *resource
+		}
+		if err := stored(sorder.SetOrder(order)); nil != err {
+			return nil, err
+		}
+
+		switch order.Resource.Status {
+		case "pending", "ready":
+			return &order, nil
+		case "processing", "valid":
+			if !bytes.Equal(order.CSR, csr.Bytes) {
+				continue
+			}
+			if 0 != len(order.Resource.Certificate) {
+				// skip if the certificate was already imported
+				if scert, err := reg.sreg.LoadCertificate(order.Resource.Certificate); nil != err {
+					return nil, err
+				} else if nil != scert {
+					continue
+				}
+			}
+			return &order, nil
+		}
+	}
+	return nil, nil
+}
+
+// all authorizations need to be valid before an order can be finalized;
+// this doesn't try to complete any challenges.
+func (reg *registration) checkOrderAuthorizations(order *types.Order) error {
+	for _, authURL := range order.Resource.Authorizations {
+		if auth, err := reg.importAuthorization(authURL, true); nil != err {
+			return err
+		} else if authData := auth.Authorization(); "valid" != authData.Resource.Status {
+			return fmt.Errorf("Authorization for %s is not valid (%s), authorize it first", authData.Name(), authData.Resource.Status)
+		}
+	}
+	return nil
+}
+
+func (reg *registration) issueCertificate(csr pem.Block) (*types.Certificate, error) {
+	certReq, err := x509.ParseCertificateRequest(csr.Bytes)
+	if nil != err {
+		return nil, fmt.Errorf("Couldn't parse certificate request: %s", err)
+	}
+
+	names := certReq.DNSNames
+	for _, ip := range certReq.IPAddresses {
+		names = append(names, ip.String())
+	}
+
+	order, err := reg.findResumableOrder(names, csr)
+	if nil != err {
+		return nil, err
+	} else if nil != order {
+		utils.Infof("Resuming order %s (%s)", order.Location, order.Resource.Status)
+	} else {
+		if order, err = requests.NewOrder(reg.sreg.Directory(), reg.sreg.Registration(), names); nil != err {
+			return nil, err
+		}
+		utils.Debugf("Created order %s", order.Location)
+		if err := reg.saveOrder(order); nil != err {
+			return nil, err
+		}
+	}
+
+	if "pending" == order.Resource.Status || "ready" == order.Resource.Status {
+		if err := reg.checkOrderAuthorizations(order); nil != err {
+			return nil, err
+		}
+		if err := reg.pollOrder(order, "pending"); nil != err {
+			return nil, err
+		}
+		if "ready" != order.Resource.Status {
+			if nil != order.Resource.Error {
+				return nil, order.Resource.Error
+			}
+			return nil, fmt.Errorf("Order %s is not ready for finalization: %s", order.Location, order.Resource.Status)
+		}
+
+		// remember the CSR before finalizing, so the order can be resumed
+		// with the same key
+		order.CSR = csr.Bytes
+		if err := reg.saveOrder(order); nil != err {
+			return nil, err
+		}
+		if resource, err := requests.FinalizeOrder(reg.sreg.Directory(), reg.sreg.Registration(), order, csr); nil != err {
+			return nil, err
+		} else {
+			order.Resource = *resource
+		}
+	}
+	if err := reg.pollOrder(order, "processing"); nil != err {
+		return nil, err
+	}
+	if "valid" != order.Resource.Status || 0 == len(order.Resource.Certificate) {
+		if nil != order.Resource.Error {
+			return nil, order.Resource.Error
+		}
+		return nil, fmt.Errorf("Order %s didn't result in a certificate: %s", order.Location, order.Resource.Status)
+	}
+
+	return requests.FetchCertificate(reg.sreg.Directory(), reg.sreg.Registration(), order.Resource.Certificate)
+}
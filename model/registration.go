@@ -12,6 +12,12 @@ type RegistrationModel interface {
 	Registration() types.Registration
 	Refresh() error
 	Update(contact []string, AgreementURL *string) error
+	RolloverKey(newKey types.SigningKey) error
+	// deactivate registration on the server; it can't be used afterwards
+	Deactivate() error
+	// delete registration with all its authorizations, certificates and
+	// orders from local storage
+	Delete() error
 
 	AuthorizationInfos() (storage_interface.AuthorizationInfos, error)
 	AuthorizationInfosWithStatus(status types.AuthorizationStatus) (storage_interface.AuthorizationInfos, error)
@@ -43,10 +49,10 @@ func (reg *registration) Registration() types.Registration {
 }
 
 func (reg *registration) Refresh() error {
-	if newReg, err := requests.FetchRegistration(reg.sreg.Registration()); nil != err {
+	if newReg, err := requests.FetchRegistration(reg.sreg.Directory(), reg.sreg.Registration()); nil != err {
 		return err
 	} else {
-		return reg.sreg.SetRegistration(*newReg)
+		return stored(reg.sreg.SetRegistration(*newReg))
 	}
 }
 
@@ -61,24 +67,64 @@ func (reg *registration) Update(contact []string, AgreementURL *string) error {
 		newData.Resource.Contact = contact
 	}
 	if nil != AgreementURL {
-		newData.Resource.AgreementURL = *AgreementURL
+		newData.AgreementURL = *AgreementURL
 	}
 
-	if newReg, err := requests.UpdateRegistration(&newData); nil != err {
+	if newReg, err := requests.UpdateRegistration(reg.sreg.Directory(), &newData); nil != err {
 		return err
 	} else {
-		return reg.sreg.SetRegistration(*newReg)
+		return stored(reg.sreg.SetRegistration(*newReg))
 	}
 }
 
-func (dir *directory) newRegistration(name string, signingKey types.SigningKey, contact []string) (*registration, error) {
+func (reg *registration) RolloverKey(newKey types.SigningKey) error {
+	// make sure the registration can be saved before the server switches
+	// to the new key; losing it would lock us out of the account.
+	if err := reg.sreg.SetRegistration(*reg.sreg.Registration()); nil != err {
+		return err
+	}
+
+	if newReg, err := requests.KeyChange(reg.sreg.Directory(), reg.sreg.Registration(), newKey); nil != err {
+		return err
+	} else if err := reg.sreg.SetRegistration(*newReg); nil != err {
+		return &KeyNotStoredError{NewKey: newKey, Err: err}
+	}
+	return stored(nil)
+}
+
+// the server switched to the new key, but it couldn't be stored; the caller
+// must save NewKey some other way, the old key isn't valid anymore.
+type KeyNotStoredError struct {
+	NewKey types.SigningKey
+	Err    error
+}
+
+func (e *KeyNotStoredError) Error() string {
+	return fmt.Sprintf("The server switched to the new key, but storing it failed: %s", e.Err)
+}
+
+func (reg *registration) Deactivate() error {
+	if newReg, err := requests.DeactivateRegistration(reg.sreg.Directory(), reg.sreg.Registration()); nil != err {
+		return err
+	} else {
+		// some servers might not return the new status
+		newReg.Resource.Status = "deactivated"
+		return stored(reg.sreg.SetRegistration(*newReg))
+	}
+}
+
+func (reg *registration) Delete() error {
+	return reg.sreg.Delete()
+}
+
+func (dir *directory) newRegistration(name string, signingKey types.SigningKey, contact []string, agreementURL string) (*registration, error) {
 	if reg, err := dir.sdir.Storage().LoadRegistration(name); nil != err {
 		return nil, err
 	} else if nil != reg {
 		return nil, fmt.Errorf("There already is a registration with name %#v", name)
 	}
 
-	reg, err := requests.NewRegistration(dir.sdir.Directory(), signingKey, contact)
+	reg, err := requests.NewRegistration(dir.sdir.Directory(), signingKey, contact, agreementURL)
 	if nil != err {
 		return nil, err
 	}
@@ -86,6 +132,8 @@ func (dir *directory) newRegistration(name string, signingKey types.SigningKey,
 
 	if sreg, err := dir.sdir.NewRegistration(*reg); nil != err || nil == sreg {
 		return nil, err
+	} else if err := stored(nil); nil != err {
+		return nil, err
 	} else {
 		return &registration{
 			dir:  dir,
@@ -94,8 +142,8 @@ func (dir *directory) newRegistration(name string, signingKey types.SigningKey,
 	}
 }
 
-func (dir *directory) NewRegistration(name string, signingKey types.SigningKey, contact []string) (RegistrationModel, error) {
-	if reg, err := dir.newRegistration(name, signingKey, contact); nil != err || nil == reg {
+func (dir *directory) NewRegistration(name string, signingKey types.SigningKey, contact []string, agreementURL string) (RegistrationModel, error) {
+	if reg, err := dir.newRegistration(name, signingKey, contact, agreementURL); nil != err || nil == reg {
 		// make sure to create a nil interface from the nil pointer!
 		return nil, err
 	} else {
@@ -107,8 +155,15 @@ func (c *controller) LoadRegistration(name string) (RegistrationModel, error) {
 	if sreg, err := c.storage.LoadRegistration(name); nil != err || nil == sreg {
 		return nil, err
 	} else if nil != sreg {
+		dir := &directory{sdir: sreg.StorageDirectory()}
+		// directories stored by older versions lack the newNonce URL
+		if 0 == len(dir.Directory().Resource.NewNonce) {
+			if err := dir.Refresh(); nil != err {
+				return nil, err
+			}
+		}
 		return &registration{
-			dir:  &directory{sdir: sreg.StorageDirectory()},
+			dir:  dir,
 			sreg: sreg,
 		}, nil
 	} else {
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"fmt"
+	"github.com/stbuehler/go-acme-client/storage_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// only implements what RolloverKey needs; saving fails after the first
+// saveLimit calls
+type rolloverTestStorage struct {
+	storage_interface.StorageRegistration
+	directory    types.Directory
+	registration types.Registration
+	saveLimit    int
+	saves        int
+}
+
+func (s *rolloverTestStorage) Directory() *types.Directory {
+	return &s.directory
+}
+
+func (s *rolloverTestStorage) Registration() *types.Registration {
+	return &s.registration
+}
+
+func (s *rolloverTestStorage) SetRegistration(registration types.Registration) error {
+	if s.saves >= s.saveLimit {
+		return fmt.Errorf("storage broken")
+	}
+	s.saves++
+	s.registration = registration
+	return nil
+}
+
+func rolloverTestServer(t *testing.T) (*httptest.Server, *int) {
+	keyChanges := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce")
+		switch r.URL.Path {
+		case "/new-nonce":
+		case "/key-change":
+			keyChanges++
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, &keyChanges
+}
+
+func newRolloverTest(t *testing.T, serverURL string, saveLimit int) (*rolloverTestStorage, types.SigningKey) {
+	oldKey, err := types.CreateSigningKey(utils.KeyEcdsa, utils.CurveP256, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	newKey, err := types.CreateSigningKey(utils.KeyEcdsa, utils.CurveP256, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	sreg := &rolloverTestStorage{
+		directory: types.Directory{
+			RootURL: serverURL + "/directory",
+			Resource: types.DirectoryResource{
+				NewNonce:  serverURL + "/new-nonce",
+				KeyChange: serverURL + "/key-change",
+			},
+		},
+		registration: types.Registration{
+			Location:   serverURL + "/account/1",
+			SigningKey: oldKey,
+		},
+		saveLimit: saveLimit,
+	}
+	return sreg, newKey
+}
+
+func TestRolloverKey(t *testing.T) {
+	server, keyChanges := rolloverTestServer(t)
+	defer server.Close()
+	sreg, newKey := newRolloverTest(t, server.URL, 2)
+
+	reg := &registration{sreg: sreg}
+	if err := reg.RolloverKey(newKey); nil != err {
+		t.Fatalf("RolloverKey failed: %s", err)
+	}
+	if 1 != *keyChanges {
+		t.Errorf("Expected one key change request, got %d", *keyChanges)
+	}
+	if sreg.registration.SigningKey.GetPublicKey().Key != newKey.GetPublicKey().Key {
+		t.Errorf("New key wasn't stored")
+	}
+}
+
+func TestRolloverKeyStorageBrokenBefore(t *testing.T) {
+	server, keyChanges := rolloverTestServer(t)
+	defer server.Close()
+	sreg, newKey := newRolloverTest(t, server.URL, 0)
+
+	reg := &registration{sreg: sreg}
+	if err := reg.RolloverKey(newKey); nil == err {
+		t.Fatalf("RolloverKey should fail")
+	} else if _, ok := err.(*KeyNotStoredError); ok {
+		t.Errorf("Key shouldn't have been changed: %s", err)
+	}
+	if 0 != *keyChanges {
+		t.Errorf("Key change must not be sent if the storage can't save")
+	}
+}
+
+func TestRolloverKeyStorageBrokenAfter(t *testing.T) {
+	server, keyChanges := rolloverTestServer(t)
+	defer server.Close()
+	sreg, newKey := newRolloverTest(t, server.URL, 1)
+
+	reg := &registration{sreg: sreg}
+	err := reg.RolloverKey(newKey)
+	if 1 != *keyChanges {
+		t.Errorf("Expected one key change request, got %d", *keyChanges)
+	}
+	notStored, ok := err.(*KeyNotStoredError)
+	if !ok {
+		t.Fatalf("Expected a KeyNotStoredError, got %v", err)
+	}
+	if notStored.NewKey.GetPublicKey().Key != newKey.GetPublicKey().Key {
+		t.Errorf("KeyNotStoredError doesn't carry the new key")
+	}
+	if _, err := notStored.NewKey.ExportPem("", utils.PemDefaultCipher); nil != err {
+		t.Errorf("Couldn't export the new key: %s", err)
+	}
+}
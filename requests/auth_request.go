@@ -4,30 +4,60 @@ import (
 	"fmt"
 	"github.com/stbuehler/go-acme-client/types"
 	"github.com/stbuehler/go-acme-client/utils"
-	"net/http"
 )
 
-func RunSignedRequest(signingKey types.SigningKey, req *utils.HttpRequest, payloadJson []byte) (*utils.HttpResponse, error) {
-	var nonce string
-	{
-		nonceResp, err := http.Head(req.URL)
+const contentTypeJoseJson = "application/jose+json"
+
+// keyID should be the registration URL; only requests creating a new
+// registration (or not associated with any registration) embed the public
+// key instead (empty keyID).
+func RunSignedRequest(directory *types.Directory, signingKey types.SigningKey, keyID string, req *utils.HttpRequest, payloadJson []byte) (*utils.HttpResponse, error) {
+	utils.Debugf("sending to %s signed payload: %s\n", req.URL, string(payloadJson))
+	req.Headers.ContentType = contentTypeJoseJson
+
+	if nil != offlineBundle {
+		return offlineBundle.runSignedRequest(directory, signingKey, keyID, req, payloadJson)
+	}
+
+	// retry once with a new nonce if the server didn't like the old one
+	for try := 0; ; try++ {
+		nonce, err := getNonce(directory)
 		if nil != err {
 			return nil, err
 		}
-		defer nonceResp.Body.Close()
 
-		nonce = nonceResp.Header.Get("Replay-Nonce")
-	}
-	if 0 == len(nonce) {
-		return nil, fmt.Errorf("Didn't get a Replay-Nonce header")
+		sig, err := signingKey.SignRequest(payloadJson, nonce, req.URL, keyID)
+		if nil != err {
+			return nil, err
+		}
+		req.Body = []byte(sig.FullSerialize())
+
+		resp, err := runRequest(req)
+		saveNonce(directory, resp)
+		if 0 == try && isBadNonce(err) {
+			utils.Debugf("Server rejected nonce, retrying request to %s", req.URL)
+			continue
+		}
+		return resp, err
 	}
+}
 
-	sig, err := signingKey.Sign(payloadJson, nonce)
-	if nil != err {
-		return nil, err
+// send a signed request with the registration URL as key id
+func runRegistrationRequest(directory *types.Directory, registration *types.Registration, req *utils.HttpRequest, payloadJson []byte) (*utils.HttpResponse, error) {
+	if 0 == len(registration.Location) {
+		return nil, fmt.Errorf("Registration has no location")
 	}
-	utils.Debugf("sending to %s signed payload: %s\n", req.URL, string(payloadJson))
-	req.Body = []byte(sig.FullSerialize())
+	return RunSignedRequest(directory, registration.SigningKey, registration.Location, req, payloadJson)
+}
 
-	return req.Run()
+// "POST-as-GET": signed POST request with an empty payload
+func runPostAsGet(directory *types.Directory, registration *types.Registration, url string, accept string) (*utils.HttpResponse, error) {
+	req := utils.HttpRequest{
+		Method: "POST",
+		URL:    url,
+		Headers: utils.HttpRequestHeader{
+			Accept: accept,
+		},
+	}
+	return runRegistrationRequest(directory, registration, &req, []byte{})
 }
@@ -8,13 +8,13 @@ import (
 )
 
 type newAuthorization struct {
-	Resource      types.ResourceNewAuthorizationTag `json:"resource"`
-	DNSIdentifier types.DNSIdentifier               `json:"identifier,omitempty"`
+	Identifier types.Identifier `json:"identifier,omitempty"`
 }
 
-func NewDNSAuthorization(directory *types.Directory, signingKey types.SigningKey, domain string) (*types.Authorization, error) {
+// pre-authorization; only available if the directory has a newAuthz URL
+func NewAuthorization(directory *types.Directory, registration *types.Registration, domain string) (*types.Authorization, error) {
 	payload := newAuthorization{
-		DNSIdentifier: types.DNSIdentifier(domain),
+		Identifier: types.NewIdentifier(domain),
 	}
 
 	payloadJson, err := json.Marshal(payload)
@@ -23,17 +23,17 @@ func NewDNSAuthorization(directory *types.Directory, signingKey types.SigningKey
 	}
 
 	url := directory.Resource.NewAuthorization
+	if 0 == len(url) {
+		return nil, fmt.Errorf("Directory %s doesn't support pre-authorization", directory.RootURL)
+	}
 	req := utils.HttpRequest{
 		Method: "POST",
 		URL:    url,
-		Headers: utils.HttpRequestHeader{
-			ContentType: "application/json",
-		},
 	}
 
-	resp, err := RunSignedRequest(signingKey, &req, payloadJson)
+	resp, err := runRegistrationRequest(directory, registration, &req, payloadJson)
 	if nil != err {
-		return nil, fmt.Errorf("POST authorization %s to %s failed: %s", string(payloadJson), url, err)
+		return nil, requestFailed(err, "POST authorization %s to %s failed: %s", string(payloadJson), url, err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
@@ -55,25 +55,53 @@ func NewDNSAuthorization(directory *types.Directory, signingKey types.SigningKey
 	return &response, nil
 }
 
-func FetchAuthorization(authURL string) (*types.AuthorizationResource, error) {
+func FetchAuthorization(directory *types.Directory, registration *types.Registration, authURL string) (*types.AuthorizationResource, error) {
+	resp, err := runPostAsGet(directory, registration, authURL, "")
+	if nil != err {
+		return nil, requestFailed(err, "Refreshing authorization %s failed: %s", authURL, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("POST-as-GET %s failed: %s", authURL, resp.Status)
+	}
+
+	var response types.AuthorizationResource
+	err = json.Unmarshal(resp.Body, &response)
+	if nil != err {
+		return nil, fmt.Errorf("Failed decoding response from POST-as-GET %s: %s", authURL, err)
+	}
+
+	return &response, nil
+}
+
+func DeactivateAuthorization(directory *types.Directory, registration *types.Registration, authURL string) (*types.AuthorizationResource, error) {
+	payloadJson, err := json.Marshal(struct {
+		Status string `json:"status"`
+	}{
+		Status: "deactivated",
+	})
+	if nil != err {
+		return nil, err
+	}
+
 	req := utils.HttpRequest{
-		Method: "GET",
+		Method: "POST",
 		URL:    authURL,
 	}
 
-	resp, err := req.Run()
+	resp, err := runRegistrationRequest(directory, registration, &req, payloadJson)
 	if nil != err {
-		return nil, fmt.Errorf("Refreshing authorization %s failed: %s", authURL, err)
+		return nil, requestFailed(err, "POST %s to %s failed: %s", string(payloadJson), authURL, err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("GET %s failed: %s", authURL, resp.Status)
+		return nil, fmt.Errorf("POST %s to %s failed: %s", string(payloadJson), authURL, resp.Status)
 	}
 
 	var response types.AuthorizationResource
 	err = json.Unmarshal(resp.Body, &response)
 	if nil != err {
-		return nil, fmt.Errorf("Failed decoding response from GET %s: %s", authURL, err)
+		return nil, fmt.Errorf("Failed decoding response from POST %s to %s: %s", string(payloadJson), authURL, err)
 	}
 
 	return &response, nil
@@ -1,68 +1,135 @@
 package requests
 
 import (
+	"bytes"
 	"crypto/x509"
 	"encoding/json"
-	"encoding/pem"
+	"errors"
 	"fmt"
 	"github.com/stbuehler/go-acme-client/types"
 	"github.com/stbuehler/go-acme-client/utils"
+	"strings"
 )
 
-type newCertificate struct {
-	Resource types.ResourceNewCertificateTag `json:"resource"`
-	CSR      string                          `json:"csr"`
-}
+const contentTypePemCertificateChain = "application/pem-certificate-chain"
 
-func NewCertificate(directory *types.Directory, signingKey types.SigningKey, csr pem.Block) (*types.Certificate, error) {
-	payload := newCertificate{
-		CSR: utils.Base64UrlEncode(csr.Bytes),
+func linkIssuer(resp *utils.HttpResponse, cert *x509.Certificate) string {
+	if link := resp.Link("up"); 0 != len(link) {
+		return link
 	}
-
-	payloadJson, err := json.Marshal(payload)
-	if nil != err {
-		return nil, err
+	// RFC 8555 servers include the chain in the response; fall back to the
+	// "CA Issuers" URL from the certificate itself
+	if 0 != len(cert.IssuingCertificateURL) {
+		return cert.IssuingCertificateURL[0]
 	}
+	return ""
+}
 
-	url := directory.Resource.NewCertificate
-	req := utils.HttpRequest{
-		Method: "POST",
-		URL:    url,
-		Headers: utils.HttpRequestHeader{
-			ContentType: "application/json",
-			Accept:      "application/pkix-cert",
-		},
-	}
-	resp, err := RunSignedRequest(signingKey, &req, payloadJson)
+// signed POST-as-GET request for a certificate with its chain
+func fetchCertificateChain(directory *types.Directory, registration *types.Registration, certURL string) (*utils.HttpResponse, []*x509.Certificate, error) {
+	resp, err := runPostAsGet(directory, registration, certURL, contentTypePemCertificateChain)
 	if nil != err {
-		return nil, fmt.Errorf("POST certificate request %s to %s failed: %s", string(payloadJson), url, err)
+		return nil, nil, requestFailed(err, "Fetching certificate %s failed: %s", certURL, err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("POST certificate request %s to %s failed: %s", string(payloadJson), url, resp.Status)
+		return nil, nil, fmt.Errorf("POST-as-GET %s failed: %s", certURL, resp.Status)
 	}
 
-	if 0 == len(resp.Location) {
-		return nil, fmt.Errorf("Requesting certificate failed: missing Location")
+	if contentTypePemCertificateChain != strings.TrimSpace(strings.Split(resp.ContentType, ";")[0]) {
+		return nil, nil, fmt.Errorf("Unexpected response Content-Type: %s, expected %s", resp.ContentType, contentTypePemCertificateChain)
 	}
 
-	if "application/pkix-cert" != resp.ContentType {
-		return nil, fmt.Errorf("Unexpected response Content-Type: %s, expected application/pkix-cert", resp.ContentType)
+	// the first certificate is the end-entity certificate, followed by
+	// the issuer chain
+	certs, err := utils.ParseCertificatesPem(resp.Body)
+	if nil != err {
+		return nil, nil, fmt.Errorf("Couldn't parse returned certificate: %s", err)
+	} else if 0 == len(certs) {
+		return nil, nil, fmt.Errorf("Couldn't find certificate in response")
 	}
 
-	cert, err := x509.ParseCertificate(resp.Body)
+	return resp, certs, nil
+}
+
+// download an issued certificate, including all chains the server offers
+func FetchCertificate(directory *types.Directory, registration *types.Registration, certURL string) (*types.Certificate, error) {
+	resp, certs, err := fetchCertificateChain(directory, registration, certURL)
 	if nil != err {
-		return nil, fmt.Errorf("Couldn't parse returned certificate: %s", err)
+		return nil, err
 	}
 
-	return &types.Certificate{
-		Location:    resp.Location,
-		LinkIssuer:  resp.Links["up"].URL,
-		Certificate: cert,
-	}, nil
+	cert := &types.Certificate{
+		Location:    certURL,
+		LinkIssuer:  linkIssuer(resp, certs[0]),
+		Certificate: certs[0],
+		Chain:       certs[1:],
+	}
+
+	// RFC 8555 7.4.2: alternate chains for the same certificate; failing
+	// to get those is not fatal, but in offline mode the certificate is
+	// only returned (and stored) once the alternate chains were fetched too
+	var queued *OfflineQueuedError
+	for _, link := range resp.Links["alternate"] {
+		if _, altCerts, err := fetchCertificateChain(directory, registration, link.URL); errors.As(err, &queued) {
+			continue
+		} else if nil != err {
+			utils.Warningf("Couldn't fetch alternate chain %s: %s", link.URL, err)
+		} else if !bytes.Equal(altCerts[0].Raw, cert.Certificate.Raw) {
+			utils.Warningf("Alternate chain %s is for a different certificate, ignoring", link.URL)
+		} else {
+			cert.AlternateChains = append(cert.AlternateChains, altCerts[1:])
+		}
+	}
+	if nil != queued {
+		return nil, queued
+	}
+
+	return cert, nil
 }
 
-func FetchCertificate(certURL string) (*types.Certificate, error) {
+// longest chain we follow "up" links for
+const maxChainLength = 10
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && nil == cert.CheckSignatureFrom(cert)
+}
+
+// fetch the issuer chain by following the "up" links, starting with the
+// issuer URL of the certificate; stops at a self-signed (root) certificate,
+// which isn't included.
+func FetchIssuerChain(certificate *types.Certificate) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	linkIssuer := certificate.LinkIssuer
+	previous := certificate.Certificate
+	visited := make(map[string]bool)
+	for 0 != len(linkIssuer) {
+		if visited[linkIssuer] {
+			return nil, fmt.Errorf("Loop in issuer chain at %s", linkIssuer)
+		} else if len(chain) >= maxChainLength {
+			return nil, fmt.Errorf("Issuer chain too long (more than %d certificates)", maxChainLength)
+		}
+		visited[linkIssuer] = true
+
+		issuer, err := FetchIssuerCertificate(linkIssuer)
+		if nil != err {
+			return nil, err
+		}
+		if err := previous.CheckSignatureFrom(issuer.Certificate); nil != err {
+			return nil, fmt.Errorf("Certificate from %s didn't issue the previous certificate in the chain: %s", linkIssuer, err)
+		}
+		if isSelfSigned(issuer.Certificate) {
+			break
+		}
+		chain = append(chain, issuer.Certificate)
+		previous = issuer.Certificate
+		linkIssuer = issuer.LinkIssuer
+	}
+	return chain, nil
+}
+
+// issuer certificates are available through simple GET requests (DER encoded)
+func FetchIssuerCertificate(certURL string) (*types.Certificate, error) {
 	req := utils.HttpRequest{
 		Method: "GET",
 		URL:    certURL,
@@ -71,16 +138,16 @@ func FetchCertificate(certURL string) (*types.Certificate, error) {
 		},
 	}
 
-	resp, err := req.Run()
+	resp, err := runRequest(&req)
 	if nil != err {
-		return nil, fmt.Errorf("Fetching certificate %s failed: %s", certURL, err)
+		return nil, requestFailed(err, "Fetching certificate %s failed: %s", certURL, err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return nil, fmt.Errorf("GET %s failed: %s", certURL, resp.Status)
 	}
 
-	if "application/pkix-cert" != resp.ContentType {
+	if "application/pkix-cert" != strings.TrimSpace(strings.Split(resp.ContentType, ";")[0]) {
 		return nil, fmt.Errorf("Unexpected response Content-Type: %s, expected application/pkix-cert", resp.ContentType)
 	}
 
@@ -91,17 +158,16 @@ func FetchCertificate(certURL string) (*types.Certificate, error) {
 
 	return &types.Certificate{
 		Location:    certURL,
-		LinkIssuer:  resp.Links["up"].URL,
+		LinkIssuer:  linkIssuer(resp, cert),
 		Certificate: cert,
 	}, nil
 }
 
 type revokeCertificate struct {
-	Resource    types.ResourceRevokeCertificateTag `json:"resource"`
-	Certificate string                             `json:"certificate"`
+	Certificate string `json:"certificate"`
 }
 
-func RevokeCertificate(directory *types.Directory, signingKey types.SigningKey, certificate *types.Certificate) error {
+func RevokeCertificate(directory *types.Directory, registration *types.Registration, certificate *types.Certificate) error {
 	payload := revokeCertificate{
 		Certificate: utils.Base64UrlEncode(certificate.Certificate.Raw),
 	}
@@ -115,13 +181,10 @@ func RevokeCertificate(directory *types.Directory, signingKey types.SigningKey,
 	req := utils.HttpRequest{
 		Method: "POST",
 		URL:    url,
-		Headers: utils.HttpRequestHeader{
-			ContentType: "application/json",
-		},
 	}
-	resp, err := RunSignedRequest(signingKey, &req, payloadJson)
+	resp, err := runRegistrationRequest(directory, registration, &req, payloadJson)
 	if nil != err {
-		return fmt.Errorf("POST revoke certificate %s to %s failed: %s", string(payloadJson), url, err)
+		return requestFailed(err, "POST revoke certificate %s to %s failed: %s", string(payloadJson), url, err)
 	}
 
 	if resp.StatusCode != 200 {
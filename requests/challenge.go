@@ -7,7 +7,7 @@ import (
 	"github.com/stbuehler/go-acme-client/utils"
 )
 
-func UpdateChallenge(challengeResponse types.ChallengeResponding) error {
+func UpdateChallenge(directory *types.Directory, challengeResponse types.ChallengeResponding) error {
 	challenge := challengeResponse.Challenge()
 	payload, err := challengeResponse.SendPayload()
 	if nil != err {
@@ -23,14 +23,11 @@ func UpdateChallenge(challengeResponse types.ChallengeResponding) error {
 	req := utils.HttpRequest{
 		Method: "POST",
 		URL:    uri,
-		Headers: utils.HttpRequestHeader{
-			ContentType: "application/json",
-		},
 	}
 
-	resp, err := RunSignedRequest(challengeResponse.Registration().SigningKey, &req, payloadJson)
+	resp, err := runRegistrationRequest(directory, challengeResponse.Registration(), &req, payloadJson)
 	if nil != err {
-		return fmt.Errorf("POST %s to %s failed: %s", string(payloadJson), uri, err)
+		return requestFailed(err, "POST %s to %s failed: %s", string(payloadJson), uri, err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
@@ -13,9 +13,9 @@ func FetchDirectory(rootURL string) (*types.Directory, error) {
 		URL:    rootURL,
 	}
 
-	resp, err := req.Run()
+	resp, err := runRequest(&req)
 	if nil != err {
-		return nil, fmt.Errorf("Retrieving directory %s failed: %s", rootURL, err)
+		return nil, requestFailed(err, "Retrieving directory %s failed: %s", rootURL, err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
@@ -28,5 +28,6 @@ func FetchDirectory(rootURL string) (*types.Directory, error) {
 		return nil, fmt.Errorf("Failed decoding response from GET %s: %s", rootURL, err)
 	}
 	response.RootURL = rootURL
+	saveNonce(&response, resp)
 	return &response, nil
 }
@@ -0,0 +1,57 @@
+package requests
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+type keyChange struct {
+	Account string           `json:"account"`
+	OldKey  *jose.JSONWebKey `json:"oldKey"`
+}
+
+// returns a copy of the registration with the new key; the old key is no
+// longer valid on success.
+func KeyChange(directory *types.Directory, registration *types.Registration, newKey types.SigningKey) (*types.Registration, error) {
+	url := directory.Resource.KeyChange
+	if 0 == len(url) {
+		return nil, fmt.Errorf("Directory %s doesn't support key rollover", directory.RootURL)
+	}
+
+	innerJson, err := json.Marshal(keyChange{
+		Account: registration.Location,
+		OldKey:  registration.SigningKey.GetPublicKey(),
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	// the inner JWS is signed with the new key, the outer one (as usual) with
+	// the old key
+	innerSig, err := newKey.SignKeyChange(innerJson, url)
+	if nil != err {
+		return nil, err
+	}
+	payloadJson := []byte(innerSig.FullSerialize())
+
+	req := utils.HttpRequest{
+		Method: "POST",
+		URL:    url,
+	}
+
+	resp, err := runRegistrationRequest(directory, registration, &req, payloadJson)
+	if nil != err {
+		return nil, requestFailed(err, "POST key change %s to %s failed: %s", string(innerJson), url, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("POST key change %s to %s failed: %s", string(innerJson), url, resp.Status)
+	}
+
+	newReg := *registration
+	newReg.SigningKey = newKey
+	return &newReg, nil
+}
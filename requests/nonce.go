@@ -0,0 +1,87 @@
+package requests
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	"net/http"
+	"sync"
+)
+
+// nonces are only valid for the server that issued them; the pool is
+// indexed by the newNonce URL of the directory.
+type noncePool struct {
+	mutex  sync.Mutex
+	nonces map[string][]string
+}
+
+var nonces = noncePool{
+	nonces: make(map[string][]string),
+}
+
+func (pool *noncePool) put(newNonceURL string, header http.Header) {
+	nonce := header.Get("Replay-Nonce")
+	if 0 == len(nonce) {
+		return
+	}
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	pool.nonces[newNonceURL] = append(pool.nonces[newNonceURL], nonce)
+}
+
+func (pool *noncePool) take(newNonceURL string) (string, bool) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	if list := pool.nonces[newNonceURL]; 0 != len(list) {
+		// use the newest nonce; older ones are more likely to be expired
+		nonce := list[len(list)-1]
+		pool.nonces[newNonceURL] = list[:len(list)-1]
+		return nonce, true
+	}
+	return "", false
+}
+
+// take a nonce from the pool or fetch a fresh one from the newNonce URL
+func getNonce(directory *types.Directory) (string, error) {
+	newNonceURL := directory.Resource.NewNonce
+	if 0 == len(newNonceURL) {
+		return "", fmt.Errorf("Directory %s doesn't provide a newNonce URL", directory.RootURL)
+	}
+
+	if nonce, ok := nonces.take(newNonceURL); ok {
+		return nonce, nil
+	}
+
+	return fetchNonce(newNonceURL)
+}
+
+func fetchNonce(newNonceURL string) (string, error) {
+	req := utils.HttpRequest{
+		Method: "HEAD",
+		URL:    newNonceURL,
+	}
+	resp, err := runRequest(&req)
+	if nil != err {
+		return "", err
+	}
+
+	nonce := resp.RawResponse.Header.Get("Replay-Nonce")
+	if 0 == len(nonce) {
+		return "", fmt.Errorf("Didn't get a Replay-Nonce header")
+	}
+	return nonce, nil
+}
+
+// remember the Replay-Nonce of a response for later requests
+func saveNonce(directory *types.Directory, resp *utils.HttpResponse) {
+	if nil != resp && nil != resp.RawResponse && 0 != len(directory.Resource.NewNonce) {
+		nonces.put(directory.Resource.NewNonce, resp.RawResponse.Header)
+	}
+}
+
+// the server rejected the nonce; the request can be retried with a new one
+func isBadNonce(err error) bool {
+	var problem *types.ProblemError
+	return errors.As(err, &problem) && problem.IsType("badNonce")
+}
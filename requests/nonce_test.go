@@ -0,0 +1,153 @@
+package requests
+
+import (
+	"fmt"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	jose "gopkg.in/square/go-jose.v2"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type nonceTestServer struct {
+	*httptest.Server
+	// the first badNonces POST requests get rejected
+	badNonces int
+	issued    int
+	newNonces int
+	// nonces sent with the POST requests
+	used []string
+}
+
+func newNonceTestServer(t *testing.T, badNonces int) *nonceTestServer {
+	ts := &nonceTestServer{badNonces: badNonces}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.issued++
+		w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", ts.issued))
+		switch {
+		case "HEAD" == r.Method && "/new-nonce" == r.URL.Path:
+			ts.newNonces++
+		case "POST" == r.Method && "/resource" == r.URL.Path:
+			body, _ := ioutil.ReadAll(r.Body)
+			sig, err := jose.ParseSigned(string(body))
+			if nil != err {
+				t.Errorf("Couldn't parse signed request: %s", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			ts.used = append(ts.used, sig.Signatures[0].Protected.Nonce)
+			if len(ts.used) <= ts.badNonces {
+				w.Header().Set("Content-Type", contentTypeProblemJson)
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"type":"urn:ietf:params:acme:error:badNonce","detail":"expired nonce"}`))
+				return
+			}
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return ts
+}
+
+func (ts *nonceTestServer) directory() *types.Directory {
+	return &types.Directory{
+		RootURL: ts.URL + "/directory",
+		Resource: types.DirectoryResource{
+			NewNonce: ts.URL + "/new-nonce",
+		},
+	}
+}
+
+func (ts *nonceTestServer) post(t *testing.T, directory *types.Directory) error {
+	key, err := types.CreateSigningKey(utils.KeyEcdsa, utils.CurveP256, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	req := utils.HttpRequest{
+		Method: "POST",
+		URL:    ts.URL + "/resource",
+	}
+	_, err = RunSignedRequest(directory, key, ts.URL+"/account/1", &req, []byte(`{}`))
+	return err
+}
+
+func TestNoncePool(t *testing.T) {
+	ts := newNonceTestServer(t, 0)
+	defer ts.Close()
+	directory := ts.directory()
+
+	for i := 0; i < 3; i++ {
+		if err := ts.post(t, directory); nil != err {
+			t.Fatalf("Request failed: %s", err)
+		}
+	}
+	if 1 != ts.newNonces {
+		t.Errorf("Expected a single newNonce request, got %d", ts.newNonces)
+	}
+	// every request uses the nonce returned with the previous one
+	expected := []string{"nonce-1", "nonce-2", "nonce-3"}
+	if strings.Join(expected, " ") != strings.Join(ts.used, " ") {
+		t.Errorf("Expected nonces %v, got %v", expected, ts.used)
+	}
+}
+
+func TestBadNonceRetry(t *testing.T) {
+	ts := newNonceTestServer(t, 1)
+	defer ts.Close()
+
+	if err := ts.post(t, ts.directory()); nil != err {
+		t.Fatalf("Request wasn't retried after badNonce: %s", err)
+	}
+	if 2 != len(ts.used) {
+		t.Fatalf("Expected two requests, got %d", len(ts.used))
+	}
+	// the retry uses the nonce from the error response
+	if "nonce-2" != ts.used[1] || 1 != ts.newNonces {
+		t.Errorf("Retry used nonce %s after %d newNonce requests", ts.used[1], ts.newNonces)
+	}
+}
+
+func TestBadNonceRetryOnce(t *testing.T) {
+	ts := newNonceTestServer(t, 2)
+	defer ts.Close()
+
+	err := ts.post(t, ts.directory())
+	if !isBadNonce(err) {
+		t.Fatalf("Expected badNonce error, got %v", err)
+	}
+	if 2 != len(ts.used) {
+		t.Errorf("Expected a single retry, got %d requests", len(ts.used))
+	}
+}
+
+func TestFetchNonceErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	if _, err := fetchNonce(server.URL + "/new-nonce"); nil == err {
+		t.Errorf("Error status for newNonce wasn't reported")
+	}
+
+	// fetching nonces goes through the transport hook too
+	transported := 0
+	utils.HttpTransport = func(req *utils.HttpRequest) (*http.Response, error) {
+		transported++
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     "200 OK",
+			Header:     http.Header{"Replay-Nonce": []string{"hooked"}},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}, nil
+	}
+	defer func() { utils.HttpTransport = nil }()
+	if nonce, err := fetchNonce(server.URL + "/new-nonce"); nil != err || "hooked" != nonce || 1 != transported {
+		t.Errorf("Expected nonce from transport hook, got %#v (%v)", nonce, err)
+	}
+}
@@ -0,0 +1,353 @@
+package requests
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+// Offline mode: the host with the storage (and the account key) has no
+// network access; instead of sending requests they are queued in a bundle
+// file. An online host sends the queued requests (see SubmitOfflineBundle)
+// and stores the responses and fresh nonces in the bundle. When the command
+// is run again on the offline host, the recorded responses are replayed
+// (and imported into the storage by the normal code paths), and the next
+// requests are queued. Replayed responses are removed from the bundle once
+// the model layer stored their results (see CommitOfflineBundle); if the
+// command fails before that, they are replayed again in the next round.
+
+type OfflineRequest struct {
+	Method      string
+	URL         string
+	ContentType string `json:",omitempty"`
+	Accept      string `json:",omitempty"`
+	// for signed requests the JWS (containing nonce and signature)
+	Body []byte `json:",omitempty"`
+
+	// signed requests are matched by payload and key instead of the body
+	Signed        bool   `json:",omitempty"`
+	Payload       []byte `json:",omitempty"`
+	KeyThumbprint string `json:",omitempty"`
+	// newNonce URL of the directory; the Replay-Nonce of the response is
+	// kept for it
+	NonceURL string `json:",omitempty"`
+}
+
+type OfflineResponse struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+type OfflineExchange struct {
+	Request  OfflineRequest
+	Response OfflineResponse
+
+	// replayed in this run; removed on commit
+	replayed bool
+}
+
+type OfflineBundle struct {
+	// unused nonces by newNonce URL; the offline host adds an empty list
+	// for URLs it needs nonces for
+	Nonces map[string][]string
+	// requests queued by the offline host
+	Requests []OfflineRequest
+	// answered requests whose results weren't stored yet, in the order
+	// they were sent
+	Exchanges []OfflineExchange
+
+	filename string
+}
+
+// returned for requests which got queued for the online host
+type OfflineQueuedError struct {
+	Filename string
+	URL      string
+}
+
+func (err *OfflineQueuedError) Error() string {
+	return fmt.Sprintf("Request to %s queued in offline bundle %#v; run \"offline-submit %s\" on an online host, copy the bundle back and run this command again", err.URL, err.Filename, err.Filename)
+}
+
+// active bundle on the offline host
+var offlineBundle *OfflineBundle
+
+// a missing file is treated as an empty bundle
+func LoadOfflineBundle(filename string) (*OfflineBundle, error) {
+	bundle := &OfflineBundle{
+		filename: filename,
+	}
+	if data, err := ioutil.ReadFile(filename); nil != err {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(data, bundle); nil != err {
+		return nil, fmt.Errorf("Couldn't parse offline bundle %#v: %s", filename, err)
+	}
+	if nil == bundle.Nonces {
+		bundle.Nonces = make(map[string][]string)
+	}
+	return bundle, nil
+}
+
+func (bundle *OfflineBundle) Save() error {
+	data, err := json.MarshalIndent(bundle, "", "\t")
+	if nil != err {
+		return err
+	}
+	_, err = utils.WriteFileAtomic(bundle.filename, data, 0600, -1, -1)
+	return err
+}
+
+// all requests (including unsigned ones) get replayed from or queued in
+// the bundle instead of being sent
+func EnableOfflineBundle(filename string) error {
+	bundle, err := LoadOfflineBundle(filename)
+	if nil != err {
+		return err
+	}
+	offlineBundle = bundle
+	utils.HttpTransport = bundle.transport
+	return nil
+}
+
+// the CSR signature is randomized for some key types; requests with the
+// same key and names are considered equal
+func sameCSR(a, b []byte) bool {
+	var payloadA, payloadB struct {
+		CSR string `json:"csr"`
+	}
+	if nil != json.Unmarshal(a, &payloadA) || nil != json.Unmarshal(b, &payloadB) {
+		return false
+	}
+	derA, errA := utils.Base64UrlDecode(payloadA.CSR)
+	derB, errB := utils.Base64UrlDecode(payloadB.CSR)
+	if nil != errA || nil != errB {
+		return false
+	}
+	csrA, errA := x509.ParseCertificateRequest(derA)
+	csrB, errB := x509.ParseCertificateRequest(derB)
+	return nil == errA && nil == errB && bytes.Equal(csrA.RawTBSCertificateRequest, csrB.RawTBSCertificateRequest)
+}
+
+func (req *OfflineRequest) matches(other *OfflineRequest) bool {
+	if req.Method != other.Method || req.URL != other.URL || req.Signed != other.Signed {
+		return false
+	}
+	if req.Signed {
+		return req.KeyThumbprint == other.KeyThumbprint &&
+			(bytes.Equal(req.Payload, other.Payload) || sameCSR(req.Payload, other.Payload))
+	}
+	return bytes.Equal(req.Body, other.Body)
+}
+
+func (resp *OfflineResponse) httpResponse() *http.Response {
+	return &http.Response{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       ioutil.NopCloser(bytes.NewReader(resp.Body)),
+	}
+}
+
+// the oldest recorded response for the request which wasn't replayed in
+// this run yet; each response is only used once
+func (bundle *OfflineBundle) replay(req *OfflineRequest) *OfflineResponse {
+	for ndx := range bundle.Exchanges {
+		exchange := &bundle.Exchanges[ndx]
+		if !exchange.replayed && exchange.Request.matches(req) {
+			exchange.replayed = true
+			return &exchange.Response
+		}
+	}
+	return nil
+}
+
+// remove the replayed responses from the bundle and save it
+func (bundle *OfflineBundle) Commit() error {
+	var remaining []OfflineExchange
+	for _, exchange := range bundle.Exchanges {
+		if !exchange.replayed {
+			remaining = append(remaining, exchange)
+		}
+	}
+	if len(remaining) == len(bundle.Exchanges) {
+		return nil
+	}
+	bundle.Exchanges = remaining
+	if err := bundle.Save(); nil != err {
+		return fmt.Errorf("Couldn't write offline bundle %#v: %s", bundle.filename, err)
+	}
+	return nil
+}
+
+// called by the model layer after the results of the requests were
+// stored; does nothing if offline mode isn't active
+func CommitOfflineBundle() error {
+	if nil == offlineBundle {
+		return nil
+	}
+	return offlineBundle.Commit()
+}
+
+func (bundle *OfflineBundle) isQueued(req *OfflineRequest) bool {
+	for ndx := range bundle.Requests {
+		if bundle.Requests[ndx].matches(req) {
+			return true
+		}
+	}
+	return false
+}
+
+func (bundle *OfflineBundle) queue(req OfflineRequest) error {
+	if !bundle.isQueued(&req) {
+		bundle.Requests = append(bundle.Requests, req)
+		if err := bundle.Save(); nil != err {
+			return fmt.Errorf("Couldn't write offline bundle %#v: %s", bundle.filename, err)
+		}
+	}
+	return &OfflineQueuedError{Filename: bundle.filename, URL: req.URL}
+}
+
+func (bundle *OfflineBundle) transport(req *utils.HttpRequest) (*http.Response, error) {
+	oreq := OfflineRequest{
+		Method:      req.Method,
+		URL:         req.URL,
+		ContentType: req.Headers.ContentType,
+		Accept:      req.Headers.Accept,
+		Body:        req.Body,
+	}
+	if resp := bundle.replay(&oreq); nil != resp {
+		return resp.httpResponse(), nil
+	}
+	return nil, bundle.queue(oreq)
+}
+
+func (bundle *OfflineBundle) runSignedRequest(directory *types.Directory, signingKey types.SigningKey, keyID string, req *utils.HttpRequest, payloadJson []byte) (*utils.HttpResponse, error) {
+	thumbprint, err := signingKey.GetPublicKey().Thumbprint(crypto.SHA256)
+	if nil != err {
+		return nil, err
+	}
+	oreq := OfflineRequest{
+		Method:        req.Method,
+		URL:           req.URL,
+		ContentType:   req.Headers.ContentType,
+		Accept:        req.Headers.Accept,
+		Signed:        true,
+		Payload:       payloadJson,
+		KeyThumbprint: utils.Base64UrlEncode(thumbprint),
+		NonceURL:      directory.Resource.NewNonce,
+	}
+
+	// a request rejected because of a bad nonce was queued again with a
+	// new nonce; look for the next response
+	for {
+		recorded := bundle.replay(&oreq)
+		if nil == recorded {
+			break
+		}
+		resp, err := responseError(utils.ReadHttpResponse(recorded.httpResponse()))
+		if !isBadNonce(err) {
+			return resp, err
+		}
+	}
+
+	if bundle.isQueued(&oreq) {
+		return nil, &OfflineQueuedError{Filename: bundle.filename, URL: req.URL}
+	}
+
+	if 0 == len(oreq.NonceURL) {
+		return nil, fmt.Errorf("Directory %s doesn't provide a newNonce URL", directory.RootURL)
+	}
+	nonces := bundle.Nonces[oreq.NonceURL]
+	if 0 == len(nonces) {
+		// remember that we need nonces from this server
+		bundle.Nonces[oreq.NonceURL] = []string{}
+		if err := bundle.Save(); nil != err {
+			return nil, fmt.Errorf("Couldn't write offline bundle %#v: %s", bundle.filename, err)
+		}
+		return nil, fmt.Errorf("No nonces for %s left in offline bundle %#v; run \"offline-submit %s\" on an online host to fetch new ones", oreq.NonceURL, bundle.filename, bundle.filename)
+	}
+	nonce := nonces[0]
+	bundle.Nonces[oreq.NonceURL] = nonces[1:]
+
+	sig, err := signingKey.SignRequest(payloadJson, nonce, req.URL, keyID)
+	if nil != err {
+		return nil, err
+	}
+	oreq.Body = []byte(sig.FullSerialize())
+	return nil, bundle.queue(oreq)
+}
+
+// send the queued requests and record the responses (used on the online
+// host); requests failing without a HTTP response stay queued. Afterwards
+// the bundle contains (up to) nonceCount fresh nonces for each server.
+func SubmitOfflineBundle(bundle *OfflineBundle, nonceCount int) (sent int, failed int) {
+	freshNonces := make(map[string][]string)
+
+	var remaining []OfflineRequest
+	for _, oreq := range bundle.Requests {
+		req := utils.HttpRequest{
+			Method: oreq.Method,
+			URL:    oreq.URL,
+			Body:   oreq.Body,
+			Headers: utils.HttpRequestHeader{
+				ContentType: oreq.ContentType,
+				Accept:      oreq.Accept,
+			},
+		}
+		// HTTP errors are recorded too, the offline host handles them
+		resp, err := req.Run()
+		if nil == resp {
+			utils.Errorf("Request to %s failed: %s", oreq.URL, err)
+			remaining = append(remaining, oreq)
+			failed++
+			continue
+		}
+		sent++
+		bundle.Exchanges = append(bundle.Exchanges, OfflineExchange{
+			Request: oreq,
+			Response: OfflineResponse{
+				StatusCode: resp.StatusCode,
+				Status:     resp.Status,
+				Header:     resp.RawResponse.Header,
+				Body:       resp.Body,
+			},
+		})
+		if nonce := resp.RawResponse.Header.Get("Replay-Nonce"); 0 != len(oreq.NonceURL) && 0 != len(nonce) {
+			freshNonces[oreq.NonceURL] = append(freshNonces[oreq.NonceURL], nonce)
+		}
+	}
+	bundle.Requests = remaining
+
+	// old nonces probably expired already
+	for nonceURL := range freshNonces {
+		if _, ok := bundle.Nonces[nonceURL]; !ok {
+			bundle.Nonces[nonceURL] = nil
+		}
+	}
+	for nonceURL := range bundle.Nonces {
+		nonces := freshNonces[nonceURL]
+		for len(nonces) < nonceCount {
+			nonce, err := fetchNonce(nonceURL)
+			if nil != err {
+				utils.Errorf("Couldn't fetch nonce from %s: %s", nonceURL, err)
+				failed++
+				break
+			}
+			nonces = append(nonces, nonce)
+		}
+		bundle.Nonces[nonceURL] = nonces
+	}
+
+	return
+}
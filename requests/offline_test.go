@@ -0,0 +1,373 @@
+package requests
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	jose "gopkg.in/square/go-jose.v2"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type offlineTestServer struct {
+	*httptest.Server
+	// the first badNonces signed requests get rejected
+	badNonces int
+	issued    int
+	newNonces int
+	// nonces sent with the signed requests
+	used []string
+	// answered requests to /resource
+	answered int
+
+	leaf, chain, alternate []*x509.Certificate
+}
+
+func newOfflineTestServer(t *testing.T, badNonces int) *offlineTestServer {
+	ts := &offlineTestServer{badNonces: badNonces}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.issued++
+		w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", ts.issued))
+		if "HEAD" == r.Method && "/new-nonce" == r.URL.Path {
+			ts.newNonces++
+			return
+		} else if "GET" == r.Method && "/directory" == r.URL.Path {
+			w.Write([]byte(`{"meta":{}}`))
+			return
+		} else if "POST" != r.Method {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		sig, err := jose.ParseSigned(string(body))
+		if nil != err {
+			t.Errorf("Couldn't parse signed request: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		ts.used = append(ts.used, sig.Signatures[0].Protected.Nonce)
+		if len(ts.used) <= ts.badNonces {
+			w.Header().Set("Content-Type", contentTypeProblemJson)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"type":"urn:ietf:params:acme:error:badNonce","detail":"expired nonce"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/resource":
+			ts.answered++
+			fmt.Fprintf(w, `{"answer":%d}`, ts.answered)
+		case "/cert":
+			w.Header().Set("Content-Type", contentTypePemCertificateChain)
+			w.Header().Add("Link", fmt.Sprintf(`<%s/cert/alt>;rel="alternate"`, ts.URL))
+			w.Write(utils.CertificatesToPem(append(ts.leaf, ts.chain...)))
+		case "/cert/alt":
+			w.Header().Set("Content-Type", contentTypePemCertificateChain)
+			w.Write(utils.CertificatesToPem(append(ts.leaf, ts.alternate...)))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return ts
+}
+
+func (ts *offlineTestServer) directory() *types.Directory {
+	return &types.Directory{
+		RootURL: ts.URL + "/directory",
+		Resource: types.DirectoryResource{
+			NewNonce: ts.URL + "/new-nonce",
+		},
+	}
+}
+
+// one run of a command on the offline host and offline-submit on the
+// online host
+type offlineTest struct {
+	t        *testing.T
+	filename string
+	key      types.SigningKey
+}
+
+func newOfflineTest(t *testing.T) *offlineTest {
+	key, err := types.CreateSigningKey(utils.KeyEcdsa, utils.CurveP256, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return &offlineTest{
+		t:        t,
+		filename: filepath.Join(t.TempDir(), "bundle.json"),
+		key:      key,
+	}
+}
+
+func (ot *offlineTest) round(run func() error) error {
+	if err := EnableOfflineBundle(ot.filename); nil != err {
+		ot.t.Fatalf("Couldn't enable offline bundle: %s", err)
+	}
+	defer func() {
+		offlineBundle = nil
+		utils.HttpTransport = nil
+	}()
+	return run()
+}
+
+func (ot *offlineTest) submit() int {
+	bundle := ot.bundle()
+	sent, failed := SubmitOfflineBundle(bundle, 2)
+	if 0 != failed {
+		ot.t.Fatalf("%d requests failed", failed)
+	}
+	if err := bundle.Save(); nil != err {
+		ot.t.Fatal(err)
+	}
+	return sent
+}
+
+func (ot *offlineTest) bundle() *OfflineBundle {
+	bundle, err := LoadOfflineBundle(ot.filename)
+	if nil != err {
+		ot.t.Fatalf("Couldn't load offline bundle: %s", err)
+	}
+	return bundle
+}
+
+func (ot *offlineTest) post(directory *types.Directory, url string) (*utils.HttpResponse, error) {
+	req := utils.HttpRequest{
+		Method: "POST",
+		URL:    url,
+	}
+	return RunSignedRequest(directory, ot.key, "https://acme.example.com/account/1", &req, []byte(`{"test":true}`))
+}
+
+func expectQueued(t *testing.T, err error) {
+	var queued *OfflineQueuedError
+	if !errors.As(err, &queued) {
+		t.Fatalf("Expected queued request, got %v", err)
+	}
+}
+
+func TestOfflineRoundTrip(t *testing.T) {
+	ts := newOfflineTestServer(t, 0)
+	defer ts.Close()
+	directory := ts.directory()
+	ot := newOfflineTest(t)
+	post := func() error {
+		resp, err := ot.post(directory, ts.URL+"/resource")
+		if nil == err && `{"answer":1}` != string(resp.Body) {
+			t.Errorf("Unexpected response %#v", string(resp.Body))
+		}
+		return err
+	}
+
+	// the first round only asks for nonces
+	if err := ot.round(post); nil == err || !strings.Contains(err.Error(), "No nonces") {
+		t.Fatalf("Expected missing nonces, got %v", err)
+	}
+	if nonces, ok := ot.bundle().Nonces[directory.Resource.NewNonce]; !ok || 0 != len(nonces) {
+		t.Fatalf("Nonce URL not requested: %v", ot.bundle().Nonces)
+	}
+	if sent := ot.submit(); 0 != sent || 2 != ts.newNonces {
+		t.Fatalf("Expected only nonces to be fetched, sent %d, %d nonces", sent, ts.newNonces)
+	}
+
+	expectQueued(t, ot.round(post))
+	if bundle := ot.bundle(); 1 != len(bundle.Requests) || 1 != len(bundle.Nonces[directory.Resource.NewNonce]) {
+		t.Fatalf("Expected one queued request and one nonce left, got %d / %v", len(bundle.Requests), bundle.Nonces)
+	}
+	if sent := ot.submit(); 1 != sent || 1 != ts.answered {
+		t.Fatalf("Expected one request to be sent, got %d", sent)
+	}
+	if bundle := ot.bundle(); 0 != len(bundle.Requests) || 1 != len(bundle.Exchanges) {
+		t.Fatalf("Expected one recorded exchange, got %d requests / %d exchanges", len(bundle.Requests), len(bundle.Exchanges))
+	}
+
+	// without storing the result the response stays in the bundle
+	if err := ot.round(post); nil != err {
+		t.Fatalf("Recorded response wasn't replayed: %s", err)
+	}
+	if 1 != len(ot.bundle().Exchanges) {
+		t.Fatalf("Replayed response was removed before its result was stored")
+	}
+
+	err := ot.round(func() error {
+		if err := post(); nil != err {
+			return err
+		}
+		// each response is only used once per run
+		if _, err := ot.post(directory, ts.URL+"/resource"); nil == err {
+			t.Errorf("Response was replayed twice")
+		}
+		return CommitOfflineBundle()
+	})
+	if nil != err {
+		t.Fatalf("Recorded response wasn't replayed again: %s", err)
+	}
+	if bundle := ot.bundle(); 0 != len(bundle.Exchanges) || 1 != len(bundle.Requests) {
+		t.Fatalf("Expected committed response to be removed and a new request, got %d exchanges / %d requests", len(bundle.Exchanges), len(bundle.Requests))
+	}
+}
+
+func TestOfflineUnsignedReplay(t *testing.T) {
+	ts := newOfflineTestServer(t, 0)
+	defer ts.Close()
+	ot := newOfflineTest(t)
+	fetch := func() error {
+		dir, err := FetchDirectory(ts.URL + "/directory")
+		if nil == err && ts.URL+"/directory" != dir.RootURL {
+			t.Errorf("Unexpected directory %#v", dir)
+		}
+		return err
+	}
+
+	expectQueued(t, ot.round(fetch))
+	if sent := ot.submit(); 1 != sent {
+		t.Fatalf("Expected one request to be sent, got %d", sent)
+	}
+	if err := ot.round(fetch); nil != err {
+		t.Fatalf("Recorded response wasn't replayed: %s", err)
+	}
+}
+
+func TestOfflineBadNonceRequeue(t *testing.T) {
+	ts := newOfflineTestServer(t, 1)
+	defer ts.Close()
+	directory := ts.directory()
+	ot := newOfflineTest(t)
+	post := func() error {
+		_, err := ot.post(directory, ts.URL+"/resource")
+		return err
+	}
+
+	ot.round(post)
+	ot.submit()
+	expectQueued(t, ot.round(post))
+	ot.submit()
+
+	// the badNonce response is replayed, the request queued again with a
+	// new nonce
+	expectQueued(t, ot.round(post))
+	if bundle := ot.bundle(); 1 != len(bundle.Requests) || !bundle.Requests[0].Signed {
+		t.Fatalf("Request rejected with badNonce wasn't queued again")
+	}
+	ot.submit()
+	if 2 != len(ts.used) || ts.used[0] == ts.used[1] {
+		t.Fatalf("Expected two requests with different nonces, got %v", ts.used)
+	}
+
+	if err := ot.round(post); nil != err {
+		t.Fatalf("Response to requeued request wasn't replayed: %s", err)
+	}
+}
+
+func offlineTestCertificate(t *testing.T, name string) *x509.Certificate {
+	key, err := utils.CreatePrivateKey(utils.KeyEcdsa, utils.CurveP256, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, utils.MustPublicKey(key), key)
+	if nil != err {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestOfflineAlternateChains(t *testing.T) {
+	ts := newOfflineTestServer(t, 0)
+	defer ts.Close()
+	ts.leaf = []*x509.Certificate{offlineTestCertificate(t, "www.example.com")}
+	ts.chain = []*x509.Certificate{offlineTestCertificate(t, "Root X1")}
+	ts.alternate = []*x509.Certificate{offlineTestCertificate(t, "Root X2")}
+	directory := ts.directory()
+	ot := newOfflineTest(t)
+	registration := &types.Registration{
+		SigningKey: ot.key,
+		Location:   "https://acme.example.com/account/1",
+	}
+	var cert *types.Certificate
+	fetch := func() (err error) {
+		cert, err = FetchCertificate(directory, registration, ts.URL+"/cert")
+		return
+	}
+
+	ot.round(fetch)
+	ot.submit()
+	expectQueued(t, ot.round(fetch))
+	ot.submit()
+
+	// the certificate isn't returned without the alternate chain
+	expectQueued(t, ot.round(fetch))
+	ot.submit()
+
+	if err := ot.round(fetch); nil != err {
+		t.Fatalf("Fetching certificate failed: %s", err)
+	}
+	if 1 != len(cert.AlternateChains) || !cert.AlternateChains[0][0].Equal(ts.alternate[0]) || !cert.Chain[0].Equal(ts.chain[0]) {
+		t.Errorf("Alternate chain missing")
+	}
+}
+
+func TestOfflineCSRMatching(t *testing.T) {
+	key, err := utils.CreatePrivateKey(utils.KeyEcdsa, utils.CurveP256, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	finalizePayload := func(names ...string) []byte {
+		csr, err := utils.MakeCertificateRequest(utils.CertificateRequestParameters{
+			PrivateKey: key,
+			DNSNames:   names,
+		})
+		if nil != err {
+			t.Fatal(err)
+		}
+		return []byte(fmt.Sprintf(`{"csr":%#v}`, utils.Base64UrlEncode(csr.Bytes)))
+	}
+	request := func(payload []byte, thumbprint string) *OfflineRequest {
+		return &OfflineRequest{
+			Method:        "POST",
+			URL:           "https://acme.example.com/order/1/finalize",
+			Signed:        true,
+			Payload:       payload,
+			KeyThumbprint: thumbprint,
+		}
+	}
+
+	// ECDSA signatures differ for every CSR
+	first, second := finalizePayload("example.com"), finalizePayload("example.com")
+	if string(first) == string(second) {
+		t.Fatalf("Expected different CSR signatures")
+	}
+	if !request(first, "key").matches(request(second, "key")) {
+		t.Errorf("CSRs with the same key and names didn't match")
+	}
+	if request(first, "key").matches(request(finalizePayload("www.example.com"), "key")) {
+		t.Errorf("CSRs with different names matched")
+	}
+	if request(first, "key").matches(request(second, "other key")) {
+		t.Errorf("Requests signed with different keys matched")
+	}
+	if sameCSR(first, []byte(`{"csr":"invalid"}`)) {
+		t.Errorf("Invalid CSR matched")
+	}
+}
@@ -0,0 +1,150 @@
+package requests
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+)
+
+type newOrder struct {
+	Identifiers []types.Identifier `json:"identifiers"`
+}
+
+func NewOrder(directory *types.Directory, registration *types.Registration, domains []string) (*types.Order, error) {
+	payload := newOrder{}
+	for _, domain := range domains {
+		payload.Identifiers = append(payload.Identifiers, types.NewIdentifier(domain))
+	}
+
+	payloadJson, err := json.Marshal(payload)
+	if nil != err {
+		return nil, err
+	}
+
+	url := directory.Resource.NewOrder
+	req := utils.HttpRequest{
+		Method: "POST",
+		URL:    url,
+	}
+
+	resp, err := runRegistrationRequest(directory, registration, &req, payloadJson)
+	if nil != err {
+		return nil, requestFailed(err, "POST order %s to %s failed: %s", string(payloadJson), url, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("POST %s to %s failed: %s", string(payloadJson), url, resp.Status)
+	}
+
+	if 0 == len(resp.Location) {
+		return nil, fmt.Errorf("Creating order failed: missing Location")
+	}
+
+	var response types.Order
+	err = json.Unmarshal(resp.Body, &response.Resource)
+	if nil != err {
+		return nil, fmt.Errorf("Failed decoding response from POST %s to %s: %s", string(payloadJson), url, err)
+	}
+	response.Location = resp.Location
+
+	return &response, nil
+}
+
+func FetchOrder(directory *types.Directory, registration *types.Registration, orderURL string) (*types.OrderResource, error) {
+	resp, err := runPostAsGet(directory, registration, orderURL, "")
+	if nil != err {
+		return nil, requestFailed(err, "Refreshing order %s failed: %s", orderURL, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("POST-as-GET %s failed: %s", orderURL, resp.Status)
+	}
+
+	var response types.OrderResource
+	err = json.Unmarshal(resp.Body, &response)
+	if nil != err {
+		return nil, fmt.Errorf("Failed decoding response from POST-as-GET %s: %s", orderURL, err)
+	}
+
+	return &response, nil
+}
+
+type finalizeOrder struct {
+	CSR string `json:"csr"`
+}
+
+// returns the updated order; the certificate is usually not issued yet
+func FinalizeOrder(directory *types.Directory, registration *types.Registration, order *types.Order, csr pem.Block) (*types.OrderResource, error) {
+	payload := finalizeOrder{
+		CSR: utils.Base64UrlEncode(csr.Bytes),
+	}
+
+	payloadJson, err := json.Marshal(payload)
+	if nil != err {
+		return nil, err
+	}
+
+	url := order.Resource.Finalize
+	if 0 == len(url) {
+		return nil, fmt.Errorf("Order %s has no finalize URL", order.Location)
+	}
+	req := utils.HttpRequest{
+		Method: "POST",
+		URL:    url,
+	}
+
+	resp, err := runRegistrationRequest(directory, registration, &req, payloadJson)
+	if nil != err {
+		return nil, requestFailed(err, "POST certificate request %s to %s failed: %s", string(payloadJson), url, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("POST certificate request %s to %s failed: %s", string(payloadJson), url, resp.Status)
+	}
+
+	var response types.OrderResource
+	err = json.Unmarshal(resp.Body, &response)
+	if nil != err {
+		return nil, fmt.Errorf("Failed decoding response from POST %s to %s: %s", string(payloadJson), url, err)
+	}
+
+	return &response, nil
+}
+
+type orderListJSON struct {
+	Orders []string `json:"orders"`
+}
+
+// follows "next" links to retrieve all pages
+func FetchOrderList(directory *types.Directory, registration *types.Registration, ordersURL string) ([]string, error) {
+	orders := []string{}
+	visited := make(map[string]bool)
+
+	for url := ordersURL; 0 != len(url) && !visited[url]; {
+		visited[url] = true
+
+		resp, err := runPostAsGet(directory, registration, url, "")
+		if nil != err {
+			return nil, requestFailed(err, "Retrieving orders list from %s failed: %s", url, err)
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("POST-as-GET %s failed: %s", url, resp.Status)
+		}
+
+		response := orderListJSON{
+			Orders: []string{},
+		}
+		err = json.Unmarshal(resp.Body, &response)
+		if nil != err {
+			return nil, fmt.Errorf("Failed decoding response from POST-as-GET %s: %s", url, err)
+		}
+		orders = append(orders, response.Orders...)
+
+		url = resp.Link("next")
+	}
+
+	return orders, nil
+}
@@ -0,0 +1,79 @@
+package requests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	"mime"
+)
+
+const contentTypeProblemJson = "application/problem+json"
+
+// extract the problem document from an error response (if there is one)
+func responseProblem(resp *utils.HttpResponse) *types.ProblemError {
+	if nil == resp || (resp.StatusCode >= 200 && resp.StatusCode < 400) {
+		return nil
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.ContentType); nil != err || contentTypeProblemJson != mediaType {
+		return nil
+	}
+	var problem types.ProblemError
+	if err := json.Unmarshal(resp.Body, &problem); nil != err {
+		utils.Debugf("Couldn't decode problem document: %s", err)
+		return nil
+	}
+	if 0 == problem.Status {
+		problem.Status = resp.StatusCode
+	}
+	return &problem
+}
+
+// run request and replace the generic HTTP error with the problem document
+// from the response (if there is one)
+func runRequest(req *utils.HttpRequest) (*utils.HttpResponse, error) {
+	resp, err := req.Run()
+	return responseError(resp, err)
+}
+
+func responseError(resp *utils.HttpResponse, err error) (*utils.HttpResponse, error) {
+	if nil != err {
+		if problem := responseProblem(resp); nil != problem {
+			return resp, problem
+		}
+	}
+	return resp, err
+}
+
+// a failed request with the server's problem document; the message has
+// the context of the request, the problem can be found with errors.As
+type RequestError struct {
+	Message string
+	Problem *types.ProblemError
+}
+
+func (e *RequestError) Error() string {
+	return e.Message
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Problem
+}
+
+// format errors with the given context; problem documents stay accessible
+// (see RequestError)
+func requestFailed(err error, format string, v ...interface{}) error {
+	var problem *types.ProblemError
+	var queued *OfflineQueuedError
+	if errors.As(err, &queued) {
+		// the request wasn't sent; callers need to recognize this
+		return queued
+	} else if errors.As(err, &problem) {
+		return &RequestError{
+			Message: fmt.Sprintf(format, v...),
+			Problem: problem,
+		}
+	}
+	return fmt.Errorf(format, v...)
+}
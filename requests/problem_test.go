@@ -0,0 +1,55 @@
+package requests
+
+import (
+	"errors"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRequestFailedKeepsProblem(t *testing.T) {
+	resp := &utils.HttpResponse{
+		StatusCode:  http.StatusTooManyRequests,
+		ContentType: "application/problem+json; charset=utf-8",
+		Body:        []byte(`{"type":"urn:ietf:params:acme:error:rateLimited","detail":"too many certificates"}`),
+	}
+	_, err := responseError(resp, errors.New("HTTP error code: 429"))
+	err = requestFailed(err, "POST new order to %s failed: %s", "https://acme.example.com/new-order", err)
+
+	if !strings.HasPrefix(err.Error(), "POST new order to https://acme.example.com/new-order failed: rateLimited") {
+		t.Errorf("Request context missing in %#v", err.Error())
+	}
+	var problem *types.ProblemError
+	if !errors.As(err, &problem) {
+		t.Fatalf("Problem document not accessible in %#v", err)
+	}
+	if !problem.IsType("rateLimited") || http.StatusTooManyRequests != problem.Status {
+		t.Errorf("Unexpected problem %#v", problem)
+	}
+}
+
+func TestRequestFailedOtherErrors(t *testing.T) {
+	err := requestFailed(errors.New("connection refused"), "GET %s failed: %s", "https://acme.example.com/", "connection refused")
+	var problem *types.ProblemError
+	if errors.As(err, &problem) {
+		t.Errorf("Unexpected problem document in %#v", err)
+	}
+	if "GET https://acme.example.com/ failed: connection refused" != err.Error() {
+		t.Errorf("Unexpected message %#v", err.Error())
+	}
+}
+
+func TestIsBadNonceWrapped(t *testing.T) {
+	problem := &types.ProblemError{Type: "urn:ietf:params:acme:error:badNonce"}
+	if !isBadNonce(problem) {
+		t.Errorf("badNonce problem not detected")
+	}
+	if !isBadNonce(requestFailed(problem, "POST failed: %s", problem)) {
+		t.Errorf("badNonce problem not detected through RequestError")
+	}
+	if isBadNonce(&types.ProblemError{Type: "urn:ietf:params:acme:error:malformed"}) {
+		t.Errorf("malformed detected as badNonce")
+	}
+}
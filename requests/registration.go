@@ -8,12 +8,11 @@ import (
 )
 
 type rawRegistration struct {
-	Contact       []string `json:"contact,omitempty"`
-	Agreement     string   `json:"agreement,omitempty"`
-	RecoveryToken string   `json:"recoveryToken,omitempty"`
+	Contact              []string `json:"contact,omitempty"`
+	TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed,omitempty"`
 }
 
-func sendRegistration(url string, signingKey types.SigningKey, payload interface{}, old *types.Registration) (*types.Registration, error) {
+func sendRegistration(directory *types.Directory, url string, signingKey types.SigningKey, payload interface{}, old *types.Registration) (*types.Registration, error) {
 	payloadJson, err := json.Marshal(payload)
 	if nil != err {
 		return nil, err
@@ -22,14 +21,12 @@ func sendRegistration(url string, signingKey types.SigningKey, payload interface
 	req := utils.HttpRequest{
 		Method: "POST",
 		URL:    url,
-		Headers: utils.HttpRequestHeader{
-			ContentType: "application/json",
-		},
 	}
 
-	resp, err := RunSignedRequest(signingKey, &req, payloadJson)
+	// only new registrations don't have a location yet
+	resp, err := RunSignedRequest(directory, signingKey, old.Location, &req, payloadJson)
 	if nil != err {
-		return nil, fmt.Errorf("POSTing registration %s to %s failed: %s", string(payloadJson), url, err)
+		return nil, requestFailed(err, "POSTing registration %s to %s failed: %s", string(payloadJson), url, err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
@@ -51,24 +48,25 @@ func sendRegistration(url string, signingKey types.SigningKey, payload interface
 	if 0 == len(registration.Location) {
 		return nil, fmt.Errorf("Invalid registration location")
 	}
-	registration.LinkTermsOfService = resp.Links["terms-of-service"].URL
-	// TODO: handle RecoveryToken updates
-	registration.RecoveryToken = old.RecoveryToken
+	registration.LinkTermsOfService = directory.Resource.Meta.TermsOfService
+	if link := resp.Link("terms-of-service"); 0 != len(link) {
+		registration.LinkTermsOfService = link
+	}
+	registration.AgreementURL = old.AgreementURL
 	registration.Name = old.Name
 
 	return &registration, nil
 }
 
 // should use a unique signing key for each registration!
-type newRegistration struct {
-	Resource types.ResourceNewRegistrationTag `json:"resource"`
-	Contact  []string                         `json:"contact,omitempty"`
-}
-
-func NewRegistration(directory *types.Directory, signingKey types.SigningKey, contact []string) (*types.Registration, error) {
-	old := types.Registration{} // empty Name
-	reg, err := sendRegistration(directory.Resource.NewRegistration, signingKey, newRegistration{
-		Contact: contact,
+func NewRegistration(directory *types.Directory, signingKey types.SigningKey, contact []string, agreementURL string) (*types.Registration, error) {
+	old := types.Registration{
+		// empty Name and Location
+		AgreementURL: agreementURL,
+	}
+	reg, err := sendRegistration(directory, directory.Resource.NewRegistration, signingKey, rawRegistration{
+		Contact:              contact,
+		TermsOfServiceAgreed: 0 != len(agreementURL),
 	}, &old)
 	if nil != err {
 		return nil, err
@@ -76,10 +74,10 @@ func NewRegistration(directory *types.Directory, signingKey types.SigningKey, co
 	return reg, nil
 }
 
-func UpdateRegistration(registration *types.Registration) (*types.Registration, error) {
-	reg, err := sendRegistration(registration.Location, registration.SigningKey, types.RegistrationResource{
-		Contact:      registration.Resource.Contact,
-		AgreementURL: registration.Resource.AgreementURL,
+func UpdateRegistration(directory *types.Directory, registration *types.Registration) (*types.Registration, error) {
+	reg, err := sendRegistration(directory, registration.Location, registration.SigningKey, rawRegistration{
+		Contact:              registration.Resource.Contact,
+		TermsOfServiceAgreed: 0 != len(registration.AgreementURL),
 	}, registration)
 	if nil != err {
 		return nil, err
@@ -87,8 +85,21 @@ func UpdateRegistration(registration *types.Registration) (*types.Registration,
 	return reg, nil
 }
 
-func FetchRegistration(registration *types.Registration) (*types.Registration, error) {
-	reg, err := sendRegistration(registration.Location, registration.SigningKey, types.RegistrationResource{}, registration)
+func FetchRegistration(directory *types.Directory, registration *types.Registration) (*types.Registration, error) {
+	// an empty update returns the current registration
+	reg, err := sendRegistration(directory, registration.Location, registration.SigningKey, struct{}{}, registration)
+	if nil != err {
+		return nil, err
+	}
+	return reg, nil
+}
+
+func DeactivateRegistration(directory *types.Directory, registration *types.Registration) (*types.Registration, error) {
+	reg, err := sendRegistration(directory, registration.Location, registration.SigningKey, struct {
+		Status string `json:"status"`
+	}{
+		Status: "deactivated",
+	}, registration)
 	if nil != err {
 		return nil, err
 	}
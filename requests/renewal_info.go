@@ -0,0 +1,77 @@
+package requests
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// poll interval if the server doesn't send a (sane) Retry-After header
+const renewalInfoDefaultRetry = 6 * time.Hour
+const renewalInfoMinRetry = time.Minute
+const renewalInfoMaxRetry = 24 * time.Hour
+
+func parseRetryAfter(resp *utils.HttpResponse, now time.Time) time.Duration {
+	value := strings.TrimSpace(resp.RawResponse.Header.Get("Retry-After"))
+	var retry time.Duration
+	if 0 == len(value) {
+		return renewalInfoDefaultRetry
+	} else if seconds, err := strconv.ParseInt(value, 10, 64); nil == err {
+		retry = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); nil == err {
+		retry = date.Sub(now)
+	} else {
+		utils.Debugf("Invalid Retry-After header %#v", value)
+		return renewalInfoDefaultRetry
+	}
+	if retry < renewalInfoMinRetry {
+		return renewalInfoMinRetry
+	} else if retry > renewalInfoMaxRetry {
+		return renewalInfoMaxRetry
+	}
+	return retry
+}
+
+// renewal information is available through simple GET requests
+func FetchRenewalInfo(directory *types.Directory, cert *x509.Certificate) (*types.RenewalInfo, error) {
+	if 0 == len(directory.Resource.RenewalInfo) {
+		return nil, fmt.Errorf("Server doesn't provide renewal information")
+	}
+	certID, err := types.RenewalCertificateID(cert)
+	if nil != err {
+		return nil, err
+	}
+	url := strings.TrimSuffix(directory.Resource.RenewalInfo, "/") + "/" + certID
+
+	req := utils.HttpRequest{
+		Method: "GET",
+		URL:    url,
+	}
+
+	now := time.Now()
+	resp, err := runRequest(&req)
+	if nil != err {
+		return nil, requestFailed(err, "Fetching renewal information %s failed: %s", url, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("GET %s failed: %s", url, resp.Status)
+	}
+
+	var info types.RenewalInfo
+	if err := json.Unmarshal(resp.Body, &info); nil != err {
+		return nil, fmt.Errorf("Failed decoding response from GET %s: %s", url, err)
+	}
+	if info.SuggestedWindow.End.Before(info.SuggestedWindow.Start) {
+		return nil, fmt.Errorf("Invalid suggested renewal window from GET %s: end before start", url)
+	}
+	info.RetryAfter = now.Add(parseRetryAfter(resp, now))
+
+	return &info, nil
+}
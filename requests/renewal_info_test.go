@@ -0,0 +1,38 @@
+package requests
+
+import (
+	"github.com/stbuehler/go-acme-client/utils"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		header string
+		retry  time.Duration
+	}{
+		{"", renewalInfoDefaultRetry},
+		{"garbage", renewalInfoDefaultRetry},
+		{"3600", time.Hour},
+		{" 7200 ", 2 * time.Hour},
+		// clamped to the allowed range
+		{"0", renewalInfoMinRetry},
+		{"-10", renewalInfoMinRetry},
+		{"10", renewalInfoMinRetry},
+		{"604800", renewalInfoMaxRetry},
+		// HTTP dates relative to now
+		{now.Add(3 * time.Hour).Format(http.TimeFormat), 3 * time.Hour},
+		{now.Add(-time.Hour).Format(http.TimeFormat), renewalInfoMinRetry},
+		{now.Add(72 * time.Hour).Format(http.TimeFormat), renewalInfoMaxRetry},
+	} {
+		resp := &utils.HttpResponse{RawResponse: &http.Response{Header: http.Header{}}}
+		if 0 != len(test.header) {
+			resp.RawResponse.Header.Set("Retry-After", test.header)
+		}
+		if retry := parseRetryAfter(resp, now); test.retry != retry {
+			t.Errorf("Retry-After %#v: expected %v, got %v", test.header, test.retry, retry)
+		}
+	}
+}
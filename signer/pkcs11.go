@@ -0,0 +1,150 @@
+package signer
+
+import (
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"github.com/ThalesIgnite/crypto11"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// PKCS#11 URI (RFC 7512) subset:
+//
+//	pkcs11:token=acme;object=account?module-path=/usr/lib/softhsm/libsofthsm2.so
+//
+// "id" can be given instead of (or additionally to) "object"; the PIN is
+// read from "pin-source" (a file), taken from "pin-value" or asked for.
+type pkcs11Reference struct {
+	modulePath string
+	token      string
+	object     string
+	id         []byte
+	pinValue   string
+	pinSource  string
+}
+
+func parsePkcs11Reference(reference string) (*pkcs11Reference, error) {
+	if !strings.HasPrefix(reference, "pkcs11:") {
+		return nil, fmt.Errorf("Not a PKCS#11 URI: %#v", reference)
+	}
+	path := reference[len("pkcs11:"):]
+	query := ""
+	if pos := strings.IndexByte(path, '?'); -1 != pos {
+		path, query = path[:pos], path[pos+1:]
+	}
+
+	var ref pkcs11Reference
+	attributes := func(list string, separator string) error {
+		for _, attr := range strings.Split(list, separator) {
+			if 0 == len(attr) {
+				continue
+			}
+			pos := strings.IndexByte(attr, '=')
+			if -1 == pos {
+				return fmt.Errorf("Invalid PKCS#11 URI attribute %#v", attr)
+			}
+			value, err := url.PathUnescape(attr[pos+1:])
+			if nil != err {
+				return fmt.Errorf("Invalid PKCS#11 URI attribute %#v: %s", attr, err)
+			}
+			switch attr[:pos] {
+			case "module-path":
+				ref.modulePath = value
+			case "token":
+				ref.token = value
+			case "object":
+				ref.object = value
+			case "id":
+				ref.id = []byte(value)
+			case "pin-value":
+				ref.pinValue = value
+			case "pin-source":
+				ref.pinSource = strings.TrimPrefix(value, "file:")
+			default:
+				// ignore other attributes (type, manufacturer, ...)
+			}
+		}
+		return nil
+	}
+	if err := attributes(path, ";"); nil != err {
+		return nil, err
+	} else if err := attributes(query, "&"); nil != err {
+		return nil, err
+	}
+
+	if 0 == len(ref.modulePath) {
+		return nil, fmt.Errorf("PKCS#11 URI %#v without module-path", reference)
+	} else if 0 == len(ref.token) {
+		return nil, fmt.Errorf("PKCS#11 URI %#v without token", reference)
+	} else if 0 == len(ref.object) && 0 == len(ref.id) {
+		return nil, fmt.Errorf("PKCS#11 URI %#v without object or id", reference)
+	}
+	return &ref, nil
+}
+
+func (ref *pkcs11Reference) pin() (string, error) {
+	if 0 != len(ref.pinSource) {
+		data, err := ioutil.ReadFile(ref.pinSource)
+		if nil != err {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	} else if 0 != len(ref.pinValue) {
+		return ref.pinValue, nil
+	}
+	return askPin(fmt.Sprintf("Enter PIN for PKCS#11 token %#v", ref.token))
+}
+
+// a module can only be initialized once per process
+var pkcs11Mutex sync.Mutex
+var pkcs11Contexts = make(map[string]*crypto11.Context)
+
+func openPkcs11(reference string) (crypto.Signer, error) {
+	ref, err := parsePkcs11Reference(reference)
+	if nil != err {
+		return nil, err
+	}
+
+	pkcs11Mutex.Lock()
+	defer pkcs11Mutex.Unlock()
+
+	contextKey := ref.modulePath + "\x00" + ref.token
+	ctx, ok := pkcs11Contexts[contextKey]
+	if !ok {
+		pin, err := ref.pin()
+		if nil != err {
+			return nil, err
+		}
+		if ctx, err = crypto11.Configure(&crypto11.Config{
+			Path:       ref.modulePath,
+			TokenLabel: ref.token,
+			Pin:        pin,
+		}); nil != err {
+			return nil, fmt.Errorf("Couldn't open PKCS#11 token %#v: %s", ref.token, err)
+		}
+		pkcs11Contexts[contextKey] = ctx
+	}
+
+	var label []byte
+	if 0 != len(ref.object) {
+		label = []byte(ref.object)
+	}
+	var id []byte
+	if 0 != len(ref.id) {
+		id = ref.id
+	}
+	key, err := ctx.FindKeyPair(id, label)
+	if nil != err {
+		return nil, fmt.Errorf("Couldn't search PKCS#11 token %#v: %s", ref.token, err)
+	} else if nil == key {
+		return nil, fmt.Errorf("Key object=%#v id=%s not found on PKCS#11 token %#v", ref.object, hex.EncodeToString(ref.id), ref.token)
+	}
+	return key, nil
+}
+
+func init() {
+	Register("pkcs11", openPkcs11)
+}
@@ -0,0 +1,153 @@
+package signer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePkcs11Reference(t *testing.T) {
+	valid := []struct {
+		reference string
+		expected  pkcs11Reference
+	}{
+		{
+			"pkcs11:token=acme;object=account?module-path=/usr/lib/softhsm/libsofthsm2.so",
+			pkcs11Reference{modulePath: "/usr/lib/softhsm/libsofthsm2.so", token: "acme", object: "account"},
+		},
+		{
+			"pkcs11:token=my%20token;id=%01%02;type=private?module-path=/lib/p11.so&pin-value=1234",
+			pkcs11Reference{modulePath: "/lib/p11.so", token: "my token", id: []byte{1, 2}, pinValue: "1234"},
+		},
+		{
+			"pkcs11:module-path=/lib/p11.so;token=acme;object=account?pin-source=file:/etc/acme/pin",
+			pkcs11Reference{modulePath: "/lib/p11.so", token: "acme", object: "account", pinSource: "/etc/acme/pin"},
+		},
+	}
+	for _, test := range valid {
+		ref, err := parsePkcs11Reference(test.reference)
+		if nil != err {
+			t.Errorf("Couldn't parse %#v: %s", test.reference, err)
+			continue
+		}
+		if ref.modulePath != test.expected.modulePath || ref.token != test.expected.token ||
+			ref.object != test.expected.object || !bytes.Equal(ref.id, test.expected.id) ||
+			ref.pinValue != test.expected.pinValue || ref.pinSource != test.expected.pinSource {
+			t.Errorf("Parsed %#v as %+v, expected %+v", test.reference, *ref, test.expected)
+		}
+	}
+
+	invalid := []string{
+		"file:/etc/acme/key.pem",
+		"pkcs11:token=acme;object=account",
+		"pkcs11:object=account?module-path=/lib/p11.so",
+		"pkcs11:token=acme?module-path=/lib/p11.so",
+		"pkcs11:token=acme;object?module-path=/lib/p11.so",
+		"pkcs11:token=acme;object=%zz?module-path=/lib/p11.so",
+	}
+	for _, reference := range invalid {
+		if _, err := parsePkcs11Reference(reference); nil == err {
+			t.Errorf("Parsing %#v should fail", reference)
+		}
+	}
+}
+
+func TestPkcs11Pin(t *testing.T) {
+	pinFile := filepath.Join(t.TempDir(), "pin")
+	if err := ioutil.WriteFile(pinFile, []byte("4321\n"), 0600); nil != err {
+		t.Fatal(err)
+	}
+	ref := pkcs11Reference{token: "acme", pinValue: "1234", pinSource: pinFile}
+	if pin, err := ref.pin(); nil != err || "4321" != pin {
+		t.Errorf("Expected PIN from pin-source, got %#v (%v)", pin, err)
+	}
+	ref.pinSource = ""
+	if pin, err := ref.pin(); nil != err || "1234" != pin {
+		t.Errorf("Expected PIN from pin-value, got %#v (%v)", pin, err)
+	}
+}
+
+func findSoftHSM() string {
+	candidates := []string{
+		os.Getenv("SOFTHSM2_LIB"),
+		"/usr/lib/softhsm/libsofthsm2.so",
+		"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+		"/usr/local/lib/softhsm/libsofthsm2.so",
+	}
+	for _, lib := range candidates {
+		if 0 == len(lib) {
+			continue
+		}
+		if _, err := os.Stat(lib); nil == err {
+			return lib
+		}
+	}
+	return ""
+}
+
+// needs SoftHSM (libsofthsm2.so and softhsm2-util)
+func TestPkcs11SoftHSM(t *testing.T) {
+	lib := findSoftHSM()
+	if 0 == len(lib) {
+		t.Skip("SoftHSM library not found (set SOFTHSM2_LIB)")
+	}
+	if _, err := exec.LookPath("softhsm2-util"); nil != err {
+		t.Skip("softhsm2-util not found")
+	}
+
+	dir := t.TempDir()
+	tokenDir := filepath.Join(dir, "tokens")
+	if err := os.Mkdir(tokenDir, 0700); nil != err {
+		t.Fatal(err)
+	}
+	conf := filepath.Join(dir, "softhsm2.conf")
+	if err := ioutil.WriteFile(conf, []byte(fmt.Sprintf("directories.tokendir = %s\n", tokenDir)), 0600); nil != err {
+		t.Fatal(err)
+	}
+	t.Setenv("SOFTHSM2_CONF", conf)
+	if out, err := exec.Command("softhsm2-util", "--init-token", "--free", "--label", "acme-test", "--pin", "1234", "--so-pin", "5678").CombinedOutput(); nil != err {
+		t.Fatalf("Couldn't initialize token: %s\n%s", err, out)
+	}
+
+	reference := fmt.Sprintf("pkcs11:token=acme-test;object=account?module-path=%s&pin-value=1234", lib)
+	// the key doesn't exist yet, but the token context gets opened
+	if _, err := Open(reference); nil == err {
+		t.Fatalf("Opening a missing key should fail")
+	}
+	pkcs11Mutex.Lock()
+	ctx := pkcs11Contexts[lib+"\x00acme-test"]
+	pkcs11Mutex.Unlock()
+	if nil == ctx {
+		t.Fatalf("Token wasn't opened")
+	}
+	generated, err := ctx.GenerateECDSAKeyPairWithLabel([]byte{1}, []byte("account"), elliptic.P256())
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	key, err := Open(reference)
+	if nil != err {
+		t.Fatal(err)
+	}
+	public, ok := key.Public().(*ecdsa.PublicKey)
+	if !ok || !public.Equal(generated.Public()) {
+		t.Fatalf("Wrong public key %v", key.Public())
+	}
+	digest := sha256.Sum256([]byte("payload"))
+	sig, err := OpenLazy(reference, public).Sign(rand.Reader, digest[:], crypto.SHA256)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !ecdsa.VerifyASN1(public, digest[:], sig) {
+		t.Errorf("Invalid signature")
+	}
+}
@@ -0,0 +1,94 @@
+package signer
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// External signers keep the account key out of the storage (for example on
+// a PKCS#11 token); the storage only contains a reference to the key of
+// the form "<scheme>:<scheme specific part>".
+type Opener func(reference string) (crypto.Signer, error)
+
+var openersMutex sync.Mutex
+var openers = make(map[string]Opener)
+
+// backends register themselves in their init function
+func Register(scheme string, opener Opener) {
+	openersMutex.Lock()
+	defer openersMutex.Unlock()
+	openers[scheme] = opener
+}
+
+func Scheme(reference string) string {
+	if pos := strings.IndexByte(reference, ':'); -1 != pos {
+		return reference[:pos]
+	}
+	return ""
+}
+
+func Open(reference string) (crypto.Signer, error) {
+	openersMutex.Lock()
+	opener, ok := openers[Scheme(reference)]
+	openersMutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown external signer %#v", reference)
+	}
+	return opener(reference)
+}
+
+// opens the signer (which might ask for a PIN or need a token to be
+// present) only when something gets signed; public is the known public key
+// of the signer, the opened signer must have the same key.
+func OpenLazy(reference string, public crypto.PublicKey) crypto.Signer {
+	return &lazySigner{reference: reference, public: public}
+}
+
+type lazySigner struct {
+	reference string
+	public    crypto.PublicKey
+
+	mutex  sync.Mutex
+	signer crypto.Signer
+}
+
+func (ls *lazySigner) Public() crypto.PublicKey {
+	return ls.public
+}
+
+func (ls *lazySigner) open() (crypto.Signer, error) {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	if nil == ls.signer {
+		signer, err := Open(ls.reference)
+		if nil != err {
+			return nil, err
+		}
+		if public, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !public.Equal(ls.public) {
+			return nil, fmt.Errorf("The key of external signer %s doesn't match the stored public key", ls.reference)
+		}
+		ls.signer = signer
+	}
+	return ls.signer, nil
+}
+
+func (ls *lazySigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	signer, err := ls.open()
+	if nil != err {
+		return nil, err
+	}
+	return signer.Sign(rand, digest, opts)
+}
+
+// used by backends to ask for PINs and passphrases; set by the commands
+var PinPrompt func(prompt string) (string, error)
+
+func askPin(prompt string) (string, error) {
+	if nil == PinPrompt {
+		return "", fmt.Errorf("No PIN available for %s", prompt)
+	}
+	return PinPrompt(prompt)
+}
@@ -0,0 +1,80 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+)
+
+func registerTestSigner(t *testing.T, key crypto.Signer) *int {
+	opened := new(int)
+	Register("test", func(reference string) (crypto.Signer, error) {
+		*opened++
+		return key, nil
+	})
+	t.Cleanup(func() {
+		openersMutex.Lock()
+		delete(openers, "test")
+		openersMutex.Unlock()
+	})
+	return opened
+}
+
+func TestOpenLazy(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	opened := registerTestSigner(t, key)
+
+	lazy := OpenLazy("test:key", key.Public())
+	if !key.PublicKey.Equal(lazy.Public()) {
+		t.Errorf("Wrong public key")
+	}
+	if 0 != *opened {
+		t.Errorf("Signer opened before signing")
+	}
+
+	digest := sha256.Sum256([]byte("payload"))
+	for i := 0; i < 2; i++ {
+		sig, err := lazy.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if !ecdsa.VerifyASN1(&key.PublicKey, digest[:], sig) {
+			t.Errorf("Invalid signature")
+		}
+	}
+	if 1 != *opened {
+		t.Errorf("Signer opened %d times, expected once", *opened)
+	}
+}
+
+func TestOpenLazyWrongKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	registerTestSigner(t, other)
+
+	digest := sha256.Sum256([]byte("payload"))
+	if _, err := OpenLazy("test:key", key.Public()).Sign(rand.Reader, digest[:], crypto.SHA256); nil == err {
+		t.Errorf("Signing with a different key should fail")
+	}
+}
+
+func TestOpenUnknownScheme(t *testing.T) {
+	if _, err := Open("unknown:key"); nil == err {
+		t.Errorf("Opening an unknown scheme should fail")
+	}
+	if "" != Scheme("no-scheme") || "pkcs11" != Scheme("pkcs11:token=acme") {
+		t.Errorf("Wrong scheme")
+	}
+}
@@ -0,0 +1,263 @@
+package storage_fs
+
+import (
+	"fmt"
+	i "github.com/stbuehler/go-acme-client/storage_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// implementations for i.StorageAuthorization
+// --------------------------------------------------------------------
+
+func (sauth *fsStorageAuthorization) Storage() i.Storage {
+	return sauth.storage
+}
+
+func (sauth *fsStorageAuthorization) StorageDirectory() i.StorageDirectory {
+	return sauth.registration.directory
+}
+
+func (sauth *fsStorageAuthorization) Directory() *types.Directory {
+	return sauth.StorageDirectory().Directory()
+}
+
+func (sauth *fsStorageAuthorization) StorageRegistration() i.StorageRegistration {
+	return sauth.registration
+}
+
+func (sauth *fsStorageAuthorization) Authorization() *types.Authorization {
+	return &sauth.authorization
+}
+
+func (sauth *fsStorageAuthorization) SetAuthorization(authorization types.Authorization) error {
+	if err := sauth.storage.locked(func() error {
+		return sauth.registration.saveAuthorization(sauth.id, authorization)
+	}); nil != err {
+		return err
+	}
+	sauth.authorization = authorization
+	return nil
+}
+
+func (sauth *fsStorageAuthorization) Delete() error {
+	if err := sauth.storage.locked(func() error {
+		return os.Remove(entryPath(sauth.registration.authorizationsPath(), sauth.id))
+	}); nil != err {
+		return err
+	}
+	sauth.id = -1
+	sauth.authorization = types.Authorization{}
+	return nil
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.StorageAuthorization]
+// --------------------------------------------------------------------
+
+// --------------------------------------------------------------------
+// implementations for i.StorageRegistration
+// --------------------------------------------------------------------
+
+func (sreg *fsStorageRegistration) NewAuthorization(auth types.Authorization) (i.StorageAuthorization, error) {
+	if err := sreg.storage.locked(func() error {
+		if existing, err := sreg.findAuthorization(func(data *authorizationJson) bool {
+			return auth.Location == data.Location
+		}); nil != err {
+			return err
+		} else if 0 != existing {
+			return fmt.Errorf("Authorization %s already exists in storage", auth.Location)
+		}
+		id, err := nextId(sreg.authorizationsPath())
+		if nil != err {
+			return err
+		}
+		return sreg.saveAuthorization(id, auth)
+	}); nil != err {
+		return nil, err
+	}
+
+	return sreg.LoadAuthorization(auth.Location)
+}
+
+func (sreg *fsStorageRegistration) AuthorizationInfos() (i.AuthorizationInfos, error) {
+	return sreg.authorizationInfos(func(data *authorizationJson) bool {
+		return true
+	})
+}
+
+func (sreg *fsStorageRegistration) AuthorizationInfosWithStatus(status types.AuthorizationStatus) (i.AuthorizationInfos, error) {
+	return sreg.authorizationInfos(func(data *authorizationJson) bool {
+		return string(status) == data.Status
+	})
+}
+
+func (sreg *fsStorageRegistration) Authorizations() ([]i.StorageAuthorization, error) {
+	ids, err := listIds(sreg.authorizationsPath())
+	if nil != err {
+		return nil, err
+	}
+	result := []i.StorageAuthorization{}
+	for _, id := range ids {
+		if sauth, err := sreg.loadAuthorizationById(id); nil != err {
+			return nil, err
+		} else if nil != sauth {
+			result = append(result, sauth)
+		}
+	}
+	return result, nil
+}
+
+func (sreg *fsStorageRegistration) LoadAuthorization(locationOrDnsIdentifier string) (i.StorageAuthorization, error) {
+	if auth, err := sreg.LoadAuthorizationByURL(locationOrDnsIdentifier); nil != err {
+		return nil, err
+	} else if auth != nil {
+		return auth, nil
+	} else {
+		return sreg.LoadAuthorizationByDNS(locationOrDnsIdentifier)
+	}
+}
+
+func (sreg *fsStorageRegistration) LoadAuthorizationByURL(authorizationURL string) (i.StorageAuthorization, error) {
+	if sauth, err := sreg.loadAuthorization(func(data *authorizationJson) bool {
+		return authorizationURL == data.Location
+	}); nil != err || nil == sauth {
+		// make sure to create a nil interface from the nil pointer!
+		return nil, err
+	} else {
+		return sauth, nil
+	}
+}
+
+func (sreg *fsStorageRegistration) LoadAuthorizationByDNS(dnsIdentifier string) (i.StorageAuthorization, error) {
+	now := time.Now()
+	if sauth, err := sreg.loadAuthorization(func(data *authorizationJson) bool {
+		switch data.Status {
+		case "valid", "pending", "", "processing":
+		default:
+			return false
+		}
+		return dnsIdentifier == data.DNSName && (nil == data.Expires || data.Expires.After(now))
+	}); nil != err || nil == sauth {
+		// make sure to create a nil interface from the nil pointer!
+		return nil, err
+	} else {
+		return sauth, nil
+	}
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.StorageRegistration]
+// --------------------------------------------------------------------
+
+type fsStorageAuthorization struct {
+	storage       *fsStorage
+	registration  *fsStorageRegistration
+	id            int64
+	authorization types.Authorization
+}
+
+// content of authorizations/<id>.json; only JsonPem is encrypted
+type authorizationJson struct {
+	IdentifierType string
+	DNSName        string
+	Location       string
+	Status         string
+	Expires        *time.Time `json:",omitempty"`
+	JsonPem        string
+}
+
+func (sreg *fsStorageRegistration) authorizationsPath() string {
+	return filepath.Join(sreg.path(), "authorizations")
+}
+
+// calls filter for all authorizations (newest first) until it returns true
+// and returns the id of that authorization (or 0)
+func (sreg *fsStorageRegistration) findAuthorization(filter func(data *authorizationJson) bool) (int64, error) {
+	ids, err := listIds(sreg.authorizationsPath())
+	if nil != err {
+		return 0, err
+	}
+	for _, id := range ids {
+		var data authorizationJson
+		if found, err := readJson(entryPath(sreg.authorizationsPath(), id), &data); nil != err {
+			return 0, err
+		} else if found && filter(&data) {
+			return id, nil
+		}
+	}
+	return 0, nil
+}
+
+func (sreg *fsStorageRegistration) loadAuthorization(filter func(data *authorizationJson) bool) (*fsStorageAuthorization, error) {
+	if id, err := sreg.findAuthorization(filter); nil != err || 0 == id {
+		return nil, err
+	} else {
+		return sreg.loadAuthorizationById(id)
+	}
+}
+
+func (sreg *fsStorageRegistration) authorizationInfos(filter func(data *authorizationJson) bool) (i.AuthorizationInfos, error) {
+	ids, err := listIds(sreg.authorizationsPath())
+	if nil != err {
+		return nil, err
+	}
+	regs := make(map[string][]i.AuthorizationInfo)
+	for _, id := range ids {
+		var data authorizationJson
+		if found, err := readJson(entryPath(sreg.authorizationsPath(), id), &data); nil != err {
+			return nil, err
+		} else if found && filter(&data) {
+			regs[data.DNSName] = append(regs[data.DNSName], i.AuthorizationInfo{
+				IdentifierType: types.IdentifierType(data.IdentifierType),
+				DNSIdentifier:  data.DNSName,
+				Location:       data.Location,
+				Status:         types.AuthorizationStatus(data.Status),
+				Expires:        data.Expires,
+			})
+		}
+	}
+	return i.AuthorizationInfos(regs), nil
+}
+
+func (sreg *fsStorageRegistration) loadAuthorizationById(id int64) (*fsStorageAuthorization, error) {
+	var data authorizationJson
+	if found, err := readJson(entryPath(sreg.authorizationsPath(), id), &data); nil != err || !found {
+		return nil, err
+	}
+
+	auth := &fsStorageAuthorization{
+		storage:      sreg.storage,
+		registration: sreg,
+		id:           id,
+	}
+
+	if err := auth.authorization.Import(
+		types.AuthorizationExport{
+			JsonPem: pemFromJson(data.JsonPem),
+		}, sreg.storage.passwordPrompt); nil != err {
+		return nil, err
+	}
+
+	return auth, nil
+}
+
+// needs to be called with the storage lock held
+func (sreg *fsStorageRegistration) saveAuthorization(id int64, auth types.Authorization) error {
+	export, err := auth.Export(sreg.storage.lastPassword())
+	if nil != err {
+		return err
+	}
+
+	return writeJson(entryPath(sreg.authorizationsPath(), id), authorizationJson{
+		IdentifierType: string(auth.Resource.Identifier.Type),
+		DNSName:        auth.Name(),
+		Location:       auth.Location,
+		Status:         string(auth.Resource.Status),
+		Expires:        auth.Resource.Expires,
+		JsonPem:        string(export.JsonPem),
+	})
+}
@@ -0,0 +1,349 @@
+package storage_fs
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	i "github.com/stbuehler/go-acme-client/storage_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/utils"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// implementations for i.StorageCertificate
+// --------------------------------------------------------------------
+
+func (scert *fsStorageCertificate) Storage() i.Storage {
+	return scert.storage
+}
+
+func (scert *fsStorageCertificate) StorageDirectory() i.StorageDirectory {
+	return scert.registration.directory
+}
+
+func (scert *fsStorageCertificate) Directory() *types.Directory {
+	return scert.StorageDirectory().Directory()
+}
+
+func (scert *fsStorageCertificate) StorageRegistration() i.StorageRegistration {
+	return scert.registration
+}
+
+func (scert *fsStorageCertificate) Certificate() *types.Certificate {
+	return &scert.certificate
+}
+
+func (scert *fsStorageCertificate) SetCertificate(certificate types.Certificate) error {
+	if err := scert.storage.locked(func() error {
+		if err := scert.registration.checkUniqueCertificate(scert.id, certificate); nil != err {
+			return err
+		}
+		return scert.registration.saveCertificate(scert.id, certificate)
+	}); nil != err {
+		return err
+	}
+	scert.certificate = certificate
+	return nil
+}
+
+func (scert *fsStorageCertificate) Delete() error {
+	if err := scert.storage.locked(func() error {
+		return os.Remove(entryPath(scert.registration.certificatesPath(), scert.id))
+	}); nil != err {
+		return err
+	}
+	scert.id = -1
+	scert.certificate = types.Certificate{}
+	return nil
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.StorageCertificate]
+// --------------------------------------------------------------------
+
+// --------------------------------------------------------------------
+// implementations for i.StorageRegistration
+// --------------------------------------------------------------------
+
+func (sreg *fsStorageRegistration) NewCertificate(cert types.Certificate) (i.StorageCertificate, error) {
+	if err := sreg.storage.locked(func() error {
+		if err := sreg.checkUniqueCertificate(0, cert); nil != err {
+			return err
+		}
+		id, err := nextId(sreg.certificatesPath())
+		if nil != err {
+			return err
+		}
+		return sreg.saveCertificate(id, cert)
+	}); nil != err {
+		return nil, err
+	}
+
+	return sreg.LoadCertificate(cert.Location)
+}
+
+func (sreg *fsStorageRegistration) CertificateInfos() ([]i.CertificateInfo, error) {
+	return sreg.certificateInfos(currentCertificate)
+}
+
+func (sreg *fsStorageRegistration) CertificateInfosAll() ([]i.CertificateInfo, error) {
+	return sreg.certificateInfos(anyCertificate)
+}
+
+func (sreg *fsStorageRegistration) Certificates() ([]i.StorageCertificate, error) {
+	return sreg.certificates(currentCertificate)
+}
+
+func (sreg *fsStorageRegistration) CertificatesAll() ([]i.StorageCertificate, error) {
+	return sreg.certificates(anyCertificate)
+}
+
+func (sreg *fsStorageRegistration) LoadCertificate(locationOrName string) (i.StorageCertificate, error) {
+	if id, err := sreg.findCertificate(func(data *certificateJson) bool {
+		return locationOrName == data.Location || (0 != len(data.Name) && locationOrName == data.Name)
+	}); nil != err || 0 == id {
+		return nil, err
+	} else if cert, err := sreg.loadCertificateById(id); nil != err || nil == cert {
+		// make sure to create a nil interface from the nil pointer!
+		return nil, err
+	} else {
+		return cert, nil
+	}
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.StorageRegistration]
+// --------------------------------------------------------------------
+
+type fsStorageCertificate struct {
+	storage      *fsStorage
+	registration *fsStorageRegistration
+	id           int64
+	certificate  types.Certificate
+}
+
+// content of certificates/<id>.json; only the private key is encrypted
+type certificateJson struct {
+	Name               string `json:",omitempty"`
+	Revoked            bool
+	Expires            time.Time
+	Location           string
+	LinkIssuer         string
+	CertificatePem     string
+	PrivateKeyPem      string           `json:",omitempty"`
+	ChainPem           string           `json:",omitempty"`
+	AlternateChainsPem []string         `json:",omitempty"`
+	RenewalInfo        *renewalInfoJson `json:",omitempty"`
+}
+
+// types.RenewalInfo doesn't serialize RetryAfter
+type renewalInfoJson struct {
+	Start          time.Time
+	End            time.Time
+	RetryAfter     *time.Time `json:",omitempty"`
+	ExplanationURL string     `json:",omitempty"`
+	RenewalTime    *time.Time `json:",omitempty"`
+}
+
+func renewalInfoToJson(info *types.RenewalInfo) *renewalInfoJson {
+	if nil == info {
+		return nil
+	}
+	result := &renewalInfoJson{
+		Start:          info.SuggestedWindow.Start,
+		End:            info.SuggestedWindow.End,
+		ExplanationURL: info.ExplanationURL,
+	}
+	if !info.RetryAfter.IsZero() {
+		result.RetryAfter = &info.RetryAfter
+	}
+	if !info.RenewalTime.IsZero() {
+		result.RenewalTime = &info.RenewalTime
+	}
+	return result
+}
+
+func (info *renewalInfoJson) renewalInfo() *types.RenewalInfo {
+	if nil == info {
+		return nil
+	}
+	result := &types.RenewalInfo{
+		SuggestedWindow: types.RenewalWindow{
+			Start: info.Start,
+			End:   info.End,
+		},
+		ExplanationURL: info.ExplanationURL,
+	}
+	if nil != info.RetryAfter {
+		result.RetryAfter = *info.RetryAfter
+	}
+	if nil != info.RenewalTime {
+		result.RenewalTime = *info.RenewalTime
+	}
+	return result
+}
+
+func anyCertificate(data *certificateJson) bool {
+	return true
+}
+
+// not revoked and not expired
+func currentCertificate(data *certificateJson) bool {
+	return !data.Revoked && data.Expires.After(time.Now())
+}
+
+func (sreg *fsStorageRegistration) certificatesPath() string {
+	return filepath.Join(sreg.path(), "certificates")
+}
+
+// locations and (non-empty) names are unique per registration; ignores the
+// certificate with the given id
+func (sreg *fsStorageRegistration) checkUniqueCertificate(id int64, cert types.Certificate) error {
+	if other, err := sreg.findCertificate(func(data *certificateJson) bool {
+		return cert.Location == data.Location || (0 != len(cert.Name) && cert.Name == data.Name)
+	}); nil != err {
+		return err
+	} else if 0 != other && other != id {
+		return fmt.Errorf("Certificate %#v (%s) conflicts with an existing certificate in storage", cert.Name, cert.Location)
+	}
+	return nil
+}
+
+func (sreg *fsStorageRegistration) findCertificate(filter func(data *certificateJson) bool) (int64, error) {
+	ids, err := listIds(sreg.certificatesPath())
+	if nil != err {
+		return 0, err
+	}
+	for _, id := range ids {
+		var data certificateJson
+		if found, err := readJson(entryPath(sreg.certificatesPath(), id), &data); nil != err {
+			return 0, err
+		} else if found && filter(&data) {
+			return id, nil
+		}
+	}
+	return 0, nil
+}
+
+func (sreg *fsStorageRegistration) certificateInfos(filter func(data *certificateJson) bool) ([]i.CertificateInfo, error) {
+	ids, err := listIds(sreg.certificatesPath())
+	if nil != err {
+		return nil, err
+	}
+	var certs []i.CertificateInfo
+	for _, id := range ids {
+		var data certificateJson
+		if found, err := readJson(entryPath(sreg.certificatesPath(), id), &data); nil != err {
+			return nil, err
+		} else if !found || !filter(&data) {
+			continue
+		}
+
+		info := i.CertificateInfo{
+			Name:        data.Name,
+			Revoked:     data.Revoked,
+			Location:    data.Location,
+			LinkIssuer:  data.LinkIssuer,
+			RenewalInfo: data.RenewalInfo.renewalInfo(),
+		}
+
+		// ignore errors in certificate
+		if certBlock, _ := pem.Decode([]byte(data.CertificatePem)); nil == certBlock || certBlock.Type != "CERTIFICATE" {
+			utils.Debugf("Couldn't decode certificate %v", data.Location)
+		} else if cert, err := x509.ParseCertificate(certBlock.Bytes); nil != err {
+			utils.Debugf("Couldn't parse certificate %v: %v", data.Location, err)
+		} else {
+			info.Certificate = cert
+		}
+
+		certs = append(certs, info)
+	}
+	return certs, nil
+}
+
+func (sreg *fsStorageRegistration) certificates(filter func(data *certificateJson) bool) ([]i.StorageCertificate, error) {
+	ids, err := listIds(sreg.certificatesPath())
+	if nil != err {
+		return nil, err
+	}
+	result := []i.StorageCertificate{}
+	for _, id := range ids {
+		var data certificateJson
+		if found, err := readJson(entryPath(sreg.certificatesPath(), id), &data); nil != err {
+			return nil, err
+		} else if !found || !filter(&data) {
+			continue
+		}
+		if scert, err := sreg.loadCertificateById(id); nil != err {
+			return nil, err
+		} else if nil != scert {
+			result = append(result, scert)
+		}
+	}
+	return result, nil
+}
+
+func (sreg *fsStorageRegistration) loadCertificateById(id int64) (*fsStorageCertificate, error) {
+	var data certificateJson
+	if found, err := readJson(entryPath(sreg.certificatesPath(), id), &data); nil != err || !found {
+		return nil, err
+	}
+
+	var alternateChainsPem [][]byte
+	for _, chain := range data.AlternateChainsPem {
+		alternateChainsPem = append(alternateChainsPem, []byte(chain))
+	}
+
+	cert := &fsStorageCertificate{
+		storage:      sreg.storage,
+		registration: sreg,
+		id:           id,
+	}
+
+	if err := cert.certificate.Import(
+		types.CertificateExport{
+			Name:           data.Name,
+			Revoked:        data.Revoked,
+			CertificatePem: pemFromJson(data.CertificatePem),
+			PrivateKeyPem:  pemFromJson(data.PrivateKeyPem),
+			Location:       data.Location,
+			LinkIssuer:     data.LinkIssuer,
+			ChainPem:       pemFromJson(data.ChainPem),
+
+			AlternateChainsPem: alternateChainsPem,
+		}, sreg.storage.passwordPrompt); nil != err {
+		return nil, err
+	}
+	cert.certificate.RenewalInfo = data.RenewalInfo.renewalInfo()
+
+	return cert, nil
+}
+
+// needs to be called with the storage lock held
+func (sreg *fsStorageRegistration) saveCertificate(id int64, cert types.Certificate) error {
+	export, err := cert.Export(sreg.storage.lastPassword())
+	if nil != err {
+		return err
+	}
+
+	var alternateChainsPem []string
+	for _, chain := range export.AlternateChainsPem {
+		alternateChainsPem = append(alternateChainsPem, string(chain))
+	}
+
+	return writeJson(entryPath(sreg.certificatesPath(), id), certificateJson{
+		Name:               export.Name,
+		Revoked:            export.Revoked,
+		Expires:            cert.Certificate.NotAfter,
+		Location:           export.Location,
+		LinkIssuer:         export.LinkIssuer,
+		CertificatePem:     string(export.CertificatePem),
+		PrivateKeyPem:      string(export.PrivateKeyPem),
+		ChainPem:           string(export.ChainPem),
+		AlternateChainsPem: alternateChainsPem,
+		RenewalInfo:        renewalInfoToJson(cert.RenewalInfo),
+	})
+}
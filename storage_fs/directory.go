@@ -0,0 +1,147 @@
+package storage_fs
+
+import (
+	"fmt"
+	i "github.com/stbuehler/go-acme-client/storage_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"os"
+)
+
+// --------------------------------------------------------------------
+// implementations for i.StorageDirectory
+// --------------------------------------------------------------------
+
+func (sdir *fsStorageDirectory) Storage() i.Storage {
+	return sdir.storage
+}
+
+func (sdir *fsStorageDirectory) StorageDirectory() i.StorageDirectory {
+	return sdir
+}
+
+func (sdir *fsStorageDirectory) Directory() *types.Directory {
+	return &sdir.directory
+}
+
+func (sdir *fsStorageDirectory) SetDirectory(directory types.Directory) error {
+	if err := sdir.check(); nil != err {
+		return err
+	}
+	if err := sdir.storage.locked(func() error {
+		return writeJson(sdir.filename(), directory)
+	}); nil != err {
+		return err
+	}
+	sdir.directory = directory
+	return nil
+}
+
+// in registration.go:
+// func (sdir *fsStorageDirectory) NewRegistration(registration types.Registration) (i.StorageRegistration, error)
+// func (sdir *fsStorageDirectory) RegistrationList() (i.RegistrationList, error)
+// func (sdir *fsStorageDirectory) LoadRegistration(name string) (i.StorageRegistration, error)
+
+func (sdir *fsStorageDirectory) Delete() error {
+	if err := sdir.check(); nil != err {
+		return err
+	}
+	if err := sdir.storage.locked(func() error {
+		return os.Remove(sdir.filename())
+	}); nil != err {
+		return err
+	}
+	sdir.id = -1
+	sdir.directory = types.Directory{}
+	return nil
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.StorageDirectory]
+// --------------------------------------------------------------------
+
+// --------------------------------------------------------------------
+// implementations for i.Storage
+// --------------------------------------------------------------------
+
+func (storage *fsStorage) LoadDirectory(rootURL string) (i.StorageDirectory, error) {
+	if sdir, err := storage.findDirectory(rootURL); nil != err || nil == sdir {
+		// make sure to create a nil interface from the nil pointer!
+		return nil, err
+	} else {
+		return sdir, nil
+	}
+}
+
+func (storage *fsStorage) NewDirectory(directory types.Directory) (i.StorageDirectory, error) {
+	var sdir *fsStorageDirectory
+	if err := storage.locked(func() error {
+		if existing, err := storage.findDirectory(directory.RootURL); nil != err {
+			return err
+		} else if nil != existing {
+			return fmt.Errorf("Directory %s already exists in storage", directory.RootURL)
+		}
+		id, err := nextId(storage.directoriesPath())
+		if nil != err {
+			return err
+		}
+		sdir = &fsStorageDirectory{
+			storage:   storage,
+			id:        id,
+			directory: directory,
+		}
+		return writeJson(sdir.filename(), directory)
+	}); nil != err {
+		return nil, err
+	}
+	return sdir, nil
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.Storage]
+// --------------------------------------------------------------------
+
+type fsStorageDirectory struct {
+	storage   *fsStorage
+	id        int64
+	directory types.Directory
+}
+
+func (sdir *fsStorageDirectory) check() error {
+	if 0 == len(sdir.directory.RootURL) {
+		return fmt.Errorf("Directory has no root url, cannot be saved")
+	}
+	if sdir.id <= 0 {
+		return fmt.Errorf("Directory has invalid id %d, cannot be saved", sdir.id)
+	}
+	return nil
+}
+
+func (sdir *fsStorageDirectory) filename() string {
+	return entryPath(sdir.storage.directoriesPath(), sdir.id)
+}
+
+func (storage *fsStorage) loadDirectoryById(directory_id int64) (*fsStorageDirectory, error) {
+	sdir := &fsStorageDirectory{
+		storage: storage,
+		id:      directory_id,
+	}
+	if found, err := readJson(sdir.filename(), &sdir.directory); nil != err || !found {
+		return nil, err
+	}
+	return sdir, nil
+}
+
+func (storage *fsStorage) findDirectory(rootURL string) (*fsStorageDirectory, error) {
+	ids, err := listIds(storage.directoriesPath())
+	if nil != err {
+		return nil, err
+	}
+	for _, id := range ids {
+		if sdir, err := storage.loadDirectoryById(id); nil != err {
+			return nil, err
+		} else if nil != sdir && rootURL == sdir.directory.RootURL {
+			return sdir, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,93 @@
+package storage_fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/utils"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func entryPath(dir string, id int64) string {
+	return filepath.Join(dir, strconv.FormatInt(id, 10)+".json")
+}
+
+// returns false if the file doesn't exist
+func readJson(filename string, value interface{}) (bool, error) {
+	data, err := ioutil.ReadFile(filename)
+	if nil != err {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := json.Unmarshal(data, value); nil != err {
+		return false, fmt.Errorf("Couldn't parse %s: %s", filename, err)
+	}
+	return true, nil
+}
+
+func writeJson(filename string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "\t")
+	if nil != err {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); nil != err {
+		return err
+	}
+	_, err = utils.WriteFileAtomic(filename, append(data, '\n'), 0600, -1, -1)
+	return err
+}
+
+// ids of the entries (files "<id>.json" or directories "<id>"), newest
+// (highest id) first
+func listIds(dir string) ([]int64, error) {
+	files, err := ioutil.ReadDir(dir)
+	if nil != err {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var ids []int64
+	for _, file := range files {
+		name := file.Name()
+		if !file.IsDir() {
+			if !strings.HasSuffix(name, ".json") {
+				continue
+			}
+			name = strings.TrimSuffix(name, ".json")
+		}
+		// skips temporary files too
+		if id, err := strconv.ParseInt(name, 10, 64); nil == err && id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(a, b int) bool { return ids[a] > ids[b] })
+	return ids, nil
+}
+
+// needs to be called with the storage lock held
+func nextId(dir string) (int64, error) {
+	ids, err := listIds(dir)
+	if nil != err {
+		return 0, err
+	}
+	if 0 == len(ids) {
+		return 1, nil
+	}
+	return ids[0] + 1, nil
+}
+
+// PEM data is stored as string to keep the files readable; empty strings
+// are missing data
+func pemFromJson(data string) []byte {
+	if 0 == len(data) {
+		return nil
+	}
+	return []byte(data)
+}
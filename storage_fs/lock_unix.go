@@ -0,0 +1,27 @@
+//go:build !windows
+// +build !windows
+
+package storage_fs
+
+import (
+	"os"
+	"syscall"
+)
+
+// blocks until the (advisory) lock is acquired
+func lockFile(filename string) (*os.File, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0600)
+	if nil != err {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); nil != err {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func unlockFile(file *os.File) {
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	file.Close()
+}
@@ -0,0 +1,26 @@
+package storage_fs
+
+import (
+	"golang.org/x/sys/windows"
+	"os"
+)
+
+// blocks until the lock is acquired; the lock is released by windows when
+// the process exits, so a crashed run doesn't leave a stale lock behind
+func lockFile(filename string) (*os.File, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0600)
+	if nil != err {
+		return nil, err
+	}
+	overlapped := new(windows.Overlapped)
+	if err := windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, overlapped); nil != err {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func unlockFile(file *os.File) {
+	windows.UnlockFileEx(windows.Handle(file.Fd()), 0, 1, 0, new(windows.Overlapped))
+	file.Close()
+}
@@ -0,0 +1,214 @@
+package storage_fs
+
+import (
+	"fmt"
+	i "github.com/stbuehler/go-acme-client/storage_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// implementations for i.StorageOrder
+// --------------------------------------------------------------------
+
+func (sorder *fsStorageOrder) Storage() i.Storage {
+	return sorder.storage
+}
+
+func (sorder *fsStorageOrder) StorageDirectory() i.StorageDirectory {
+	return sorder.registration.directory
+}
+
+func (sorder *fsStorageOrder) Directory() *types.Directory {
+	return sorder.StorageDirectory().Directory()
+}
+
+func (sorder *fsStorageOrder) StorageRegistration() i.StorageRegistration {
+	return sorder.registration
+}
+
+func (sorder *fsStorageOrder) Order() *types.Order {
+	return &sorder.order
+}
+
+func (sorder *fsStorageOrder) SetOrder(order types.Order) error {
+	if err := sorder.storage.locked(func() error {
+		return sorder.registration.saveOrder(sorder.id, order)
+	}); nil != err {
+		return err
+	}
+	sorder.order = order
+	return nil
+}
+
+func (sorder *fsStorageOrder) Delete() error {
+	if err := sorder.storage.locked(func() error {
+		return os.Remove(entryPath(sorder.registration.ordersPath(), sorder.id))
+	}); nil != err {
+		return err
+	}
+	sorder.id = -1
+	sorder.order = types.Order{}
+	return nil
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.StorageOrder]
+// --------------------------------------------------------------------
+
+// --------------------------------------------------------------------
+// implementations for i.StorageRegistration
+// --------------------------------------------------------------------
+
+func (sreg *fsStorageRegistration) NewOrder(order types.Order) (i.StorageOrder, error) {
+	if err := sreg.storage.locked(func() error {
+		if existing, err := sreg.findOrder(order.Location); nil != err {
+			return err
+		} else if 0 != existing {
+			return fmt.Errorf("Order %s already exists in storage", order.Location)
+		}
+		id, err := nextId(sreg.ordersPath())
+		if nil != err {
+			return err
+		}
+		return sreg.saveOrder(id, order)
+	}); nil != err {
+		return nil, err
+	}
+
+	return sreg.LoadOrder(order.Location)
+}
+
+func (sreg *fsStorageRegistration) OrderInfos() ([]i.OrderInfo, error) {
+	ids, err := listIds(sreg.ordersPath())
+	if nil != err {
+		return nil, err
+	}
+	var orders []i.OrderInfo
+	for _, id := range ids {
+		var data orderJson
+		if found, err := readJson(entryPath(sreg.ordersPath(), id), &data); nil != err {
+			return nil, err
+		} else if found {
+			orders = append(orders, i.OrderInfo{
+				Location:    data.Location,
+				Status:      types.OrderStatus(data.Status),
+				Expires:     data.Expires,
+				Identifiers: data.Identifiers,
+			})
+		}
+	}
+	return orders, nil
+}
+
+func (sreg *fsStorageRegistration) Orders() ([]i.StorageOrder, error) {
+	ids, err := listIds(sreg.ordersPath())
+	if nil != err {
+		return nil, err
+	}
+	result := []i.StorageOrder{}
+	for _, id := range ids {
+		if sorder, err := sreg.loadOrderById(id); nil != err {
+			return nil, err
+		} else if nil != sorder {
+			result = append(result, sorder)
+		}
+	}
+	return result, nil
+}
+
+func (sreg *fsStorageRegistration) LoadOrder(location string) (i.StorageOrder, error) {
+	if id, err := sreg.findOrder(location); nil != err || 0 == id {
+		return nil, err
+	} else if sorder, err := sreg.loadOrderById(id); nil != err || nil == sorder {
+		// make sure to create a nil interface from the nil pointer!
+		return nil, err
+	} else {
+		return sorder, nil
+	}
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.StorageRegistration]
+// --------------------------------------------------------------------
+
+type fsStorageOrder struct {
+	storage      *fsStorage
+	registration *fsStorageRegistration
+	id           int64
+	order        types.Order
+}
+
+// content of orders/<id>.json; only JsonPem is encrypted
+type orderJson struct {
+	Identifiers []string
+	Location    string
+	Status      string
+	Expires     *time.Time `json:",omitempty"`
+	JsonPem     string
+}
+
+func (sreg *fsStorageRegistration) ordersPath() string {
+	return filepath.Join(sreg.path(), "orders")
+}
+
+func (sreg *fsStorageRegistration) findOrder(location string) (int64, error) {
+	ids, err := listIds(sreg.ordersPath())
+	if nil != err {
+		return 0, err
+	}
+	for _, id := range ids {
+		var data orderJson
+		if found, err := readJson(entryPath(sreg.ordersPath(), id), &data); nil != err {
+			return 0, err
+		} else if found && location == data.Location {
+			return id, nil
+		}
+	}
+	return 0, nil
+}
+
+func (sreg *fsStorageRegistration) loadOrderById(id int64) (*fsStorageOrder, error) {
+	var data orderJson
+	if found, err := readJson(entryPath(sreg.ordersPath(), id), &data); nil != err || !found {
+		return nil, err
+	}
+
+	order := &fsStorageOrder{
+		storage:      sreg.storage,
+		registration: sreg,
+		id:           id,
+	}
+
+	if err := order.order.Import(
+		types.OrderExport{
+			JsonPem: pemFromJson(data.JsonPem),
+		}, sreg.storage.passwordPrompt); nil != err {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// needs to be called with the storage lock held
+func (sreg *fsStorageRegistration) saveOrder(id int64, order types.Order) error {
+	export, err := order.Export(sreg.storage.lastPassword())
+	if nil != err {
+		return err
+	}
+
+	identifiers := make([]string, len(order.Resource.Identifiers))
+	for ndx, identifier := range order.Resource.Identifiers {
+		identifiers[ndx] = identifier.Value
+	}
+
+	return writeJson(entryPath(sreg.ordersPath(), id), orderJson{
+		Identifiers: identifiers,
+		Location:    order.Location,
+		Status:      string(order.Resource.Status),
+		Expires:     order.Resource.Expires,
+		JsonPem:     string(export.JsonPem),
+	})
+}
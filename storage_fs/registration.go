@@ -0,0 +1,281 @@
+package storage_fs
+
+import (
+	"fmt"
+	i "github.com/stbuehler/go-acme-client/storage_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// --------------------------------------------------------------------
+// implementations for i.StorageRegistration
+// --------------------------------------------------------------------
+
+func (sreg *fsStorageRegistration) Storage() i.Storage {
+	return sreg.storage
+}
+
+func (sreg *fsStorageRegistration) StorageDirectory() i.StorageDirectory {
+	return sreg.directory
+}
+
+func (sreg *fsStorageRegistration) Directory() *types.Directory {
+	return sreg.directory.Directory()
+}
+
+func (sreg *fsStorageRegistration) StorageRegistration() i.StorageRegistration {
+	return sreg
+}
+
+func (sreg *fsStorageRegistration) Registration() *types.Registration {
+	return &sreg.registration
+}
+
+func (sreg *fsStorageRegistration) SetRegistration(registration types.Registration) error {
+	if err := sreg.storage.locked(func() error {
+		if other, err := sreg.storage.findRegistration(registration.Name); nil != err {
+			return err
+		} else if 0 != other && other != sreg.id {
+			return fmt.Errorf("Registration %#v already exists in storage", registration.Name)
+		}
+		return sreg.storage.saveRegistration(sreg.id, sreg.directory.id, registration)
+	}); nil != err {
+		return err
+	}
+	sreg.registration = registration
+	return nil
+}
+
+// in authorization.go
+// func (sreg *fsStorageRegistration) NewAuthorization(auth types.Authorization) (i.StorageAuthorization, error)
+// func (sreg *fsStorageRegistration) AuthorizationInfos() (i.AuthorizationInfos, error)
+// func (sreg *fsStorageRegistration) AuthorizationInfosWithStatus(status types.AuthorizationStatus) (i.AuthorizationInfos, error)
+// func (sreg *fsStorageRegistration) Authorizations() ([]i.StorageAuthorization, error)
+// func (sreg *fsStorageRegistration) LoadAuthorization(locationOrDnsIdentifier string) (i.StorageAuthorization, error)
+// func (sreg *fsStorageRegistration) LoadAuthorizationByURL(authorizationURL string) (i.StorageAuthorization, error)
+// func (sreg *fsStorageRegistration) LoadAuthorizationByDNS(dnsIdentifier string) (i.StorageAuthorization, error)
+
+// in certificate.go
+// func (sreg *fsStorageRegistration) NewCertificate(cert types.Certificate) (i.StorageCertificate, error)
+// func (sreg *fsStorageRegistration) CertificateInfos() ([]CertificateInfo, error)
+// func (sreg *fsStorageRegistration) Certificates() ([]i.StorageCertificate, error)
+// func (sreg *fsStorageRegistration) LoadCertificate(location string) (i.StorageCertificate, error)
+
+// in order.go
+// func (sreg *fsStorageRegistration) NewOrder(order types.Order) (i.StorageOrder, error)
+// func (sreg *fsStorageRegistration) OrderInfos() ([]i.OrderInfo, error)
+// func (sreg *fsStorageRegistration) Orders() ([]i.StorageOrder, error)
+// func (sreg *fsStorageRegistration) LoadOrder(location string) (i.StorageOrder, error)
+
+// also deletes all authorizations, certificates and orders of the
+// registration
+func (sreg *fsStorageRegistration) Delete() error {
+	if err := sreg.storage.locked(func() error {
+		// move it out of the way first, so a partially deleted
+		// registration isn't visible
+		path := sreg.path()
+		deleted := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".deleted")
+		if err := os.Rename(path, deleted); nil != err {
+			return err
+		}
+		return os.RemoveAll(deleted)
+	}); nil != err {
+		return err
+	}
+	sreg.id = -1
+	sreg.registration = types.Registration{}
+	return nil
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.StorageRegistration]
+// --------------------------------------------------------------------
+
+// --------------------------------------------------------------------
+// implementations for i.Storage
+// --------------------------------------------------------------------
+
+func (storage *fsStorage) RegistrationList() (i.RegistrationList, error) {
+	return storage.registrationList(nil)
+}
+
+func (storage *fsStorage) LoadRegistration(name string) (i.StorageRegistration, error) {
+	if sreg, err := storage.loadRegistrationByName(name, nil); nil != err || nil == sreg {
+		// make sure to create a nil interface from the nil pointer!
+		return nil, err
+	} else {
+		return sreg, nil
+	}
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.Storage]
+// --------------------------------------------------------------------
+
+// --------------------------------------------------------------------
+// implementations for i.StorageDirectory
+// --------------------------------------------------------------------
+
+func (sdir *fsStorageDirectory) NewRegistration(registration types.Registration) (i.StorageRegistration, error) {
+	if err := sdir.storage.locked(func() error {
+		if existing, err := sdir.storage.findRegistration(registration.Name); nil != err {
+			return err
+		} else if 0 != existing {
+			return fmt.Errorf("Registration %#v already exists in storage", registration.Name)
+		}
+		id, err := nextId(sdir.storage.registrationsPath())
+		if nil != err {
+			return err
+		}
+		return sdir.storage.saveRegistration(id, sdir.id, registration)
+	}); nil != err {
+		return nil, err
+	}
+
+	return sdir.LoadRegistration(registration.Name)
+}
+
+func (sdir *fsStorageDirectory) RegistrationList() (i.RegistrationList, error) {
+	return sdir.storage.registrationList(sdir)
+}
+
+func (sdir *fsStorageDirectory) LoadRegistration(name string) (i.StorageRegistration, error) {
+	if sreg, err := sdir.storage.loadRegistrationByName(name, sdir); nil != err || nil == sreg {
+		// make sure to create a nil interface from the nil pointer!
+		return nil, err
+	} else if sreg.directory != sdir {
+		// not found in this directory, and names are unique across directories
+		return nil, nil
+	} else {
+		return sreg, nil
+	}
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.StorageDirectory]
+// --------------------------------------------------------------------
+
+type fsStorageRegistration struct {
+	storage      *fsStorage
+	directory    *fsStorageDirectory
+	id           int64
+	registration types.Registration
+}
+
+// content of registration.json
+type registrationJson struct {
+	Directory     int64
+	Name          string
+	Location      string
+	JsonPem       string
+	SigningKeyPem string
+}
+
+func registrationPath(storage *fsStorage, id int64) string {
+	return filepath.Join(storage.registrationsPath(), strconv.FormatInt(id, 10))
+}
+
+func (sreg *fsStorageRegistration) path() string {
+	return registrationPath(sreg.storage, sreg.id)
+}
+
+func readRegistrationJson(storage *fsStorage, id int64) (*registrationJson, error) {
+	var data registrationJson
+	if found, err := readJson(filepath.Join(registrationPath(storage, id), "registration.json"), &data); nil != err || !found {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// returns the id of the registration with the given name or 0
+func (storage *fsStorage) findRegistration(name string) (int64, error) {
+	ids, err := listIds(storage.registrationsPath())
+	if nil != err {
+		return 0, err
+	}
+	for _, id := range ids {
+		if data, err := readRegistrationJson(storage, id); nil != err {
+			return 0, err
+		} else if nil != data && name == data.Name {
+			return id, nil
+		}
+	}
+	return 0, nil
+}
+
+func (storage *fsStorage) registrationList(sdir *fsStorageDirectory) (i.RegistrationList, error) {
+	ids, err := listIds(storage.registrationsPath())
+	if nil != err {
+		return nil, err
+	}
+	regs := make(map[string]string)
+	for _, id := range ids {
+		if data, err := readRegistrationJson(storage, id); nil != err {
+			return nil, err
+		} else if nil != data && (nil == sdir || sdir.id == data.Directory) {
+			regs[data.Name] = data.Location
+		}
+	}
+	return i.RegistrationList(regs), nil
+}
+
+func (storage *fsStorage) loadRegistrationByName(name string, sdirHint *fsStorageDirectory) (*fsStorageRegistration, error) {
+	if id, err := storage.findRegistration(name); nil != err || 0 == id {
+		return nil, err
+	} else {
+		return storage.loadRegistrationById(id, sdirHint)
+	}
+}
+
+func (storage *fsStorage) loadRegistrationById(registration_id int64, sdirHint *fsStorageDirectory) (*fsStorageRegistration, error) {
+	data, err := readRegistrationJson(storage, registration_id)
+	if nil != err || nil == data {
+		return nil, err
+	}
+
+	if nil == sdirHint || data.Directory != sdirHint.id {
+		if sdir, err := storage.loadDirectoryById(data.Directory); nil != err {
+			return nil, err
+		} else if nil == sdir {
+			return nil, fmt.Errorf("Couldn't find directory %d for registration", data.Directory)
+		} else {
+			sdirHint = sdir
+		}
+	}
+
+	reg := &fsStorageRegistration{
+		storage:   storage,
+		directory: sdirHint,
+		id:        registration_id,
+	}
+
+	if err := reg.registration.Import(
+		types.RegistrationExport{
+			JsonPem:       pemFromJson(data.JsonPem),
+			SigningKeyPem: pemFromJson(data.SigningKeyPem),
+			Location:      data.Location,
+			Name:          data.Name,
+		}, storage.passwordPrompt); nil != err {
+		return nil, err
+	}
+
+	return reg, nil
+}
+
+// needs to be called with the storage lock held
+func (storage *fsStorage) saveRegistration(id int64, directory_id int64, reg types.Registration) error {
+	export, err := reg.Export(storage.lastPassword())
+	if nil != err {
+		return err
+	}
+
+	return writeJson(filepath.Join(registrationPath(storage, id), "registration.json"), registrationJson{
+		Directory:     directory_id,
+		Name:          export.Name,
+		Location:      export.Location,
+		JsonPem:       string(export.JsonPem),
+		SigningKeyPem: string(export.SigningKeyPem),
+	})
+}
@@ -0,0 +1,86 @@
+package storage_fs
+
+import (
+	i "github.com/stbuehler/go-acme-client/storage_interface"
+	"github.com/stbuehler/go-acme-client/ui"
+	"os"
+	"path/filepath"
+)
+
+// Storage in a directory tree; every entry is a JSON file (private data as
+// encrypted PEM blocks in it), so the storage can be kept in backups and
+// version control:
+//
+//	directories/<id>.json
+//	registrations/<id>/registration.json
+//	registrations/<id>/authorizations/<id>.json
+//	registrations/<id>/certificates/<id>.json
+//	registrations/<id>/orders/<id>.json
+//
+// ids are assigned in ascending order, listings return the newest entries
+// first. Files are replaced atomically, and all changes are made while
+// holding a lock on the "lock" file.
+
+// --------------------------------------------------------------------
+// implementations for i.Storage
+// --------------------------------------------------------------------
+
+func (storage *fsStorage) SetPassword(password string) {
+	storage.passwordPrompt = func() (string, error) {
+		return password, nil
+	}
+	storage.lastPassword = func() string {
+		return password
+	}
+}
+
+// in directory.go:
+// func (storage *fsStorage) LoadDirectory(rootURL string) (StorageDirectory, error)
+// func (storage *fsStorage) NewDirectory(directory types.Directory) (StorageDirectory, error)
+
+// in registration.go:
+// func (storage *fsStorage) RegistrationList() (RegistrationList, error)
+// func (storage *fsStorage) LoadRegistration(name string) (StorageRegistration, error)
+
+// --------------------------------------------------------------------
+// end [implementations for i.Storage]
+// --------------------------------------------------------------------
+
+type fsStorage struct {
+	path           string
+	passwordPrompt func() (string, error)
+	lastPassword   func() string
+}
+
+func Open(UI ui.UserInterface, path string) (i.Storage, error) {
+	pwPrompt, lastPassword := UI.PasswordPromptOnce("Enter storage password")
+	storage := &fsStorage{
+		path:           path,
+		passwordPrompt: pwPrompt,
+		lastPassword:   lastPassword,
+	}
+	for _, dir := range []string{storage.path, storage.directoriesPath(), storage.registrationsPath()} {
+		if err := os.MkdirAll(dir, 0700); nil != err {
+			return nil, err
+		}
+	}
+	return storage, nil
+}
+
+func (storage *fsStorage) directoriesPath() string {
+	return filepath.Join(storage.path, "directories")
+}
+
+func (storage *fsStorage) registrationsPath() string {
+	return filepath.Join(storage.path, "registrations")
+}
+
+// run a change while holding the storage lock
+func (storage *fsStorage) locked(change func() error) error {
+	lock, err := lockFile(filepath.Join(storage.path, "lock"))
+	if nil != err {
+		return err
+	}
+	defer unlockFile(lock)
+	return change()
+}
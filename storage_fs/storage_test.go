@@ -0,0 +1,402 @@
+package storage_fs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	i "github.com/stbuehler/go-acme-client/storage_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestStorage(t *testing.T) (*fsStorage, i.StorageDirectory) {
+	storage, err := Open(ui.CLI, t.TempDir())
+	if nil != err {
+		t.Fatal(err)
+	}
+	storage.SetPassword("secret")
+	sdir, err := storage.NewDirectory(types.Directory{
+		RootURL: "https://acme.example.com/directory",
+		Resource: types.DirectoryResource{
+			NewNonce: "https://acme.example.com/new-nonce",
+		},
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	return storage.(*fsStorage), sdir
+}
+
+func newTestRegistration(t *testing.T, sdir i.StorageDirectory, name string) i.StorageRegistration {
+	skey, err := types.CreateSigningKey(utils.KeyEcdsa, utils.CurveP256, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	sreg, err := sdir.NewRegistration(types.Registration{
+		SigningKey: skey,
+		Location:   "https://acme.example.com/acct/" + name,
+		Name:       name,
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	return sreg
+}
+
+func testCertificate(t *testing.T, name string, location string, notAfter time.Time) types.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if nil != err {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if nil != err {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return types.Certificate{
+		Name:        name,
+		Certificate: cert,
+		PrivateKey:  &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer},
+		Location:    location,
+	}
+}
+
+func TestDirectories(t *testing.T) {
+	storage, sdir := openTestStorage(t)
+
+	if _, err := storage.NewDirectory(types.Directory{RootURL: "https://acme.example.com/directory"}); nil == err {
+		t.Errorf("Creating a directory twice should fail")
+	}
+
+	directory := *sdir.Directory()
+	directory.Resource.NewOrder = "https://acme.example.com/new-order"
+	if err := sdir.SetDirectory(directory); nil != err {
+		t.Fatal(err)
+	}
+	if loaded, err := storage.LoadDirectory("https://acme.example.com/directory"); nil != err || nil == loaded {
+		t.Fatalf("Couldn't load directory: %v", err)
+	} else if "https://acme.example.com/new-order" != loaded.Directory().Resource.NewOrder {
+		t.Errorf("Directory update wasn't stored: %+v", loaded.Directory().Resource)
+	}
+	if loaded, err := storage.LoadDirectory("https://other.example.com/directory"); nil != err || nil != loaded {
+		t.Errorf("Loading an unknown directory should return nil, got %v (%v)", loaded, err)
+	}
+
+	if err := sdir.Delete(); nil != err {
+		t.Fatal(err)
+	}
+	if loaded, err := storage.LoadDirectory("https://acme.example.com/directory"); nil != err || nil != loaded {
+		t.Errorf("Directory still loadable after delete: %v (%v)", loaded, err)
+	}
+}
+
+func TestRegistrations(t *testing.T) {
+	storage, sdir := openTestStorage(t)
+	sreg := newTestRegistration(t, sdir, "default")
+	newTestRegistration(t, sdir, "other")
+
+	// names are unique across directories
+	otherDir, err := storage.NewDirectory(types.Directory{RootURL: "https://other.example.com/directory"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := otherDir.NewRegistration(types.Registration{SigningKey: sreg.Registration().SigningKey, Name: "default"}); nil == err {
+		t.Errorf("Creating a registration with an existing name should fail")
+	}
+	reg := *sreg.Registration()
+	reg.Name = "other"
+	if err := sreg.SetRegistration(reg); nil == err {
+		t.Errorf("Renaming a registration to an existing name should fail")
+	}
+
+	if list, err := storage.RegistrationList(); nil != err {
+		t.Fatal(err)
+	} else if 2 != len(list) || "https://acme.example.com/acct/default" != list["default"] {
+		t.Errorf("Unexpected registration list %v", list)
+	}
+	if list, err := otherDir.RegistrationList(); nil != err || 0 != len(list) {
+		t.Errorf("Unexpected registration list %v (%v)", list, err)
+	}
+	if loaded, err := otherDir.LoadRegistration("default"); nil != err || nil != loaded {
+		t.Errorf("Registration loaded from the wrong directory: %v (%v)", loaded, err)
+	}
+
+	// the signing key is stored encrypted and survives the round trip
+	loaded, err := storage.LoadRegistration("default")
+	if nil != err || nil == loaded {
+		t.Fatalf("Couldn't load registration: %v", err)
+	}
+	expected, _ := sreg.Registration().SigningKey.GetPublicKey().Thumbprint(crypto.SHA256)
+	actual, _ := loaded.Registration().SigningKey.GetPublicKey().Thumbprint(crypto.SHA256)
+	if string(expected) != string(actual) {
+		t.Errorf("Loaded registration has a different signing key")
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(storage.path, "registrations", "1", "registration.json")); nil != err {
+		t.Fatal(err)
+	} else if !strings.Contains(string(data), "ENCRYPTED") {
+		t.Errorf("Registration isn't encrypted:\n%s", data)
+	}
+
+	// deleting a registration deletes its entries too
+	if _, err := sreg.NewOrder(types.Order{Location: "https://acme.example.com/order/1"}); nil != err {
+		t.Fatal(err)
+	}
+	if err := sreg.Delete(); nil != err {
+		t.Fatal(err)
+	}
+	if loaded, err := storage.LoadRegistration("default"); nil != err || nil != loaded {
+		t.Errorf("Registration still loadable after delete: %v (%v)", loaded, err)
+	}
+	if ids, err := listIds(storage.registrationsPath()); nil != err || 1 != len(ids) {
+		t.Errorf("Unexpected registrations %v after delete (%v)", ids, err)
+	}
+	// the name can be used again
+	newTestRegistration(t, sdir, "default")
+}
+
+func TestAuthorizations(t *testing.T) {
+	_, sdir := openTestStorage(t)
+	sreg := newTestRegistration(t, sdir, "default")
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	auths := []types.Authorization{
+		{
+			Resource: types.AuthorizationResource{
+				Identifier: types.Identifier{Type: types.IdentifierDNS, Value: "example.com"},
+				Status:     "valid",
+				Expires:    &past,
+			},
+			Location: "https://acme.example.com/authz/1",
+		},
+		{
+			Resource: types.AuthorizationResource{
+				Identifier: types.Identifier{Type: types.IdentifierDNS, Value: "example.com"},
+				Status:     "valid",
+				Expires:    &future,
+			},
+			Location: "https://acme.example.com/authz/2",
+		},
+		{
+			Resource: types.AuthorizationResource{
+				Identifier: types.Identifier{Type: types.IdentifierDNS, Value: "example.com"},
+				Status:     "invalid",
+				Expires:    &future,
+			},
+			Location: "https://acme.example.com/authz/3",
+		},
+	}
+	for _, auth := range auths {
+		if _, err := sreg.NewAuthorization(auth); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if _, err := sreg.NewAuthorization(auths[0]); nil == err {
+		t.Errorf("Creating an authorization with an existing location should fail")
+	}
+
+	// only the newest usable authorization
+	if sauth, err := sreg.LoadAuthorizationByDNS("example.com"); nil != err || nil == sauth {
+		t.Fatalf("Couldn't load authorization: %v", err)
+	} else if "https://acme.example.com/authz/2" != sauth.Authorization().Location {
+		t.Errorf("Loaded wrong authorization %s", sauth.Authorization().Location)
+	}
+	if infos, err := sreg.AuthorizationInfos(); nil != err || 3 != len(infos["example.com"]) {
+		t.Errorf("Unexpected authorization infos %v (%v)", infos, err)
+	} else if "https://acme.example.com/authz/3" != infos["example.com"][0].Location {
+		t.Errorf("Authorization infos aren't sorted newest first: %v", infos)
+	}
+	if infos, err := sreg.AuthorizationInfosWithStatus("invalid"); nil != err || 1 != len(infos["example.com"]) {
+		t.Errorf("Unexpected invalid authorization infos %v (%v)", infos, err)
+	}
+
+	sauth, err := sreg.LoadAuthorization("https://acme.example.com/authz/2")
+	if nil != err || nil == sauth {
+		t.Fatalf("Couldn't load authorization: %v", err)
+	}
+	auth := *sauth.Authorization()
+	auth.Resource.Status = "deactivated"
+	if err := sauth.SetAuthorization(auth); nil != err {
+		t.Fatal(err)
+	}
+	if loaded, err := sreg.LoadAuthorizationByDNS("example.com"); nil != err || nil != loaded {
+		t.Errorf("Deactivated authorization still loadable by name: %v (%v)", loaded, err)
+	}
+
+	if err := sauth.Delete(); nil != err {
+		t.Fatal(err)
+	}
+	if auths, err := sreg.Authorizations(); nil != err || 2 != len(auths) {
+		t.Errorf("Unexpected authorizations %v after delete (%v)", auths, err)
+	}
+}
+
+func TestCertificates(t *testing.T) {
+	storage, sdir := openTestStorage(t)
+	sreg := newTestRegistration(t, sdir, "default")
+
+	future := time.Now().Add(30 * 24 * time.Hour)
+	current := testCertificate(t, "example.com", "https://acme.example.com/cert/1", future)
+	if _, err := sreg.NewCertificate(current); nil != err {
+		t.Fatal(err)
+	}
+	expired := testCertificate(t, "", "https://acme.example.com/cert/2", time.Now().Add(-time.Hour))
+	if _, err := sreg.NewCertificate(expired); nil != err {
+		t.Fatal(err)
+	}
+
+	// locations and names are unique
+	if _, err := sreg.NewCertificate(testCertificate(t, "", "https://acme.example.com/cert/1", future)); nil == err {
+		t.Errorf("Creating a certificate with an existing location should fail")
+	}
+	if _, err := sreg.NewCertificate(testCertificate(t, "example.com", "https://acme.example.com/cert/3", future)); nil == err {
+		t.Errorf("Creating a certificate with an existing name should fail")
+	}
+
+	if infos, err := sreg.CertificateInfos(); nil != err || 1 != len(infos) {
+		t.Errorf("Unexpected certificate infos %v (%v)", infos, err)
+	} else if "example.com" != infos[0].Name || nil == infos[0].Certificate {
+		t.Errorf("Unexpected certificate info %+v", infos[0])
+	}
+	if infos, err := sreg.CertificateInfosAll(); nil != err || 2 != len(infos) {
+		t.Errorf("Unexpected certificate infos %v (%v)", infos, err)
+	}
+
+	scert, err := sreg.LoadCertificate("example.com")
+	if nil != err || nil == scert {
+		t.Fatalf("Couldn't load certificate: %v", err)
+	}
+	if nil == scert.Certificate().PrivateKey || string(current.PrivateKey.Bytes) != string(scert.Certificate().PrivateKey.Bytes) {
+		t.Errorf("Private key wasn't stored")
+	}
+	if data, err := ioutil.ReadFile(entryPath(filepath.Join(storage.path, "registrations", "1", "certificates"), 1)); nil != err {
+		t.Fatal(err)
+	} else if !strings.Contains(string(data), "ENCRYPTED") {
+		t.Errorf("Private key isn't encrypted:\n%s", data)
+	}
+
+	cert := *scert.Certificate()
+	cert.Revoked = true
+	if err := scert.SetCertificate(cert); nil != err {
+		t.Fatal(err)
+	}
+	if certs, err := sreg.Certificates(); nil != err || 0 != len(certs) {
+		t.Errorf("Revoked certificate still listed: %v (%v)", certs, err)
+	}
+
+	if err := scert.Delete(); nil != err {
+		t.Fatal(err)
+	}
+	if certs, err := sreg.CertificatesAll(); nil != err || 1 != len(certs) {
+		t.Errorf("Unexpected certificates %v after delete (%v)", certs, err)
+	}
+}
+
+func TestOrders(t *testing.T) {
+	_, sdir := openTestStorage(t)
+	sreg := newTestRegistration(t, sdir, "default")
+
+	order := types.Order{
+		Resource: types.OrderResource{
+			Status:      "pending",
+			Identifiers: []types.Identifier{{Type: types.IdentifierDNS, Value: "example.com"}},
+		},
+		Location: "https://acme.example.com/order/1",
+	}
+	if _, err := sreg.NewOrder(order); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := sreg.NewOrder(order); nil == err {
+		t.Errorf("Creating an order with an existing location should fail")
+	}
+	order.Location = "https://acme.example.com/order/2"
+	if _, err := sreg.NewOrder(order); nil != err {
+		t.Fatal(err)
+	}
+
+	if infos, err := sreg.OrderInfos(); nil != err || 2 != len(infos) {
+		t.Fatalf("Unexpected order infos %v (%v)", infos, err)
+	} else if "https://acme.example.com/order/2" != infos[0].Location || 1 != len(infos[0].Identifiers) || "example.com" != infos[0].Identifiers[0] {
+		t.Errorf("Unexpected order info %+v", infos[0])
+	}
+
+	sorder, err := sreg.LoadOrder("https://acme.example.com/order/1")
+	if nil != err || nil == sorder {
+		t.Fatalf("Couldn't load order: %v", err)
+	}
+	order = *sorder.Order()
+	order.Resource.Status = "ready"
+	if err := sorder.SetOrder(order); nil != err {
+		t.Fatal(err)
+	}
+	if loaded, err := sreg.LoadOrder("https://acme.example.com/order/1"); nil != err || nil == loaded {
+		t.Fatalf("Couldn't load order: %v", err)
+	} else if "ready" != loaded.Order().Resource.Status {
+		t.Errorf("Order update wasn't stored: %+v", loaded.Order().Resource)
+	}
+
+	if err := sorder.Delete(); nil != err {
+		t.Fatal(err)
+	}
+	if orders, err := sreg.Orders(); nil != err || 1 != len(orders) {
+		t.Errorf("Unexpected orders %v after delete (%v)", orders, err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "lock")
+	lock, err := lockFile(filename)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan error)
+	go func() {
+		second, err := lockFile(filename)
+		if nil == err {
+			unlockFile(second)
+		}
+		acquired <- err
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatalf("Lock acquired twice")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	unlockFile(lock)
+	select {
+	case err := <-acquired:
+		if nil != err {
+			t.Errorf("Couldn't lock after unlock: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Lock not acquired after unlock")
+	}
+}
@@ -0,0 +1,14 @@
+package storage_interface
+
+import (
+	"github.com/stbuehler/go-acme-client/types"
+)
+
+type StorageOrder interface {
+	StorageRegistrationComponent
+
+	Order() *types.Order
+	SetOrder(order types.Order) error
+
+	Delete() error
+}
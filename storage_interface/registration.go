@@ -7,6 +7,8 @@ import (
 )
 
 type AuthorizationInfo struct {
+	IdentifierType types.IdentifierType
+	// name of the identifier (DNS name or IP address)
 	DNSIdentifier string
 	Location      string
 	Status        types.AuthorizationStatus
@@ -22,6 +24,14 @@ type CertificateInfo struct {
 	Location    string
 	LinkIssuer  string
 	Certificate *x509.Certificate
+	RenewalInfo *types.RenewalInfo
+}
+
+type OrderInfo struct {
+	Location    string
+	Status      types.OrderStatus
+	Expires     *time.Time
+	Identifiers []string
 }
 
 type StorageRegistrationComponent interface {
@@ -51,5 +61,10 @@ type StorageRegistration interface {
 	CertificatesAll() ([]StorageCertificate, error) // also return expired+revoked
 	LoadCertificate(locationOrName string) (StorageCertificate, error)
 
+	NewOrder(order types.Order) (StorageOrder, error)
+	OrderInfos() ([]OrderInfo, error)
+	Orders() ([]StorageOrder, error)
+	LoadOrder(location string) (StorageOrder, error)
+
 	Delete() error
 }
@@ -39,7 +39,7 @@ func (sauth *sqlStorageAuthorization) SetAuthorization(authorization types.Autho
 }
 
 func (sauth *sqlStorageAuthorization) Delete() error {
-	if _, err := sauth.storage.db.Exec(`DELETE FROM authorization WHERE id = $1`, sauth.id); nil != err {
+	if _, err := sauth.storage.db.Exec(`DELETE FROM "authorization" WHERE id = $1`, sauth.id); nil != err {
 		return err
 	}
 	sauth.id = -1
@@ -62,9 +62,9 @@ func (sreg *sqlStorageRegistration) NewAuthorization(auth types.Authorization) (
 	}
 
 	_, err = sreg.storage.db.Exec(
-		`INSERT INTO authorization (registration_id, dnsName, location, status, expires, jsonPem) VALUES
-			($1, $2, $3, $4, $5, $6)`,
-		sreg.id, string(auth.Resource.DNSIdentifier), auth.Location,
+		`INSERT INTO "authorization" (registration_id, identifierType, dnsName, location, status, expires, jsonPem) VALUES
+			($1, $2, $3, $4, $5, $6, $7)`,
+		sreg.id, string(auth.Resource.Identifier.Type), auth.Name(), auth.Location,
 		string(auth.Resource.Status), auth.Resource.Expires, export.JsonPem)
 	if nil != err {
 		return nil, err
@@ -75,7 +75,7 @@ func (sreg *sqlStorageRegistration) NewAuthorization(auth types.Authorization) (
 
 func (sreg *sqlStorageRegistration) AuthorizationInfos() (i.AuthorizationInfos, error) {
 	rows, err := sreg.storage.db.Query(
-		`SELECT dnsName, location, status, strftime('%Y-%m-%dT%H:%M:%fZ', expires) FROM authorization WHERE registration_id = $1 ORDER BY id DESC`,
+		`SELECT identifierType, dnsName, location, status, `+sreg.storage.dialect.formatTime(`expires`)+` FROM "authorization" WHERE registration_id = $1 ORDER BY id DESC`,
 		sreg.id)
 	if nil != err {
 		return nil, err
@@ -86,7 +86,7 @@ func (sreg *sqlStorageRegistration) AuthorizationInfos() (i.AuthorizationInfos,
 
 func (sreg *sqlStorageRegistration) AuthorizationInfosWithStatus(status types.AuthorizationStatus) (i.AuthorizationInfos, error) {
 	rows, err := sreg.storage.db.Query(
-		`SELECT dnsName, location, status, strftime('%Y-%m-%dT%H:%M:%fZ', expires) FROM authorization WHERE registration_id = $1 AND status = $2 ORDER BY id DESC`,
+		`SELECT identifierType, dnsName, location, status, `+sreg.storage.dialect.formatTime(`expires`)+` FROM "authorization" WHERE registration_id = $1 AND status = $2 ORDER BY id DESC`,
 		sreg.id, string(status))
 	if nil != err {
 		return nil, err
@@ -97,7 +97,7 @@ func (sreg *sqlStorageRegistration) AuthorizationInfosWithStatus(status types.Au
 
 func (sreg *sqlStorageRegistration) Authorizations() ([]i.StorageAuthorization, error) {
 	if rows, err := sreg.storage.db.Query(
-		"SELECT id, registration_id, jsonPem FROM authorization WHERE registration_id = $1", sreg.id); nil != err {
+		`SELECT id, registration_id, jsonPem FROM "authorization" WHERE registration_id = $1`, sreg.id); nil != err {
 		return nil, err
 	} else {
 		defer rows.Close()
@@ -153,30 +153,47 @@ type sqlStorageAuthorization struct {
 	authorization types.Authorization
 }
 
-func checkAuthorizationTable(tx *sql.Tx) error {
-	_, err := tx.Exec(
-		`CREATE TABLE IF NOT EXISTS authorization (
-			id INTEGER PRIMARY KEY,
-			registration_id INT NOT NULL,
-			jsonPem BLOB NOT NULL,
-			dnsName TEXT NOT NULL,
-			location TEXT NOT NULL,
-			status TEXT NOT NULL,
-			expires TEXT,
-			FOREIGN KEY(registration_id) REFERENCES registration(id),
-			UNIQUE (location)
-		)`)
-	return err
+// "authorization" is a keyword in SQL (at least in PostgreSQL) and needs to
+// be quoted in all statements
+var authorizationSchema = &tableSchema{
+	table:   `authorization`,
+	version: 1,
+	create: func(tx *sql.Tx, dialect *sqlDialect) error {
+		_, err := tx.Exec(dialect.schema(
+			`CREATE TABLE "authorization" (
+				id {primaryKey},
+				registration_id INT NOT NULL,
+				jsonPem {blob} NOT NULL,
+				identifierType TEXT NOT NULL,
+				dnsName TEXT NOT NULL,
+				location TEXT NOT NULL,
+				status TEXT NOT NULL,
+				expires {timestamp},
+				FOREIGN KEY(registration_id) REFERENCES registration(id),
+				UNIQUE (location)
+			)`))
+		return err
+	},
+	migrations: []schemaMigration{
+		{-1, 1, "identifier types", func(tx *sql.Tx, dialect *sqlDialect) error {
+			// only "dns" was supported before
+			_, err := tx.Exec(
+				`ALTER TABLE "authorization" ADD COLUMN identifierType TEXT NOT NULL DEFAULT 'dns'
+				`)
+			return err
+		}},
+	},
 }
 
 func authInfoListFromRows(rows *sql.Rows) (i.AuthorizationInfos, error) {
 	regs := make(map[string][]i.AuthorizationInfo)
 	for rows.Next() {
+		var identifierType string
 		var dnsName string
 		var location string
 		var status string
 		var expiresString sql.NullString
-		if err := rows.Scan(&dnsName, &location, &status, &expiresString); nil != err {
+		if err := rows.Scan(&identifierType, &dnsName, &location, &status, &expiresString); nil != err {
 			return nil, err
 		}
 		expires, err := timeFromSqlNullstring(expiresString)
@@ -184,10 +201,11 @@ func authInfoListFromRows(rows *sql.Rows) (i.AuthorizationInfos, error) {
 			return nil, err
 		}
 		regs[dnsName] = append(regs[dnsName], i.AuthorizationInfo{
-			DNSIdentifier: dnsName,
-			Location:      location,
-			Status:        types.AuthorizationStatus(status),
-			Expires:       expires,
+			IdentifierType: types.IdentifierType(identifierType),
+			DNSIdentifier:  dnsName,
+			Location:       location,
+			Status:         types.AuthorizationStatus(status),
+			Expires:        expires,
 		})
 	}
 	return i.AuthorizationInfos(regs), nil
@@ -230,7 +248,7 @@ func (storage *sqlStorage) loadAuthorizationFromSql(rows *sql.Rows, sregHint *sq
 
 func (storage *sqlStorage) loadAuthorizationByLocation(location string, sregHint *sqlStorageRegistration) (*sqlStorageAuthorization, error) {
 	if rows, err := storage.db.Query(
-		"SELECT id, registration_id, jsonPem FROM authorization WHERE location = $1", location); nil != err {
+		`SELECT id, registration_id, jsonPem FROM "authorization" WHERE location = $1`, location); nil != err {
 		return nil, err
 	} else {
 		defer rows.Close()
@@ -242,7 +260,7 @@ func (sreg *sqlStorageRegistration) loadAuthorizationByDnsIdentifier(dnsIdentifi
 	if rows, err := sreg.storage.db.Query(
 		`SELECT
 			id, registration_id, jsonPem
-		FROM authorization
+		FROM "authorization"
 		WHERE registration_id = $1
 			AND dnsName = $2
 			AND (status = 'valid' OR status = 'pending' OR status = '' OR status = 'processing')
@@ -262,12 +280,12 @@ func (storage *sqlStorage) saveAuthorization(id int64, registration_id int64, au
 	}
 
 	_, err = storage.db.Exec(
-		`UPDATE authorization SET
-			registration_id = $1, dnsName = $2, location = $3,
-			status = $4, expires = $5, jsonPem = $6
-		WHERE id = $7`,
+		`UPDATE "authorization" SET
+			registration_id = $1, identifierType = $2, dnsName = $3, location = $4,
+			status = $5, expires = $6, jsonPem = $7
+		WHERE id = $8`,
 		registration_id,
-		string(auth.Resource.DNSIdentifier), auth.Location,
+		string(auth.Resource.Identifier.Type), auth.Name(), auth.Location,
 		string(auth.Resource.Status), auth.Resource.Expires, export.JsonPem,
 		id)
 
@@ -3,11 +3,13 @@ package storage_sql
 import (
 	"crypto/x509"
 	"database/sql"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	i "github.com/stbuehler/go-acme-client/storage_interface"
 	"github.com/stbuehler/go-acme-client/types"
 	"github.com/stbuehler/go-acme-client/utils"
+	"time"
 )
 
 // --------------------------------------------------------------------
@@ -43,7 +45,7 @@ func (scert *sqlStorageCertificate) SetCertificate(certificate types.Certificate
 }
 
 func (scert *sqlStorageCertificate) Delete() error {
-	if _, err := scert.storage.db.Exec(`DELETE FROM certificate WHERE id = %1`, scert.id); nil != err {
+	if _, err := scert.storage.db.Exec(`DELETE FROM certificate WHERE id = $1`, scert.id); nil != err {
 		return err
 	}
 	scert.id = -1
@@ -69,12 +71,15 @@ func (sreg *sqlStorageRegistration) NewCertificate(cert types.Certificate) (i.St
 	if len(cert.Name) == 0 {
 		name = nil
 	}
+	renewalStart, renewalEnd, renewalRetryAfter, renewalExplanationURL, renewalTime := renewalInfoToSql(cert.RenewalInfo)
 
 	_, err = sreg.storage.db.Exec(
-		`INSERT INTO certificate (registration_id, name, revoked, expires, location, linkIssuer, certificatePem, privateKeyPem) VALUES
-			($1, $2, $3, $4, $5, $6, $7, $8)`,
+		`INSERT INTO certificate (registration_id, name, revoked, expires, location, linkIssuer, certificatePem, privateKeyPem, chainPem, alternateChainsJson,
+			renewalStart, renewalEnd, renewalRetryAfter, renewalExplanationURL, renewalTime) VALUES
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
 		sreg.id, name, cert.Revoked, cert.Certificate.NotAfter, cert.Location,
-		cert.LinkIssuer, export.CertificatePem, export.PrivateKeyPem)
+		cert.LinkIssuer, export.CertificatePem, export.PrivateKeyPem, export.ChainPem, alternateChainsToSql(export),
+		renewalStart, renewalEnd, renewalRetryAfter, renewalExplanationURL, renewalTime)
 	if nil != err {
 		return nil, err
 	}
@@ -84,7 +89,7 @@ func (sreg *sqlStorageRegistration) NewCertificate(cert types.Certificate) (i.St
 
 func (sreg *sqlStorageRegistration) CertificateInfos() ([]i.CertificateInfo, error) {
 	rows, err := sreg.storage.db.Query(
-		`SELECT name, revoked, location, linkIssuer, certificatePem
+		`SELECT name, revoked, location, linkIssuer, certificatePem, `+certificateRenewalInfoColumns(sreg.storage.dialect)+`
 		FROM certificate
 		WHERE registration_id = $1
 			AND NOT revoked
@@ -100,7 +105,7 @@ func (sreg *sqlStorageRegistration) CertificateInfos() ([]i.CertificateInfo, err
 
 func (sreg *sqlStorageRegistration) CertificateInfosAll() ([]i.CertificateInfo, error) {
 	rows, err := sreg.storage.db.Query(
-		`SELECT name, revoked, location, linkIssuer, certificatePem
+		`SELECT name, revoked, location, linkIssuer, certificatePem, `+certificateRenewalInfoColumns(sreg.storage.dialect)+`
 		FROM certificate
 		WHERE registration_id = $1
 		ORDER BY id DESC
@@ -114,7 +119,7 @@ func (sreg *sqlStorageRegistration) CertificateInfosAll() ([]i.CertificateInfo,
 
 func (sreg *sqlStorageRegistration) Certificates() ([]i.StorageCertificate, error) {
 	if rows, err := sreg.storage.db.Query(
-		`SELECT id, registration_id, name, revoked, location, linkIssuer, certificatePem, privateKeyPem
+		`SELECT id, registration_id, name, revoked, location, linkIssuer, certificatePem, privateKeyPem, chainPem, alternateChainsJson, `+certificateRenewalInfoColumns(sreg.storage.dialect)+`
 		FROM certificate
 		WHERE registration_id = $1
 			AND NOT revoked
@@ -139,7 +144,7 @@ func (sreg *sqlStorageRegistration) Certificates() ([]i.StorageCertificate, erro
 
 func (sreg *sqlStorageRegistration) CertificatesAll() ([]i.StorageCertificate, error) {
 	if rows, err := sreg.storage.db.Query(
-		`SELECT id, registration_id, name, revoked, location, linkIssuer, certificatePem, privateKeyPem
+		`SELECT id, registration_id, name, revoked, location, linkIssuer, certificatePem, privateKeyPem, chainPem, alternateChainsJson, `+certificateRenewalInfoColumns(sreg.storage.dialect)+`
 		FROM certificate
 		WHERE registration_id = $1
 		ORDER BY id DESC
@@ -162,7 +167,7 @@ func (sreg *sqlStorageRegistration) CertificatesAll() ([]i.StorageCertificate, e
 
 func (sreg *sqlStorageRegistration) LoadCertificate(locationOrName string) (i.StorageCertificate, error) {
 	if rows, err := sreg.storage.db.Query(
-		`SELECT id, registration_id, name, revoked, location, linkIssuer, certificatePem, privateKeyPem
+		`SELECT id, registration_id, name, revoked, location, linkIssuer, certificatePem, privateKeyPem, chainPem, alternateChainsJson, `+certificateRenewalInfoColumns(sreg.storage.dialect)+`
 		FROM certificate
 		WHERE registration_id = $1 AND (location = $2 OR name = $2)`, sreg.id, locationOrName); nil != err {
 		return nil, err
@@ -188,120 +193,227 @@ type sqlStorageCertificate struct {
 	certificate  types.Certificate
 }
 
-func checkCertificateTable(tx *sql.Tx) error {
-	if version, err := schemaGetVersion(tx, `certificate`); nil != err {
-		return err
-	} else if nil == version {
-		if _, err := tx.Exec(
+var certificateSchema = &tableSchema{
+	table:   `certificate`,
+	version: 4,
+	create: func(tx *sql.Tx, dialect *sqlDialect) error {
+		_, err := tx.Exec(dialect.schema(
 			`CREATE TABLE certificate (
-				id INTEGER PRIMARY KEY,
+				id {primaryKey},
 				registration_id INT NOT NULL,
 				name TEXT,
-				revoked INT NOT NULL,
-				expires TEXT NOT NULL,
+				revoked {boolean} NOT NULL,
+				expires {timestamp} NOT NULL,
 				location TEXT NOT NULL,
 				linkIssuer TEXT NOT NULL,
-				certificatePem BLOB NOT NULL,
-				privateKeyPem BLOB,
+				certificatePem {blob} NOT NULL,
+				privateKeyPem {blob},
+				chainPem {blob},
+				alternateChainsJson TEXT,
+				renewalStart {timestamp},
+				renewalEnd {timestamp},
+				renewalRetryAfter {timestamp},
+				renewalExplanationURL TEXT,
+				renewalTime {timestamp},
 				FOREIGN KEY(registration_id) REFERENCES registration(id),
 				UNIQUE (registration_id, location),
 				CONSTRAINT certificate_unique_reg_name UNIQUE (registration_id, name)
-			)`); nil != err {
+			)`))
+		return err
+	},
+	migrations: []schemaMigration{
+		{-1, 1, "names, expiry and revocation", migrateCertificateNames},
+		{1, 2, "ACME renewal information", func(tx *sql.Tx, dialect *sqlDialect) error {
+			for _, column := range []string{"renewalStart", "renewalEnd", "renewalRetryAfter", "renewalTime"} {
+				if _, err := tx.Exec(dialect.schema(`ALTER TABLE certificate ADD COLUMN ` + column + ` {timestamp}`)); nil != err {
+					return err
+				}
+			}
+			_, err := tx.Exec(`ALTER TABLE certificate ADD COLUMN renewalExplanationURL TEXT`)
 			return err
-		}
-		if err := schemaSetVersion(tx, `certificate`, 1); nil != err {
+		}},
+		{2, 3, "issuer chain", func(tx *sql.Tx, dialect *sqlDialect) error {
+			_, err := tx.Exec(dialect.schema(`ALTER TABLE certificate ADD COLUMN chainPem {blob}`))
 			return err
-		}
+		}},
+		{3, 4, "alternate chains", func(tx *sql.Tx, dialect *sqlDialect) error {
+			_, err := tx.Exec(`ALTER TABLE certificate ADD COLUMN alternateChainsJson TEXT`)
+			return err
+		}},
+	},
+}
+
+// add name, expires and revoked (only SQLite databases are this old)
+func migrateCertificateNames(tx *sql.Tx, dialect *sqlDialect) error {
+	if _, err := tx.Exec(
+		`ALTER TABLE certificate ADD COLUMN name TEXT
+		`); nil != err {
+		return err
+	}
+	if _, err := tx.Exec(
+		`CREATE UNIQUE INDEX certificate_unique_reg_name ON certificate (registration_id, name)
+		`); nil != err {
+		return err
+	}
+	if _, err := tx.Exec(
+		`ALTER TABLE certificate ADD COLUMN expires TEXT NOT NULL DEFAULT ''
+		`); nil != err {
+		return err
+	}
+	if _, err := tx.Exec(
+		`ALTER TABLE certificate ADD COLUMN revoked INT NOT NULL DEFAULT 0
+		`); nil != err {
+		return err
+	}
+
+	// read all certificates before updating them
+	certificates := make(map[int64]*x509.Certificate)
+	var ids []int64
+	if rows, err := tx.Query(
+		`SELECT id, certificatePem FROM certificate ORDER BY id DESC
+		`); nil != err {
+		return err
 	} else {
-		switch *version {
-		case -1:
-			// add name, expires and revoked
-			if _, err := tx.Exec(
-				`ALTER TABLE certificate ADD COLUMN name TEXT
-				`); nil != err {
-				return err
-			}
-			if _, err := tx.Exec(
-				`CREATE UNIQUE INDEX certificate_unique_reg_name ON certificate (registration_id, name)
-				`); nil != err {
-				return err
-			}
-			if _, err := tx.Exec(
-				`ALTER TABLE certificate ADD COLUMN expires TEXT NOT NULL DEFAULT ''
-				`); nil != err {
-				return err
-			}
-			if _, err := tx.Exec(
-				`ALTER TABLE certificate ADD COLUMN revoked INT NOT NULL DEFAULT 0
-				`); nil != err {
+		defer rows.Close()
+		for rows.Next() {
+			var id int64
+			var certificatePem []byte
+			if err := rows.Scan(&id, &certificatePem); nil != err {
 				return err
 			}
-			if err := schemaSetVersion(tx, `certificate`, 1); nil != err {
-				return err
+			certBlock, _ := pem.Decode(certificatePem)
+			if nil == certBlock || certBlock.Type != "CERTIFICATE" {
+				return fmt.Errorf("Couldn't decode certificate id %v", id)
 			}
-			utils.Infof("Updating certificate table, assigning names")
-			if rows, err := tx.Query(
-				`SELECT id, certificatePem FROM certificate ORDER BY id DESC
-			`); nil != err {
-				return err
+			if cert, err := x509.ParseCertificate(certBlock.Bytes); nil != err {
+				utils.Debugf("Couldn't parse certificate id %v: %v", id, err)
+				return fmt.Errorf("Couldn't parse certificate id %v: %v", id, err)
 			} else {
-				defer rows.Close()
-				for rows.Next() {
-					var id int64
-					var certificatePem []byte
-					if err := rows.Scan(&id, &certificatePem); nil != err {
-						return err
-					}
-					certBlock, _ := pem.Decode(certificatePem)
-					if certBlock.Type != "CERTIFICATE" {
-						return fmt.Errorf("Couldn't decode certificate id %v: unexpected block type %#v", id, certBlock.Type)
-					}
-					if cert, err := x509.ParseCertificate(certBlock.Bytes); nil != err {
-						utils.Debugf("Couldn't parse certificate id %v: %v", id, err)
-						return fmt.Errorf("Couldn't parse certificate id %v: %v", id, err)
-					} else {
-						if _, err := tx.Exec(`UPDATE OR FAIL certificate SET expires = $1 WHERE id = $2`, cert.NotAfter, id); nil != err {
-							return fmt.Errorf("Couldn't set expires for certificate id %d: %v", id, err)
-						}
-
-						name := cert.Subject.CommonName
-						utils.Debugf("Trying to name certificate id %v %#v", id, name)
-						if _, err := tx.Exec(`UPDATE OR FAIL certificate SET name = $1 WHERE id = $2`, name, id); nil != err {
-							utils.Debugf("Couldn't name certificate %#v, name probably already in use; trying to append #%d: %v", name, id, err)
-							// try appending #id to name
-							name = fmt.Sprintf("%s#%d", name, id)
-							if _, err := tx.Exec(`UPDATE OR FAIL  certificate SET name = $1 WHERE id = $2`, name, id); nil != err {
-								return fmt.Errorf("Couldn't name certificate %#v or %#v: %v", cert.Subject.CommonName, name, err)
-							}
-						}
-					}
-				}
+				certificates[id] = cert
+				ids = append(ids, id)
+			}
+		}
+		if err := rows.Err(); nil != err {
+			return err
+		}
+		rows.Close()
+	}
+
+	for _, id := range ids {
+		cert := certificates[id]
+		if _, err := tx.Exec(`UPDATE certificate SET expires = $1 WHERE id = $2`, cert.NotAfter, id); nil != err {
+			return fmt.Errorf("Couldn't set expires for certificate id %d: %v", id, err)
+		}
+
+		name := cert.Subject.CommonName
+		utils.Debugf("Trying to name certificate id %v %#v", id, name)
+		// a failed statement doesn't abort the (SQLite) transaction
+		if _, err := tx.Exec(`UPDATE certificate SET name = $1 WHERE id = $2`, name, id); nil != err {
+			utils.Debugf("Couldn't name certificate %#v, name probably already in use; trying to append #%d: %v", name, id, err)
+			// try appending #id to name
+			name = fmt.Sprintf("%s#%d", name, id)
+			if _, err := tx.Exec(`UPDATE certificate SET name = $1 WHERE id = $2`, name, id); nil != err {
+				return fmt.Errorf("Couldn't name certificate %#v or %#v: %v", cert.Subject.CommonName, name, err)
 			}
-			utils.Infof("Finished updating certificate table")
-		case 1:
-			// current version
-		default:
-			return fmt.Errorf("Unsupported schema_version %d for %s", version, `certificate`)
 		}
 	}
 	return nil
 }
 
+// renewal information is public, it is stored unencrypted (and the
+// timestamps are selected in the JSON format timeFromSql expects)
+func certificateRenewalInfoColumns(dialect *sqlDialect) string {
+	return dialect.formatTime(`renewalStart`) + `,
+			` + dialect.formatTime(`renewalEnd`) + `,
+			` + dialect.formatTime(`renewalRetryAfter`) + `,
+			renewalExplanationURL,
+			` + dialect.formatTime(`renewalTime`)
+}
+
+type sqlRenewalInfo struct {
+	start, end, retryAfter, explanationURL, renewalTime sql.NullString
+}
+
+func (info *sqlRenewalInfo) scanTargets() []interface{} {
+	return []interface{}{&info.start, &info.end, &info.retryAfter, &info.explanationURL, &info.renewalTime}
+}
+
+func (info *sqlRenewalInfo) renewalInfo() (*types.RenewalInfo, error) {
+	if !info.start.Valid || !info.end.Valid {
+		return nil, nil
+	}
+	result := &types.RenewalInfo{
+		ExplanationURL: info.explanationURL.String,
+	}
+	if start, err := timeFromSql(info.start.String); nil != err {
+		return nil, err
+	} else {
+		result.SuggestedWindow.Start = *start
+	}
+	if end, err := timeFromSql(info.end.String); nil != err {
+		return nil, err
+	} else {
+		result.SuggestedWindow.End = *end
+	}
+	if retryAfter, err := timeFromSqlNullstring(info.retryAfter); nil != err {
+		return nil, err
+	} else if nil != retryAfter {
+		result.RetryAfter = *retryAfter
+	}
+	if renewalTime, err := timeFromSqlNullstring(info.renewalTime); nil != err {
+		return nil, err
+	} else if nil != renewalTime {
+		result.RenewalTime = *renewalTime
+	}
+	return result, nil
+}
+
+func renewalInfoToSql(info *types.RenewalInfo) (start, end, retryAfter *time.Time, explanationURL *string, renewalTime *time.Time) {
+	if nil == info {
+		return nil, nil, nil, nil, nil
+	}
+	if !info.RenewalTime.IsZero() {
+		renewalTime = &info.RenewalTime
+	}
+	return &info.SuggestedWindow.Start, &info.SuggestedWindow.End, &info.RetryAfter, &info.ExplanationURL, renewalTime
+}
+
+func alternateChainsToSql(export *types.CertificateExport) *string {
+	if 0 == len(export.AlternateChainsPem) {
+		return nil
+	}
+	chains := make([]string, len(export.AlternateChainsPem))
+	for ndx, chain := range export.AlternateChainsPem {
+		chains[ndx] = string(chain)
+	}
+	// marshalling strings can't fail
+	data, _ := json.Marshal(chains)
+	result := string(data)
+	return &result
+}
+
 func certInfoListFromRows(rows *sql.Rows) ([]i.CertificateInfo, error) {
 	var certs []i.CertificateInfo
 	for rows.Next() {
 		var name, location, linkIssuer string
 		var revoked bool
 		var certificatePem []byte
-		if err := rows.Scan(&name, &revoked, &location, &linkIssuer, &certificatePem); nil != err {
+		var renewal sqlRenewalInfo
+		if err := rows.Scan(append([]interface{}{&name, &revoked, &location, &linkIssuer, &certificatePem}, renewal.scanTargets()...)...); nil != err {
+			return nil, err
+		}
+
+		renewalInfo, err := renewal.renewalInfo()
+		if nil != err {
 			return nil, err
 		}
 
 		info := i.CertificateInfo{
-			Name:       name,
-			Revoked:    revoked,
-			Location:   location,
-			LinkIssuer: linkIssuer,
+			Name:        name,
+			Revoked:     revoked,
+			Location:    location,
+			LinkIssuer:  linkIssuer,
+			RenewalInfo: renewalInfo,
 		}
 
 		// ignore errors in certificate
@@ -329,10 +441,25 @@ func (storage *sqlStorage) loadCertificateFromSql(rows *sql.Rows, sregHint *sqlS
 	var revoked bool
 	var certificatePem []byte
 	var privateKeyPem sql.NullString
-	if err := rows.Scan(&id, &registration_id, &name, &revoked, &location, &linkIssuer, &certificatePem, &privateKeyPem); nil != err {
+	var chainPem []byte
+	var alternateChainsJson sql.NullString
+	var renewal sqlRenewalInfo
+	if err := rows.Scan(append([]interface{}{&id, &registration_id, &name, &revoked, &location, &linkIssuer, &certificatePem, &privateKeyPem, &chainPem, &alternateChainsJson}, renewal.scanTargets()...)...); nil != err {
 		return nil, err
 	}
 
+	// list of PEM strings
+	var alternateChainsPem [][]byte
+	if alternateChainsJson.Valid {
+		var alternateChains []string
+		if err := json.Unmarshal([]byte(alternateChainsJson.String), &alternateChains); nil != err {
+			return nil, err
+		}
+		for _, chain := range alternateChains {
+			alternateChainsPem = append(alternateChainsPem, []byte(chain))
+		}
+	}
+
 	var privKeyPem []byte
 	if privateKeyPem.Valid {
 		privKeyPem = []byte(privateKeyPem.String)
@@ -352,10 +479,19 @@ func (storage *sqlStorage) loadCertificateFromSql(rows *sql.Rows, sregHint *sqlS
 			PrivateKeyPem:  privKeyPem,
 			Location:       location,
 			LinkIssuer:     linkIssuer,
+			ChainPem:       chainPem,
+
+			AlternateChainsPem: alternateChainsPem,
 		}, storage.passwordPrompt); nil != err {
 		return nil, err
 	}
 
+	if renewalInfo, err := renewal.renewalInfo(); nil != err {
+		return nil, err
+	} else {
+		cert.certificate.RenewalInfo = renewalInfo
+	}
+
 	return cert, nil
 }
 
@@ -369,14 +505,19 @@ func (storage *sqlStorage) saveCertificate(id int64, registration_id int64, cert
 	if len(cert.Name) == 0 {
 		name = nil
 	}
+	renewalStart, renewalEnd, renewalRetryAfter, renewalExplanationURL, renewalTime := renewalInfoToSql(cert.RenewalInfo)
 
 	_, err = storage.db.Exec(
 		`UPDATE certificate SET
-			registration_id = $1, name = $2, revoked = $3, expires = $4, location = $5, linkIssuer = $6, certificatePem = $7, privateKeyPem = $8
-		WHERE id = $9`,
+			registration_id = $1, name = $2, revoked = $3, expires = $4, location = $5, linkIssuer = $6, certificatePem = $7, privateKeyPem = $8,
+			chainPem = $9, alternateChainsJson = $10,
+			renewalStart = $11, renewalEnd = $12, renewalRetryAfter = $13, renewalExplanationURL = $14, renewalTime = $15
+		WHERE id = $16`,
 		registration_id, name, cert.Revoked, cert.Certificate.NotAfter,
 		cert.Location, cert.LinkIssuer, export.CertificatePem,
-		export.PrivateKeyPem, id)
+		export.PrivateKeyPem, export.ChainPem, alternateChainsToSql(export),
+		renewalStart, renewalEnd, renewalRetryAfter, renewalExplanationURL, renewalTime,
+		id)
 
 	return err
 }
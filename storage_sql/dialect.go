@@ -0,0 +1,134 @@
+package storage_sql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// the differences between the supported databases. queries use "$1"
+// placeholders (understood by both drivers) and only standard SQL apart
+// from the pieces below.
+type sqlDialect struct {
+	name   string
+	driver string
+	// column types
+	primaryKey string
+	blob       string
+	timestamp  string
+	boolean    string
+	// query listing a table by name
+	tableExistsQuery string
+	// format of timestamps (as expected by timeFromSql)
+	formatTime func(column string) string
+	// copy of the data before upgrading the given tables; returns a
+	// description of where the backup is
+	backup func(db *sql.DB, tables []string, stamp string) (string, error)
+}
+
+var dialectSQLite = &sqlDialect{
+	name:             "sqlite",
+	driver:           "sqlite3",
+	primaryKey:       "INTEGER PRIMARY KEY",
+	blob:             "BLOB",
+	timestamp:        "TEXT",
+	boolean:          "INT",
+	tableExistsQuery: `SELECT name FROM sqlite_master WHERE type = 'table' AND name = $1`,
+	formatTime: func(column string) string {
+		return `strftime('%Y-%m-%dT%H:%M:%fZ', ` + column + `)`
+	},
+	backup: backupSQLite,
+}
+
+var dialectPostgres = &sqlDialect{
+	name:             "postgres",
+	driver:           "postgres",
+	primaryKey:       "SERIAL PRIMARY KEY",
+	blob:             "BYTEA",
+	timestamp:        "TIMESTAMP WITH TIME ZONE",
+	boolean:          "BOOLEAN",
+	tableExistsQuery: `SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1`,
+	formatTime: func(column string) string {
+		return `to_char(` + column + ` AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')`
+	},
+	backup: backupPostgres,
+}
+
+// copy of the whole database file next to it
+func backupSQLite(db *sql.DB, tables []string, stamp string) (string, error) {
+	var seq int64
+	var name, filename string
+	if err := db.QueryRow(`PRAGMA database_list`).Scan(&seq, &name, &filename); nil != err {
+		return "", err
+	} else if 0 == len(filename) {
+		return "", fmt.Errorf("Database has no file")
+	}
+	backup := filename + ".backup-" + stamp
+	if _, err := db.Exec(`VACUUM INTO $1`, backup); nil != err {
+		return "", err
+	}
+	return backup, nil
+}
+
+// copies of the tables in the same database
+func backupPostgres(db *sql.DB, tables []string, stamp string) (string, error) {
+	tx, err := db.Begin()
+	if nil != err {
+		return "", err
+	}
+	var backupTables []string
+	for _, table := range tables {
+		backupTable := table + "_backup_" + strings.ToLower(stamp)
+		if _, err := tx.Exec(`CREATE TABLE "` + backupTable + `" AS SELECT * FROM "` + table + `"`); nil != err {
+			tx.Rollback()
+			return "", err
+		}
+		backupTables = append(backupTables, backupTable)
+	}
+	if err := tx.Commit(); nil != err {
+		return "", err
+	}
+	return "tables " + strings.Join(backupTables, ", "), nil
+}
+
+// replaces the placeholders {primaryKey}, {blob}, {timestamp} and {boolean}
+// with the column types of the dialect
+func (dialect *sqlDialect) schema(statement string) string {
+	return strings.NewReplacer(
+		"{primaryKey}", dialect.primaryKey,
+		"{blob}", dialect.blob,
+		"{timestamp}", dialect.timestamp,
+		"{boolean}", dialect.boolean,
+	).Replace(statement)
+}
+
+func (dialect *sqlDialect) tableExists(tx *sql.Tx, table string) (bool, error) {
+	rows, err := tx.Query(dialect.tableExistsQuery, table)
+	if nil != err {
+		return false, err
+	}
+	defer rows.Close()
+	return rows.Next(), rows.Err()
+}
+
+// splits a storage DSN into dialect and driver specific data source name:
+// "sqlite://<filename>", "postgres://..." (or "postgresql://...", passed
+// to the driver as is); anything else is the filename of a SQLite database
+func parseDSN(dsn string) (*sqlDialect, string, error) {
+	if pos := strings.Index(dsn, "://"); -1 == pos {
+		return dialectSQLite, dsn, nil
+	} else {
+		switch scheme := dsn[:pos]; scheme {
+		case "sqlite", "sqlite3":
+			filename := dsn[pos+3:]
+			if 0 == len(filename) {
+				return nil, "", fmt.Errorf("Missing filename in storage DSN %#v", dsn)
+			}
+			return dialectSQLite, filename, nil
+		case "postgres", "postgresql":
+			return dialectPostgres, dsn, nil
+		default:
+			return nil, "", fmt.Errorf("Unsupported storage type %#v (use sqlite:// or postgres://)", scheme)
+		}
+	}
+}
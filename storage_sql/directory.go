@@ -29,18 +29,24 @@ func (sdir *sqlStorageDirectory) SetDirectory(directory types.Directory) error {
 	}
 	if _, err := sdir.storage.db.Exec("UPDATE directory SET "+
 		"rootURL=$1,"+
-		"newRegistration = $2, "+
-		"recoverRegistration = $3, "+
-		"newAuthorization = $4, "+
-		"newCertificate = $5, "+
-		"revokeCertificate = $6 "+
-		"WHERE id = $7",
+		"newNonce = $2, "+
+		"newRegistration = $3, "+
+		"newOrder = $4, "+
+		"newAuthorization = $5, "+
+		"revokeCertificate = $6, "+
+		"keyChange = $7, "+
+		"termsOfService = $8, "+
+		"renewalInfo = $9 "+
+		"WHERE id = $10",
 		directory.RootURL,
+		directory.Resource.NewNonce,
 		directory.Resource.NewRegistration,
-		directory.Resource.RecoverRegistration,
+		directory.Resource.NewOrder,
 		directory.Resource.NewAuthorization,
-		directory.Resource.NewCertificate,
 		directory.Resource.RevokeCertificate,
+		directory.Resource.KeyChange,
+		directory.Resource.Meta.TermsOfService,
+		directory.Resource.RenewalInfo,
 		sdir.id); nil != err {
 		return err
 	}
@@ -74,8 +80,8 @@ func (sdir *sqlStorageDirectory) Delete() error {
 // --------------------------------------------------------------------
 
 func (storage *sqlStorage) LoadDirectory(rootURL string) (i.StorageDirectory, error) {
-	rows, err := storage.db.Query("SELECT id, rootURL, newRegistration, "+
-		"recoverRegistration, newAuthorization, newCertificate, revokeCertificate "+
+	rows, err := storage.db.Query("SELECT id, rootURL, newNonce, newRegistration, "+
+		"newOrder, newAuthorization, revokeCertificate, keyChange, termsOfService, renewalInfo "+
 		"FROM directory WHERE rootURL = $1", rootURL)
 	if nil != err {
 		return nil, err
@@ -89,14 +95,17 @@ func (storage *sqlStorage) LoadDirectory(rootURL string) (i.StorageDirectory, er
 }
 
 func (storage *sqlStorage) NewDirectory(directory types.Directory) (i.StorageDirectory, error) {
-	if _, err := storage.db.Exec("INSERT INTO directory (rootURL, newRegistration, "+
-		"recoverRegistration, newAuthorization, newCertificate, revokeCertificate "+
-		") VALUES ($1, $2, $3, $4, $5, $6)", directory.RootURL,
+	if _, err := storage.db.Exec("INSERT INTO directory (rootURL, newNonce, newRegistration, "+
+		"newOrder, newAuthorization, revokeCertificate, keyChange, termsOfService, renewalInfo "+
+		") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)", directory.RootURL,
+		directory.Resource.NewNonce,
 		directory.Resource.NewRegistration,
-		directory.Resource.RecoverRegistration,
+		directory.Resource.NewOrder,
 		directory.Resource.NewAuthorization,
-		directory.Resource.NewCertificate,
-		directory.Resource.RevokeCertificate); nil != err {
+		directory.Resource.RevokeCertificate,
+		directory.Resource.KeyChange,
+		directory.Resource.Meta.TermsOfService,
+		directory.Resource.RenewalInfo); nil != err {
 		return nil, err
 	}
 	return storage.LoadDirectory(directory.RootURL)
@@ -113,17 +122,56 @@ type sqlStorageDirectory struct {
 	directory types.Directory
 }
 
-func checkDirectoryTable(tx *sql.Tx) error {
-	_, err := tx.Exec(
-		`CREATE TABLE IF NOT EXISTS directory (
-			id INTEGER PRIMARY KEY,
-			rootURL TEXT NOT NULL,
-			newRegistration TEXT NOT NULL,
-			recoverRegistration TEXT NOT NULL,
-			newAuthorization TEXT NOT NULL,
-			newCertificate TEXT NOT NULL,
-			revokeCertificate TEXT NOT NULL)`)
-	return err
+var directorySchema = &tableSchema{
+	table:   `directory`,
+	version: 2,
+	create: func(tx *sql.Tx, dialect *sqlDialect) error {
+		_, err := tx.Exec(dialect.schema(
+			`CREATE TABLE directory (
+				id {primaryKey},
+				rootURL TEXT NOT NULL,
+				newNonce TEXT NOT NULL,
+				newRegistration TEXT NOT NULL,
+				newOrder TEXT NOT NULL,
+				newAuthorization TEXT NOT NULL,
+				revokeCertificate TEXT NOT NULL,
+				keyChange TEXT NOT NULL,
+				termsOfService TEXT NOT NULL,
+				renewalInfo TEXT NOT NULL
+			)`))
+		return err
+	},
+	migrations: []schemaMigration{
+		{-1, 1, "RFC 8555 directory resources", func(tx *sql.Tx, dialect *sqlDialect) error {
+			// pre-RFC 8555 directory: the old URLs are useless, they get
+			// refreshed on next use. registrations reference the entries,
+			// so the columns are replaced in place instead of rebuilding
+			// the table (dropping it would break the foreign key).
+			// DROP COLUMN needs SQLite >= 3.35; builds with the libsqlite3
+			// tag use the system library, which might be older.
+			for _, column := range []string{"recoverRegistration", "newCertificate"} {
+				if _, err := tx.Exec(`ALTER TABLE directory DROP COLUMN ` + column); nil != err {
+					return err
+				}
+			}
+			for _, column := range []string{"newNonce", "newOrder", "keyChange", "termsOfService"} {
+				if _, err := tx.Exec(`ALTER TABLE directory ADD COLUMN ` + column + ` TEXT NOT NULL DEFAULT ''`); nil != err {
+					return err
+				}
+			}
+			_, err := tx.Exec(`UPDATE directory SET newRegistration = '', newAuthorization = '', revokeCertificate = ''`)
+			return err
+		}},
+		{1, 2, "ACME renewal information", func(tx *sql.Tx, dialect *sqlDialect) error {
+			if _, err := tx.Exec(`ALTER TABLE directory ADD COLUMN renewalInfo TEXT NOT NULL DEFAULT ''`); nil != err {
+				return err
+			}
+			// the renewalInfo URL wasn't stored before; clearing newNonce
+			// makes the directory get refreshed on next use
+			_, err := tx.Exec(`UPDATE directory SET newNonce = ''`)
+			return err
+		}},
+	},
 }
 
 func (sdir *sqlStorageDirectory) check() error {
@@ -143,21 +191,27 @@ func (storage *sqlStorage) loadDirectoryFromSql(rows *sql.Rows) (*sqlStorageDire
 	}
 
 	var id int64
-	var rootURL, newRegistration, recoverRegistration, newAuthorization, newCertificate, revokeCertificate string
-	if err := rows.Scan(&id, &rootURL, &newRegistration, &recoverRegistration, &newAuthorization, &newCertificate, &revokeCertificate); nil != err {
+	var rootURL, newNonce, newRegistration, newOrder, newAuthorization, revokeCertificate, keyChange, termsOfService, renewalInfo string
+	if err := rows.Scan(&id, &rootURL, &newNonce, &newRegistration, &newOrder, &newAuthorization, &revokeCertificate, &keyChange, &termsOfService, &renewalInfo); nil != err {
 		return nil, err
 	}
 
 	return &sqlStorageDirectory{
 		storage: storage,
 		id:      id,
+		rootURL: rootURL,
 		directory: types.Directory{
 			Resource: types.DirectoryResource{
-				NewRegistration:     newRegistration,
-				RecoverRegistration: recoverRegistration,
-				NewAuthorization:    newAuthorization,
-				NewCertificate:      newCertificate,
-				RevokeCertificate:   revokeCertificate,
+				NewNonce:          newNonce,
+				NewRegistration:   newRegistration,
+				NewOrder:          newOrder,
+				NewAuthorization:  newAuthorization,
+				RevokeCertificate: revokeCertificate,
+				KeyChange:         keyChange,
+				RenewalInfo:       renewalInfo,
+				Meta: types.DirectoryMeta{
+					TermsOfService: termsOfService,
+				},
 			},
 			RootURL: rootURL,
 		},
@@ -165,8 +219,8 @@ func (storage *sqlStorage) loadDirectoryFromSql(rows *sql.Rows) (*sqlStorageDire
 }
 
 func (storage *sqlStorage) loadDirectoryById(directory_id int64) (*sqlStorageDirectory, error) {
-	rows, err := storage.db.Query("SELECT id, rootURL, newRegistration, "+
-		"recoverRegistration, newAuthorization, newCertificate, revokeCertificate "+
+	rows, err := storage.db.Query("SELECT id, rootURL, newNonce, newRegistration, "+
+		"newOrder, newAuthorization, revokeCertificate, keyChange, termsOfService, renewalInfo "+
 		"FROM directory WHERE id = $1", directory_id)
 	if nil != err {
 		return nil, err
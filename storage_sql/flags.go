@@ -7,16 +7,16 @@ import (
 	"github.com/stbuehler/go-acme-client/utils"
 )
 
-var flagsStoragePath string
+var flagsStorage string
 var FlagsStorageAccount string
 
 func AddStorageFlags(flags *flag.FlagSet) {
-	flags.StringVar(&flagsStoragePath, "storage", "storage.sqlite3", "Storagefile")
+	flags.StringVar(&flagsStorage, "storage", "storage.sqlite3", "Storage: SQLite file (or sqlite://<file>) or postgres:// DSN")
 	flags.StringVar(&FlagsStorageAccount, "account", "", "Account name in storage")
 }
 
 func OpenStorageFromFlags(UI ui.UserInterface) (i.Storage, i.StorageRegistration) {
-	st, err := OpenSQLite(UI, flagsStoragePath)
+	st, err := OpenDSN(UI, flagsStorage)
 	if nil != err {
 		utils.Fatalf("Couldn't access storage: %s", err)
 	}
@@ -0,0 +1,149 @@
+package storage_sql
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/utils"
+	"strings"
+	"time"
+)
+
+// a table is created in its current version; older versions are upgraded
+// step by step with the migrations (in the given order) when the storage
+// is opened. tables created before schema versions were recorded have
+// version -1.
+type tableSchema struct {
+	table      string
+	version    int64
+	create     func(tx *sql.Tx, dialect *sqlDialect) error
+	migrations []schemaMigration
+}
+
+type schemaMigration struct {
+	from, to    int64
+	description string
+	migrate     func(tx *sql.Tx, dialect *sqlDialect) error
+}
+
+// in order of the foreign keys
+func tableSchemas() []*tableSchema {
+	return []*tableSchema{
+		directorySchema,
+		registrationSchema,
+		authorizationSchema,
+		certificateSchema,
+		orderSchema,
+	}
+}
+
+type schemaUpgrade struct {
+	schema *tableSchema
+	// nil if the table doesn't exist yet
+	version *int64
+}
+
+// tables which need to be created or upgraded
+func schemaPendingUpgrades(tx *sql.Tx, dialect *sqlDialect) ([]schemaUpgrade, error) {
+	var upgrades []schemaUpgrade
+	for _, schema := range tableSchemas() {
+		version, err := schemaGetVersion(tx, dialect, schema.table)
+		if nil != err {
+			return nil, err
+		}
+		if nil != version && *version > schema.version {
+			return nil, fmt.Errorf("Table %s has schema version %d, but this binary only supports up to version %d; use a newer version of acme-client", schema.table, *version, schema.version)
+		}
+		if nil == version || *version < schema.version {
+			upgrades = append(upgrades, schemaUpgrade{schema: schema, version: version})
+		}
+	}
+	return upgrades, nil
+}
+
+func (upgrade schemaUpgrade) run(tx *sql.Tx, dialect *sqlDialect) error {
+	schema := upgrade.schema
+	if nil == upgrade.version {
+		if err := schema.create(tx, dialect); nil != err {
+			return fmt.Errorf("Couldn't create table %s: %s", schema.table, err)
+		}
+		return schemaSetVersion(tx, schema.table, schema.version)
+	}
+
+	version := *upgrade.version
+	for _, migration := range schema.migrations {
+		if migration.from != version {
+			continue
+		}
+		utils.Infof("Upgrading table %s from schema version %d to %d: %s", schema.table, migration.from, migration.to, migration.description)
+		if err := migration.migrate(tx, dialect); nil != err {
+			return fmt.Errorf("Couldn't upgrade table %s from schema version %d to %d: %s", schema.table, migration.from, migration.to, err)
+		}
+		if err := schemaSetVersion(tx, schema.table, migration.to); nil != err {
+			return err
+		}
+		version = migration.to
+	}
+	if version != schema.version {
+		return fmt.Errorf("Don't know how to upgrade table %s from schema version %d", schema.table, version)
+	}
+	return nil
+}
+
+// creates missing tables and upgrades old ones in a single transaction;
+// existing tables are backed up first
+func upgradeSchema(db *sql.DB, dialect *sqlDialect) error {
+	if _, err := db.Exec(schemaVersionsTable); nil != err {
+		return err
+	}
+
+	var upgrades []schemaUpgrade
+	if tx, err := db.Begin(); nil != err {
+		return err
+	} else {
+		upgrades, err = schemaPendingUpgrades(tx, dialect)
+		tx.Rollback()
+		if nil != err {
+			return err
+		}
+	}
+	if 0 == len(upgrades) {
+		return nil
+	}
+
+	var existing []string
+	for _, upgrade := range upgrades {
+		if nil != upgrade.version {
+			existing = append(existing, upgrade.schema.table)
+		}
+	}
+	if 0 != len(existing) {
+		if backup, err := dialect.backup(db, existing, time.Now().UTC().Format("20060102T150405Z")); nil != err {
+			return fmt.Errorf("Couldn't back up the storage before upgrading tables %s: %s", strings.Join(existing, ", "), err)
+		} else {
+			utils.Infof("Storage backed up to %s before upgrading tables %s", backup, strings.Join(existing, ", "))
+		}
+	}
+
+	tx, err := db.Begin()
+	if nil != err {
+		return err
+	}
+	if err := func() error {
+		// check again: another process might have upgraded the tables
+		// in the meantime
+		upgrades, err := schemaPendingUpgrades(tx, dialect)
+		if nil != err {
+			return err
+		}
+		for _, upgrade := range upgrades {
+			if err := upgrade.run(tx, dialect); nil != err {
+				return err
+			}
+		}
+		return nil
+	}(); nil != err {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,221 @@
+package storage_sql
+
+import (
+	"database/sql"
+	"github.com/stbuehler/go-acme-client/ui"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// tables as created by the first versions (without recorded schema
+// versions, apart from the certificate table)
+var legacySchema = []string{
+	`CREATE TABLE schema_version (
+		tablename TEXT PRIMARY KEY,
+		version INTEGER NOT NULL
+	)`,
+	`CREATE TABLE directory (
+		id INTEGER PRIMARY KEY,
+		rootURL TEXT NOT NULL,
+		newRegistration TEXT NOT NULL,
+		recoverRegistration TEXT NOT NULL,
+		newAuthorization TEXT NOT NULL,
+		newCertificate TEXT NOT NULL,
+		revokeCertificate TEXT NOT NULL)`,
+	`CREATE TABLE registration (
+		id INTEGER PRIMARY KEY,
+		directory_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		location TEXT NOT NULL,
+		jsonPem BLOB NOT NULL,
+		keyPem BLOB NOT NULL,
+		FOREIGN KEY(directory_id) REFERENCES directory(id),
+		UNIQUE (name))`,
+	`CREATE TABLE authorization (
+		id INTEGER PRIMARY KEY,
+		registration_id INT NOT NULL,
+		jsonPem BLOB NOT NULL,
+		dnsName TEXT NOT NULL,
+		location TEXT NOT NULL,
+		status TEXT NOT NULL,
+		expires TEXT,
+		FOREIGN KEY(registration_id) REFERENCES registration(id),
+		UNIQUE (location)
+	)`,
+	`CREATE TABLE certificate (
+		id INTEGER PRIMARY KEY,
+		registration_id INT NOT NULL,
+		name TEXT,
+		revoked INT NOT NULL,
+		expires TEXT NOT NULL,
+		location TEXT NOT NULL,
+		linkIssuer TEXT NOT NULL,
+		certificatePem BLOB NOT NULL,
+		privateKeyPem BLOB,
+		FOREIGN KEY(registration_id) REFERENCES registration(id),
+		UNIQUE (registration_id, location),
+		CONSTRAINT certificate_unique_reg_name UNIQUE (registration_id, name)
+	)`,
+	`INSERT INTO schema_version (tablename, version) VALUES ('certificate', 1)`,
+	`INSERT INTO directory (id, rootURL, newRegistration, recoverRegistration, newAuthorization, newCertificate, revokeCertificate)
+		VALUES (1, 'https://acme.example.com/directory', 'https://acme.example.com/new-reg', '', 'https://acme.example.com/new-authz', 'https://acme.example.com/new-cert', 'https://acme.example.com/revoke-cert')`,
+	`INSERT INTO registration (id, directory_id, name, location, jsonPem, keyPem)
+		VALUES (1, 1, 'default', 'https://acme.example.com/reg/1', 'json', 'key')`,
+	`INSERT INTO authorization (id, registration_id, jsonPem, dnsName, location, status, expires)
+		VALUES (1, 1, 'json', 'example.com', 'https://acme.example.com/authz/1', 'valid', NULL)`,
+	`INSERT INTO certificate (id, registration_id, name, revoked, expires, location, linkIssuer, certificatePem)
+		VALUES (1, 1, 'example.com', 0, '2016-01-01T00:00:00Z', 'https://acme.example.com/cert/1', '', 'cert')`,
+}
+
+// foreign keys are only checked by SQLite if enabled per connection
+func openTestDB(t *testing.T, filename string) *sql.DB {
+	db, err := sql.Open("sqlite3", "file:"+filename+"?_foreign_keys=1")
+	if nil != err {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func createLegacyDB(t *testing.T, filename string) {
+	db := openTestDB(t, filename)
+	defer db.Close()
+	for _, statement := range legacySchema {
+		if _, err := db.Exec(statement); nil != err {
+			t.Fatalf("Couldn't create legacy database: %s\n%s", err, statement)
+		}
+	}
+}
+
+func tableColumns(t *testing.T, db *sql.DB, table string) []string {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info($1)`, table)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); nil != err {
+			t.Fatal(err)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func schemaVersions(t *testing.T, db *sql.DB) map[string]int64 {
+	rows, err := db.Query(`SELECT tablename, version FROM schema_version`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	versions := make(map[string]int64)
+	for rows.Next() {
+		var table string
+		var version int64
+		if err := rows.Scan(&table, &version); nil != err {
+			t.Fatal(err)
+		}
+		versions[table] = version
+	}
+	return versions
+}
+
+func backupFiles(t *testing.T, filename string) []string {
+	backups, err := filepath.Glob(filename + ".backup-*")
+	if nil != err {
+		t.Fatal(err)
+	}
+	return backups
+}
+
+func TestUpgradeLegacySchema(t *testing.T) {
+	dir := t.TempDir()
+	legacyFile := filepath.Join(dir, "legacy.sqlite3")
+	createLegacyDB(t, legacyFile)
+
+	db := openTestDB(t, legacyFile)
+	defer db.Close()
+	storage, err := open(ui.CLI, db, dialectSQLite)
+	if nil != err {
+		t.Fatalf("Upgrading legacy storage failed: %s", err)
+	}
+
+	// the upgraded tables must look like freshly created ones
+	freshFile := filepath.Join(dir, "fresh.sqlite3")
+	freshDB := openTestDB(t, freshFile)
+	defer freshDB.Close()
+	if _, err := open(ui.CLI, freshDB, dialectSQLite); nil != err {
+		t.Fatalf("Creating storage failed: %s", err)
+	}
+	for _, schema := range tableSchemas() {
+		if upgraded, fresh := tableColumns(t, db, schema.table), tableColumns(t, freshDB, schema.table); !reflect.DeepEqual(upgraded, fresh) {
+			t.Errorf("Upgraded table %s has columns %v, expected %v", schema.table, upgraded, fresh)
+		}
+	}
+	versions := schemaVersions(t, db)
+	for _, schema := range tableSchemas() {
+		if schema.version != versions[schema.table] {
+			t.Errorf("Table %s has version %d after upgrade, expected %d", schema.table, versions[schema.table], schema.version)
+		}
+	}
+
+	// the registration still references its directory
+	if rows, err := db.Query(`PRAGMA foreign_key_check`); nil != err {
+		t.Fatal(err)
+	} else {
+		if rows.Next() {
+			t.Errorf("Foreign key violations after upgrade")
+		}
+		rows.Close()
+	}
+	if sdir, err := storage.LoadDirectory("https://acme.example.com/directory"); nil != err || nil == sdir {
+		t.Fatalf("Couldn't load upgraded directory: %v", err)
+	} else if 0 != len(sdir.Directory().Resource.NewNonce) {
+		t.Errorf("Upgraded directory needs to be refreshed, but has newNonce %#v", sdir.Directory().Resource.NewNonce)
+	}
+
+	// the backup has the old layout
+	backups := backupFiles(t, legacyFile)
+	if 1 != len(backups) {
+		t.Fatalf("Expected one backup, got %v", backups)
+	}
+	backupDB := openTestDB(t, backups[0])
+	defer backupDB.Close()
+	if columns := tableColumns(t, backupDB, "directory"); !strings.Contains(strings.Join(columns, " "), "recoverRegistration") {
+		t.Errorf("Backup doesn't contain the old directory table: %v", columns)
+	}
+
+	// nothing to upgrade (and back up) anymore
+	if _, err := open(ui.CLI, db, dialectSQLite); nil != err {
+		t.Fatalf("Reopening upgraded storage failed: %s", err)
+	}
+	if backups := backupFiles(t, legacyFile); 1 != len(backups) {
+		t.Errorf("Reopening upgraded storage created another backup: %v", backups)
+	}
+}
+
+func TestRefuseNewerSchema(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "newer.sqlite3")
+	db := openTestDB(t, filename)
+	defer db.Close()
+	if _, err := open(ui.CLI, db, dialectSQLite); nil != err {
+		t.Fatalf("Creating storage failed: %s", err)
+	}
+	if _, err := db.Exec(`UPDATE schema_version SET version = version + 1 WHERE tablename = 'certificate'`); nil != err {
+		t.Fatal(err)
+	}
+
+	if _, err := open(ui.CLI, db, dialectSQLite); nil == err {
+		t.Fatalf("Storage with newer schema version should be refused")
+	} else if !strings.Contains(err.Error(), "use a newer version") {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if backups := backupFiles(t, filename); 0 != len(backups) {
+		t.Errorf("Refused storage shouldn't be backed up: %v", backups)
+	}
+}
@@ -0,0 +1,241 @@
+package storage_sql
+
+import (
+	"database/sql"
+	i "github.com/stbuehler/go-acme-client/storage_interface"
+	"github.com/stbuehler/go-acme-client/types"
+	"strings"
+)
+
+// --------------------------------------------------------------------
+// implementations for i.StorageOrder
+// --------------------------------------------------------------------
+
+func (sorder *sqlStorageOrder) Storage() i.Storage {
+	return sorder.storage
+}
+
+func (sorder *sqlStorageOrder) StorageDirectory() i.StorageDirectory {
+	return sorder.registration.directory
+}
+
+func (sorder *sqlStorageOrder) Directory() *types.Directory {
+	return sorder.StorageDirectory().Directory()
+}
+
+func (sorder *sqlStorageOrder) StorageRegistration() i.StorageRegistration {
+	return sorder.registration
+}
+
+func (sorder *sqlStorageOrder) Order() *types.Order {
+	return &sorder.order
+}
+
+func (sorder *sqlStorageOrder) SetOrder(order types.Order) error {
+	if err := sorder.storage.saveOrder(sorder.id, sorder.registration.id, order); nil != err {
+		return err
+	}
+	sorder.order = order
+	return nil
+}
+
+func (sorder *sqlStorageOrder) Delete() error {
+	if _, err := sorder.storage.db.Exec(`DELETE FROM "order" WHERE id = $1`, sorder.id); nil != err {
+		return err
+	}
+	sorder.id = -1
+	sorder.order = types.Order{}
+	return nil
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.StorageOrder]
+// --------------------------------------------------------------------
+
+// --------------------------------------------------------------------
+// implementations for i.StorageRegistration
+// --------------------------------------------------------------------
+
+func (sreg *sqlStorageRegistration) NewOrder(order types.Order) (i.StorageOrder, error) {
+	export, err := order.Export(sreg.storage.lastPassword())
+	if nil != err {
+		return nil, err
+	}
+
+	_, err = sreg.storage.db.Exec(
+		`INSERT INTO "order" (registration_id, identifiers, location, status, expires, jsonPem) VALUES
+			($1, $2, $3, $4, $5, $6)`,
+		sreg.id, orderIdentifiersToSql(order), order.Location,
+		string(order.Resource.Status), order.Resource.Expires, export.JsonPem)
+	if nil != err {
+		return nil, err
+	}
+
+	return sreg.LoadOrder(order.Location)
+}
+
+func (sreg *sqlStorageRegistration) OrderInfos() ([]i.OrderInfo, error) {
+	rows, err := sreg.storage.db.Query(
+		`SELECT identifiers, location, status, `+sreg.storage.dialect.formatTime(`expires`)+`
+		FROM "order"
+		WHERE registration_id = $1
+		ORDER BY id DESC`,
+		sreg.id)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+	return orderInfoListFromRows(rows)
+}
+
+func (sreg *sqlStorageRegistration) Orders() ([]i.StorageOrder, error) {
+	if rows, err := sreg.storage.db.Query(
+		`SELECT id, registration_id, jsonPem FROM "order" WHERE registration_id = $1 ORDER BY id DESC`, sreg.id); nil != err {
+		return nil, err
+	} else {
+		defer rows.Close()
+		result := []i.StorageOrder{}
+		for {
+			if sorder, err := sreg.storage.loadOrderFromSql(rows, sreg); nil != err {
+				return nil, err
+			} else if nil == sorder {
+				return result, nil
+			} else {
+				result = append(result, sorder)
+			}
+		}
+	}
+}
+
+func (sreg *sqlStorageRegistration) LoadOrder(location string) (i.StorageOrder, error) {
+	if rows, err := sreg.storage.db.Query(
+		`SELECT id, registration_id, jsonPem FROM "order" WHERE registration_id = $1 AND location = $2`, sreg.id, location); nil != err {
+		return nil, err
+	} else {
+		defer rows.Close()
+		if sorder, err := sreg.storage.loadOrderFromSql(rows, sreg); nil != err || nil == sorder {
+			// make sure to create a nil interface from the nil pointer!
+			return nil, err
+		} else {
+			return sorder, nil
+		}
+	}
+}
+
+// --------------------------------------------------------------------
+// end [implementations for i.StorageRegistration]
+// --------------------------------------------------------------------
+
+type sqlStorageOrder struct {
+	storage      *sqlStorage
+	registration *sqlStorageRegistration
+	id           int64
+	order        types.Order
+}
+
+// "order" is a keyword in SQL and needs to be quoted in all statements
+var orderSchema = &tableSchema{
+	table:   `order`,
+	version: 1,
+	create: func(tx *sql.Tx, dialect *sqlDialect) error {
+		_, err := tx.Exec(dialect.schema(
+			`CREATE TABLE "order" (
+				id {primaryKey},
+				registration_id INT NOT NULL,
+				jsonPem {blob} NOT NULL,
+				identifiers TEXT NOT NULL,
+				location TEXT NOT NULL,
+				status TEXT NOT NULL,
+				expires {timestamp},
+				FOREIGN KEY(registration_id) REFERENCES registration(id),
+				UNIQUE (location)
+			)`))
+		return err
+	},
+}
+
+// identifier values are stored space separated (neither DNS names nor IP
+// addresses contain spaces)
+func orderIdentifiersToSql(order types.Order) string {
+	identifiers := make([]string, len(order.Resource.Identifiers))
+	for ndx, identifier := range order.Resource.Identifiers {
+		identifiers[ndx] = identifier.Value
+	}
+	return strings.Join(identifiers, " ")
+}
+
+func orderInfoListFromRows(rows *sql.Rows) ([]i.OrderInfo, error) {
+	var orders []i.OrderInfo
+	for rows.Next() {
+		var identifiers, location, status string
+		var expiresString sql.NullString
+		if err := rows.Scan(&identifiers, &location, &status, &expiresString); nil != err {
+			return nil, err
+		}
+		expires, err := timeFromSqlNullstring(expiresString)
+		if nil != err {
+			return nil, err
+		}
+		orders = append(orders, i.OrderInfo{
+			Location:    location,
+			Status:      types.OrderStatus(status),
+			Expires:     expires,
+			Identifiers: strings.Fields(identifiers),
+		})
+	}
+	return orders, nil
+}
+
+func (storage *sqlStorage) loadOrderFromSql(rows *sql.Rows, sregHint *sqlStorageRegistration) (*sqlStorageOrder, error) {
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	var id, registration_id int64
+	var jsonPem []byte
+	if err := rows.Scan(&id, &registration_id, &jsonPem); nil != err {
+		return nil, err
+	}
+
+	if nil == sregHint || registration_id != sregHint.id {
+		if sreg, err := storage.loadRegistrationById(registration_id, nil); nil != err {
+			return nil, err
+		} else {
+			sregHint = sreg
+		}
+	}
+
+	order := &sqlStorageOrder{
+		storage:      storage,
+		registration: sregHint,
+		id:           id,
+	}
+
+	if err := order.order.Import(
+		types.OrderExport{
+			JsonPem: jsonPem,
+		}, storage.passwordPrompt); nil != err {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func (storage *sqlStorage) saveOrder(id int64, registration_id int64, order types.Order) error {
+	export, err := order.Export(storage.lastPassword())
+	if nil != err {
+		return err
+	}
+
+	_, err = storage.db.Exec(
+		`UPDATE "order" SET
+			registration_id = $1, identifiers = $2, location = $3,
+			status = $4, expires = $5, jsonPem = $6
+		WHERE id = $7`,
+		registration_id,
+		orderIdentifiersToSql(order), order.Location,
+		string(order.Resource.Status), order.Resource.Expires, export.JsonPem,
+		id)
+
+	return err
+}
@@ -54,8 +54,29 @@ func (sreg *sqlStorageRegistration) SetRegistration(registration types.Registrat
 // func (sreg *sqlStorageRegistration) Certificates() ([]i.StorageCertificate, error)
 // func (sreg *sqlStorageRegistration) LoadCertificate(location string) (i.StorageCertificate, error)
 
+// in order.go
+// func (sreg *sqlStorageRegistration) NewOrder(order types.Order) (i.StorageOrder, error)
+// func (sreg *sqlStorageRegistration) OrderInfos() ([]i.OrderInfo, error)
+// func (sreg *sqlStorageRegistration) Orders() ([]i.StorageOrder, error)
+// func (sreg *sqlStorageRegistration) LoadOrder(location string) (i.StorageOrder, error)
+
+// also deletes all authorizations, certificates and orders of the
+// registration
 func (sreg *sqlStorageRegistration) Delete() error {
-	if _, err := sreg.storage.db.Exec("DELETE FROM registration WHERE id = $1", sreg.id); nil != err {
+	if tx, err := sreg.storage.db.Begin(); nil != err {
+		return err
+	} else if err := func() error {
+		for _, table := range []string{`"authorization"`, `certificate`, `"order"`} {
+			if _, err := tx.Exec(`DELETE FROM `+table+` WHERE registration_id = $1`, sreg.id); nil != err {
+				return err
+			}
+		}
+		_, err := tx.Exec("DELETE FROM registration WHERE id = $1", sreg.id)
+		return err
+	}(); nil != err {
+		tx.Rollback()
+		return err
+	} else if err := tx.Commit(); nil != err {
 		return err
 	}
 	sreg.id = -1
@@ -141,18 +162,29 @@ type sqlStorageRegistration struct {
 	registration types.Registration
 }
 
-func checkRegistrationTable(tx *sql.Tx) error {
-	_, err := tx.Exec(
-		`CREATE TABLE IF NOT EXISTS registration (
-			id INTEGER PRIMARY KEY,
-			directory_id INTEGER NOT NULL,
-			name TEXT NOT NULL,
-			location TEXT NOT NULL,
-			jsonPem BLOB NOT NULL,
-			keyPem BLOB NOT NULL,
-			FOREIGN KEY(directory_id) REFERENCES directory(id),
-			UNIQUE (name))`)
-	return err
+var registrationSchema = &tableSchema{
+	table:   `registration`,
+	version: 1,
+	create: func(tx *sql.Tx, dialect *sqlDialect) error {
+		_, err := tx.Exec(dialect.schema(
+			`CREATE TABLE registration (
+				id {primaryKey},
+				directory_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				location TEXT NOT NULL,
+				jsonPem {blob} NOT NULL,
+				keyPem {blob} NOT NULL,
+				FOREIGN KEY(directory_id) REFERENCES directory(id),
+				UNIQUE (name)
+			)`))
+		return err
+	},
+	migrations: []schemaMigration{
+		{-1, 1, "record schema version", func(tx *sql.Tx, dialect *sqlDialect) error {
+			// the table didn't change before its version was recorded
+			return nil
+		}},
+	},
 }
 
 func registrationListFromSql(rows *sql.Rows) (i.RegistrationList, error) {
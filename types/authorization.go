@@ -5,12 +5,12 @@ import (
 )
 
 type AuthorizationResource struct {
-	Resource      ResourceAuthorizationTag `json:"resource"`
-	DNSIdentifier DNSIdentifier            `json:"identifier,omitempty"`
-	Status        AuthorizationStatus      `json:"status,omitempty"`
-	Challenges    []Challenge              `json:"challenges,omitempty"`
-	Combinations  [][]int                  `json:"combinations,omitempty"`
-	Expires       *time.Time               `json:"expires,omitempty"`
+	Identifier Identifier          `json:"identifier,omitempty"`
+	Status     AuthorizationStatus `json:"status,omitempty"`
+	Challenges []Challenge         `json:"challenges,omitempty"`
+	Expires    *time.Time          `json:"expires,omitempty"`
+	// authorization for a wildcard name "*.<Identifier>"
+	Wildcard bool `json:"wildcard,omitempty"`
 }
 
 type Authorization struct {
@@ -19,3 +19,12 @@ type Authorization struct {
 	// map challenge uri to per challenge data
 	ChallengesData map[string]ChallengeData
 }
+
+// name the authorization is valid for; includes the "*." prefix for
+// wildcard authorizations
+func (auth *Authorization) Name() string {
+	if auth.Resource.Wildcard {
+		return WildcardPrefix + auth.Resource.Identifier.Value
+	}
+	return auth.Resource.Identifier.Value
+}
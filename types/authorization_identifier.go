@@ -0,0 +1,82 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+)
+
+type IdentifierType string
+
+const (
+	IdentifierDNS IdentifierType = "dns"
+	// RFC 8738
+	IdentifierIP IdentifierType = "ip"
+)
+
+type Identifier struct {
+	Type  IdentifierType
+	Value string
+}
+
+const WildcardPrefix = "*."
+
+func IsWildcard(dnsName string) bool {
+	return strings.HasPrefix(dnsName, WildcardPrefix)
+}
+
+// IP addresses (v4 and v6 literals) are "ip" identifiers, everything
+// else "dns"
+func NewIdentifier(name string) Identifier {
+	if ip := net.ParseIP(name); nil != ip {
+		return Identifier{Type: IdentifierIP, Value: ip.String()}
+	}
+	return Identifier{Type: IdentifierDNS, Value: name}
+}
+
+// DNS names and IP addresses for a certificate request, classified like
+// the identifiers of the order
+func SplitDNSNamesAndIPs(names []string) (dnsNames []string, ips []net.IP) {
+	for _, name := range names {
+		if id := NewIdentifier(name); IdentifierIP == id.Type {
+			ips = append(ips, net.ParseIP(id.Value))
+		} else {
+			dnsNames = append(dnsNames, id.Value)
+		}
+	}
+	return
+}
+
+func (id Identifier) String() string {
+	return id.Value
+}
+
+type rawAuthorizationIdentifier struct {
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+func (id Identifier) MarshalJSON() (data []byte, err error) {
+	return json.Marshal(rawAuthorizationIdentifier{
+		Type:  string(id.Type),
+		Value: id.Value,
+	})
+}
+
+func (id *Identifier) UnmarshalJSON(data []byte) error {
+	var rawId rawAuthorizationIdentifier
+	if err := json.Unmarshal(data, &rawId); nil != err {
+		return err
+	}
+	switch IdentifierType(rawId.Type) {
+	case IdentifierDNS, IdentifierIP:
+	default:
+		return fmt.Errorf("Unknown identifier.type %s, expected \"dns\" or \"ip\"", rawId.Type)
+	}
+	*id = Identifier{
+		Type:  IdentifierType(rawId.Type),
+		Value: rawId.Value,
+	}
+	return nil
+}
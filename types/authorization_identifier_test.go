@@ -0,0 +1,17 @@
+package types
+
+import (
+	"testing"
+)
+
+// the CSR must contain exactly the identifiers of the order
+func TestSplitDNSNamesAndIPs(t *testing.T) {
+	names := []string{"example.com", "192.0.2.1", "*.example.com", "2001:db8::1"}
+	dnsNames, ips := SplitDNSNamesAndIPs(names)
+	if 2 != len(dnsNames) || "example.com" != dnsNames[0] || "*.example.com" != dnsNames[1] {
+		t.Errorf("Unexpected DNS names %v", dnsNames)
+	}
+	if 2 != len(ips) || "192.0.2.1" != ips[0].String() || "2001:db8::1" != ips[1].String() {
+		t.Fatalf("Unexpected IP addresses %v", ips)
+	}
+}
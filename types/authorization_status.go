@@ -17,7 +17,7 @@ func (status *AuthorizationStatus) UnmarshalJSON(data []byte) error {
 		// normalize: unset or empty string means pending; go doesn't have
 		// "default" values, so always use empty string to represent "pending"
 		*status = AuthorizationStatus("")
-	case "unknown", "processing", "valid", "invalid", "revoked":
+	case "unknown", "processing", "valid", "invalid", "deactivated", "expired", "revoked":
 		*status = AuthorizationStatus(str)
 	default:
 		return fmt.Errorf("Uknown authorization status %v", str)
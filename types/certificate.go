@@ -1,8 +1,12 @@
 package types
 
 import (
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
+	"strings"
 )
 
 type Certificate struct {
@@ -12,4 +16,43 @@ type Certificate struct {
 	PrivateKey  *pem.Block
 	Location    string
 	LinkIssuer  string
+	// issuer certificates (without the leaf, usually without the root)
+	Chain []*x509.Certificate
+	// other chains offered by the server (Link: rel="alternate")
+	AlternateChains [][]*x509.Certificate
+	// ACME Renewal Information, if the server supports it
+	RenewalInfo *RenewalInfo
+}
+
+// a chain matches the preferred root if the topmost certificate was issued
+// by a CA with the given Common Name, or if any certificate in it has the
+// given "sha256:<hex or base64>" hash of its SubjectPublicKeyInfo
+func ChainMatches(chain []*x509.Certificate, preferred string) bool {
+	if 0 == len(chain) {
+		return false
+	}
+	if strings.HasPrefix(preferred, "sha256:") {
+		hash := strings.TrimPrefix(preferred, "sha256:")
+		for _, cert := range chain {
+			spkiHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			if strings.EqualFold(hash, hex.EncodeToString(spkiHash[:])) || hash == base64.StdEncoding.EncodeToString(spkiHash[:]) {
+				return true
+			}
+		}
+		return false
+	}
+	return chain[len(chain)-1].Issuer.CommonName == preferred
+}
+
+// the first chain matching the preferred root; the default chain if none
+// matches (or nothing is preferred)
+func (cert *Certificate) SelectChain(preferred string) []*x509.Certificate {
+	if 0 != len(preferred) {
+		for _, chain := range append([][]*x509.Certificate{cert.Chain}, cert.AlternateChains...) {
+			if ChainMatches(chain, preferred) {
+				return chain
+			}
+		}
+	}
+	return cert.Chain
 }
@@ -21,6 +21,7 @@ type ChallengeImplementation interface {
 	GetStatus() string
 	GetValidated() string
 	GetURI() string
+	GetError() *ProblemError
 
 	initializeResponse(registration *Registration, authorization *Authorization) (ChallengeResponding, error)
 }
@@ -30,10 +31,16 @@ type Challenge struct {
 }
 
 type rawChallengeBasic struct {
-	Type      string `json:"type,omitempty"`
-	Status    string `json:"status,omitempty"`
-	Validated string `json:"validated,omitempty"`
-	URI       string `json:"uri,omitempty"`
+	Type      string        `json:"type,omitempty"`
+	Status    string        `json:"status,omitempty"`
+	Validated string        `json:"validated,omitempty"`
+	URI       string        `json:"url,omitempty"`
+	Error     *ProblemError `json:"error,omitempty"`
+}
+
+// the reason why the challenge failed (if it did)
+func (basic *rawChallengeBasic) GetError() *ProblemError {
+	return basic.Error
 }
 
 func (authorization *Authorization) Respond(registration Registration, challengeIndex int) (ChallengeResponding, error) {
@@ -55,6 +62,10 @@ func (challenge *Challenge) UnmarshalJSON(data []byte) error {
 	switch jsonType.Type {
 	case http01Identifier:
 		newC = &challengeHttp01{}
+	case dns01Identifier:
+		newC = &challengeDns01{}
+	case tlsAlpn01Identifier:
+		newC = &challengeTlsAlpn01{}
 	case simpleHttpIdentifier: // deprecated
 		newC = &challengeSimpleHttp{}
 	case dvsniIdentifier: // deprecated
@@ -87,3 +98,7 @@ func (challenge *Challenge) GetValidated() string {
 func (challenge *Challenge) GetURI() string {
 	return challenge.chImpl.GetURI()
 }
+
+func (challenge *Challenge) GetError() *ProblemError {
+	return challenge.chImpl.GetError()
+}
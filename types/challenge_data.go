@@ -33,6 +33,10 @@ func (cdata *ChallengeData) UnmarshalJSON(data []byte) error {
 		return nil
 	case http01Identifier:
 		newData = &challengeHttp01Data{}
+	case dns01Identifier:
+		newData = &challengeDns01Data{}
+	case tlsAlpn01Identifier:
+		newData = &challengeTlsAlpn01Data{}
 	case simpleHttpIdentifier: // deprecated
 		newData = &challengeSimpleHttpData{}
 	case dvsniIdentifier: // deprecated
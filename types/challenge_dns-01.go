@@ -0,0 +1,140 @@
+package types
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+)
+
+const dns01Identifier string = "dns-01"
+
+type challengeDns01 struct {
+	rawChallengeBasic
+	Token string `json:"token,omitempty"` // ASCII only
+}
+
+func (dns01 *challengeDns01) GetType() string {
+	return dns01.Type
+}
+
+func (dns01 *challengeDns01) GetStatus() string {
+	return dns01.Status
+}
+
+func (dns01 *challengeDns01) GetValidated() string {
+	return dns01.Validated
+}
+
+func (dns01 *challengeDns01) GetURI() string {
+	return dns01.URI
+}
+
+type challengeDns01Data struct {
+	Type             string `json:"type"`
+	KeyAuthorization string `json:"keyAuthorization"`
+}
+
+func (dns01Data *challengeDns01Data) GetType() string {
+	return dns01Data.Type
+}
+
+type challengeDns01Responding struct {
+	registration  *Registration
+	dnsIdentifier string
+	challenge     challengeDns01
+	data          challengeDns01Data
+}
+
+func (dns01 *challengeDns01) initializeResponse(registration *Registration, authorization *Authorization) (ChallengeResponding, error) {
+	// RFC 8738, section 7
+	if IdentifierIP == authorization.Resource.Identifier.Type {
+		return nil, fmt.Errorf("%s can't be used for the IP identifier %s", dns01Identifier, authorization.Resource.Identifier.Value)
+	}
+
+	keyhash, err := registration.SigningKey.GetPublicKey().Thumbprint(crypto.SHA256)
+	if nil != err {
+		return nil, err
+	}
+
+	responding := challengeDns01Responding{
+		registration:  registration,
+		dnsIdentifier: authorization.Resource.Identifier.Value,
+		challenge:     *dns01,
+		data: challengeDns01Data{
+			Type:             dns01Identifier,
+			KeyAuthorization: dns01.Token + "." + utils.Base64UrlEncode(keyhash),
+		},
+	}
+
+	if oldData := authorization.ChallengesData[dns01.GetURI()].chDataImpl; nil != oldData {
+		if oldData := oldData.(*challengeDns01Data); nil != oldData {
+			responding.data = *oldData
+		}
+	}
+	return &responding, nil
+}
+
+// name of the TXT record
+func (responding *challengeDns01Responding) RecordName() string {
+	return "_acme-challenge." + responding.dnsIdentifier
+}
+
+// content of the TXT record: base64url encoded SHA-256 digest of the key
+// authorization
+func (responding *challengeDns01Responding) RecordValue() string {
+	digest := sha256.Sum256([]byte(responding.data.KeyAuthorization))
+	return utils.Base64UrlEncode(digest[:])
+}
+
+func (responding *challengeDns01Responding) ResetResponse() error {
+	return nil
+}
+
+func (responding *challengeDns01Responding) InitializeResponse(UI ui.UserInterface) error {
+	return nil
+}
+
+func (responding *challengeDns01Responding) ShowInstructions(UI ui.UserInterface) error {
+	if _, err := UI.Prompt(fmt.Sprintf(
+		"Create the following DNS record (other TXT records with the same name can stay):\n%s. 300 IN TXT \"%s\"\nPress enter when done",
+		responding.RecordName(), responding.RecordValue())); nil != err {
+		return err
+	}
+	return nil
+}
+
+func (responding *challengeDns01Responding) Verify() error {
+	name := responding.RecordName()
+	records, err := utils.LookupTXT(name)
+	if nil != err {
+		return fmt.Errorf("Looking up TXT records for %s failed: %s", name, err)
+	}
+
+	expected := responding.RecordValue()
+	for _, record := range records {
+		if expected == record {
+			return nil
+		}
+	}
+	return fmt.Errorf("TXT records for %s don't contain the expected value %#v: got %#v", name, expected, records)
+}
+
+func (responding *challengeDns01Responding) SendPayload() (interface{}, error) {
+	// the server calculates the key authorization itself, an empty object
+	// only signals that we're ready
+	return struct{}{}, nil
+}
+
+func (responding *challengeDns01Responding) ChallengeData() ChallengeData {
+	return ChallengeData{chDataImpl: &responding.data}
+}
+
+func (responding *challengeDns01Responding) Challenge() Challenge {
+	return Challenge{chImpl: &responding.challenge}
+}
+
+func (responding *challengeDns01Responding) Registration() *Registration {
+	return responding.registration
+}
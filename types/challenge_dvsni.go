@@ -17,7 +17,6 @@ import (
 const dvsniIdentifier string = "dvsni"
 
 type challengeDVSNI struct {
-	Resource ResourceChallengeTag `json:"resource"`
 	rawChallengeBasic
 	Token string `json:"token,omitempty"` // ASCII only
 }
@@ -39,8 +38,7 @@ func (dvsni *challengeDVSNI) GetURI() string {
 }
 
 type challengeDVSNIData struct {
-	Resource   ResourceChallengeTag `json:"resource"`
-	Type       string               `json:"type"`
+	Type       string `json:"type"`
 	Validation JSONSignature
 }
 
@@ -51,7 +49,7 @@ func (dvsniData *challengeDVSNIData) GetType() string {
 func (dvsni *challengeDVSNI) initializeResponse(registration *Registration, authorization *Authorization) (ChallengeResponding, error) {
 	responding := challengeDVSNIResponding{
 		registration:  registration,
-		dnsIdentifier: string(authorization.Resource.DNSIdentifier),
+		dnsIdentifier: authorization.Resource.Identifier.Value,
 		challenge:     *dvsni,
 		data: challengeDVSNIData{
 			Type: dvsniIdentifier,
@@ -13,7 +13,6 @@ import (
 const http01Identifier string = "http-01"
 
 type challengeHttp01 struct {
-	Resource ResourceChallengeTag `json:"resource"`
 	rawChallengeBasic
 	Token string `json:"token,omitempty"` // ASCII only
 }
@@ -35,9 +34,8 @@ func (http01 *challengeHttp01) GetURI() string {
 }
 
 type challengeHttp01Data struct {
-	Resource         ResourceChallengeTag `json:"resource"`
-	Type             string               `json:"type"`
-	KeyAuthorization string               `json:"keyAuthorization"`
+	Type             string `json:"type"`
+	KeyAuthorization string `json:"keyAuthorization"`
 }
 
 func (http01Data *challengeHttp01Data) GetType() string {
@@ -59,7 +57,7 @@ func (http01 *challengeHttp01) initializeResponse(registration *Registration, au
 
 	responding := challengeHttp01Responding{
 		registration:  registration,
-		dnsIdentifier: string(authorization.Resource.DNSIdentifier),
+		dnsIdentifier: authorization.Resource.Identifier.Value,
 		challenge:     *http01,
 		data: challengeHttp01Data{
 			Type:             http01Identifier,
@@ -76,9 +74,14 @@ func (http01 *challengeHttp01) initializeResponse(registration *Registration, au
 }
 
 func (responding *challengeHttp01Responding) WellKnownURL() string {
+	host := responding.dnsIdentifier
+	if strings.Contains(host, ":") {
+		// IPv6 address literal (RFC 8738)
+		host = "[" + host + "]"
+	}
 	return fmt.Sprintf(
 		"http://%s/.well-known/acme-challenge/%s",
-		responding.dnsIdentifier,
+		host,
 		responding.challenge.Token)
 }
 
@@ -132,7 +135,9 @@ func (responding *challengeHttp01Responding) Verify() error {
 }
 
 func (responding *challengeHttp01Responding) SendPayload() (interface{}, error) {
-	return responding.data, nil
+	// the server calculates the key authorization itself, an empty object
+	// only signals that we're ready
+	return struct{}{}, nil
 }
 
 func (responding *challengeHttp01Responding) ChallengeData() ChallengeData {
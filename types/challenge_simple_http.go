@@ -12,7 +12,6 @@ import (
 const simpleHttpIdentifier string = "simpleHttp"
 
 type challengeSimpleHttp struct {
-	Resource ResourceChallengeTag `json:"resource"`
 	rawChallengeBasic
 	Token string `json:"token,omitempty"` // ASCII only
 }
@@ -34,9 +33,8 @@ func (simpleHttp *challengeSimpleHttp) GetURI() string {
 }
 
 type challengeSimpleHttpData struct {
-	Resource ResourceChallengeTag `json:"resource"`
-	Type     string               `json:"type"`
-	TLS      bool                 `json:"tls"`
+	Type string `json:"type"`
+	TLS  bool   `json:"tls"`
 }
 
 type challengeSimpleHttpFileData struct {
@@ -52,7 +50,7 @@ func (simpleHttpData *challengeSimpleHttpData) GetType() string {
 func (simpleHttp *challengeSimpleHttp) initializeResponse(registration *Registration, authorization *Authorization) (ChallengeResponding, error) {
 	responding := challengeSimpleHttpResponding{
 		registration:  registration,
-		dnsIdentifier: string(authorization.Resource.DNSIdentifier),
+		dnsIdentifier: authorization.Resource.Identifier.Value,
 		challenge:     *simpleHttp,
 		data: challengeSimpleHttpData{
 			Type: simpleHttpIdentifier,
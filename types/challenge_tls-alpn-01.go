@@ -0,0 +1,257 @@
+package types
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/ui"
+	"github.com/stbuehler/go-acme-client/utils"
+	"net"
+	"strings"
+	"time"
+)
+
+const tlsAlpn01Identifier string = "tls-alpn-01"
+
+// ALPN protocol name the server has to negotiate
+const tlsAlpn01Protocol = "acme-tls/1"
+
+// id-pe-acmeIdentifier (RFC 8737)
+var oidAcmeIdentifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+
+type challengeTlsAlpn01 struct {
+	rawChallengeBasic
+	Token string `json:"token,omitempty"` // ASCII only
+}
+
+func (tlsAlpn01 *challengeTlsAlpn01) GetType() string {
+	return tlsAlpn01.Type
+}
+
+func (tlsAlpn01 *challengeTlsAlpn01) GetStatus() string {
+	return tlsAlpn01.Status
+}
+
+func (tlsAlpn01 *challengeTlsAlpn01) GetValidated() string {
+	return tlsAlpn01.Validated
+}
+
+func (tlsAlpn01 *challengeTlsAlpn01) GetURI() string {
+	return tlsAlpn01.URI
+}
+
+type challengeTlsAlpn01Data struct {
+	Type             string `json:"type"`
+	KeyAuthorization string `json:"keyAuthorization"`
+}
+
+func (tlsAlpn01Data *challengeTlsAlpn01Data) GetType() string {
+	return tlsAlpn01Data.Type
+}
+
+type challengeTlsAlpn01Responding struct {
+	registration  *Registration
+	dnsIdentifier string
+	// only set for "ip" identifiers (RFC 8738)
+	ip        net.IP
+	challenge challengeTlsAlpn01
+	data      challengeTlsAlpn01Data
+}
+
+func (tlsAlpn01 *challengeTlsAlpn01) initializeResponse(registration *Registration, authorization *Authorization) (ChallengeResponding, error) {
+	keyhash, err := registration.SigningKey.GetPublicKey().Thumbprint(crypto.SHA256)
+	if nil != err {
+		return nil, err
+	}
+
+	responding := challengeTlsAlpn01Responding{
+		registration:  registration,
+		dnsIdentifier: authorization.Resource.Identifier.Value,
+		challenge:     *tlsAlpn01,
+		data: challengeTlsAlpn01Data{
+			Type:             tlsAlpn01Identifier,
+			KeyAuthorization: tlsAlpn01.Token + "." + utils.Base64UrlEncode(keyhash),
+		},
+	}
+
+	if IdentifierIP == authorization.Resource.Identifier.Type {
+		if responding.ip = net.ParseIP(responding.dnsIdentifier); nil == responding.ip {
+			return nil, fmt.Errorf("Invalid IP identifier %s", responding.dnsIdentifier)
+		}
+	}
+
+	if oldData := authorization.ChallengesData[tlsAlpn01.GetURI()].chDataImpl; nil != oldData {
+		if oldData := oldData.(*challengeTlsAlpn01Data); nil != oldData {
+			responding.data = *oldData
+		}
+	}
+	return &responding, nil
+}
+
+// reverse DNS name of an IP address (in-addr.arpa or ip6.arpa), which IP
+// identifiers use as SNI (RFC 8738, section 6)
+func reverseDNSName(ip net.IP) string {
+	if ip4 := ip.To4(); nil != ip4 {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+	var labels []string
+	for ndx := len(ip) - 1; ndx >= 0; ndx-- {
+		labels = append(labels, fmt.Sprintf("%x.%x", ip[ndx]&0xf, ip[ndx]>>4))
+	}
+	return strings.Join(labels, ".") + ".ip6.arpa"
+}
+
+// the TLS server name the ACME server sends
+func (responding *challengeTlsAlpn01Responding) serverName() string {
+	if nil != responding.ip {
+		return reverseDNSName(responding.ip)
+	}
+	return responding.dnsIdentifier
+}
+
+// DER encoded content of the acmeIdentifier extension: an OCTET STRING
+// containing the SHA-256 digest of the key authorization
+func (responding *challengeTlsAlpn01Responding) acmeIdentifierValue() ([]byte, error) {
+	digest := sha256.Sum256([]byte(responding.data.KeyAuthorization))
+	return asn1.Marshal(digest[:])
+}
+
+func (responding *challengeTlsAlpn01Responding) ResetResponse() error {
+	return nil
+}
+
+func (responding *challengeTlsAlpn01Responding) InitializeResponse(UI ui.UserInterface) error {
+	return nil
+}
+
+func (responding *challengeTlsAlpn01Responding) ShowInstructions(UI ui.UserInterface) error {
+	var text string
+	if nil != responding.ip {
+		text = fmt.Sprintf("%s needs to present a (self-signed) certificate with the IP address %s in SubjectAltName and a critical acmeIdentifier extension (%s) when %s is requested through SNI and the %#v protocol is negotiated through ALPN\n",
+			net.JoinHostPort(responding.dnsIdentifier, "443"), responding.dnsIdentifier, oidAcmeIdentifier, responding.serverName(), tlsAlpn01Protocol)
+	} else {
+		text = fmt.Sprintf("%s:443 needs to present a (self-signed) certificate for %s with a critical acmeIdentifier extension (%s) when the %#v protocol is negotiated through ALPN\n",
+			responding.dnsIdentifier, responding.dnsIdentifier, oidAcmeIdentifier, tlsAlpn01Protocol)
+	}
+	if cert, err := responding.makeCertificate(); nil != err {
+		text += fmt.Sprintf("Couldn't generate certificate: %s\n", err)
+	} else {
+		text += fmt.Sprintf("You can use the following P-256 ECDSA certificate:\n%s", cert)
+	}
+	text += "Press enter when done"
+	_, err := UI.Prompt(text)
+	return err
+}
+
+func (responding *challengeTlsAlpn01Responding) Verify() error {
+	expected, err := responding.acmeIdentifierValue()
+	if nil != err {
+		return err
+	}
+
+	address := net.JoinHostPort(responding.dnsIdentifier, "443")
+	conn, err := tls.Dial("tcp", address, &tls.Config{
+		RootCAs:            x509.NewCertPool(),
+		ServerName:         responding.serverName(),
+		NextProtos:         []string{tlsAlpn01Protocol},
+		InsecureSkipVerify: true,
+	})
+	if nil != err {
+		return fmt.Errorf("Failed TLS handshake with %s: %v", address, err)
+	}
+	defer conn.Close()
+
+	cState := conn.ConnectionState()
+	if tlsAlpn01Protocol != cState.NegotiatedProtocol {
+		return fmt.Errorf("Server %s didn't negotiate ALPN protocol %#v (got %#v)", address, tlsAlpn01Protocol, cState.NegotiatedProtocol)
+	}
+	if 0 == len(cState.PeerCertificates) {
+		return fmt.Errorf("Server %s returned no certificates", address)
+	}
+	cert := cState.PeerCertificates[0]
+	if nil != responding.ip {
+		if 0 != len(cert.DNSNames) || 1 != len(cert.IPAddresses) || !responding.ip.Equal(cert.IPAddresses[0]) {
+			return fmt.Errorf("Certificate on %s must contain exactly the IP address %s in SubjectAltName, got %v %v", address, responding.dnsIdentifier, cert.DNSNames, cert.IPAddresses)
+		}
+	} else if 1 != len(cert.DNSNames) || 0 != len(cert.IPAddresses) || responding.dnsIdentifier != cert.DNSNames[0] {
+		return fmt.Errorf("Certificate on %s must contain exactly %s in SubjectAltName, got %v", address, responding.dnsIdentifier, cert.DNSNames)
+	}
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidAcmeIdentifier) {
+			if !ext.Critical {
+				return fmt.Errorf("acmeIdentifier extension in certificate on %s is not marked critical", address)
+			} else if !bytes.Equal(expected, ext.Value) {
+				return fmt.Errorf("acmeIdentifier extension in certificate on %s doesn't match the key authorization", address)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("Certificate on %s doesn't contain the acmeIdentifier extension", address)
+}
+
+func (responding *challengeTlsAlpn01Responding) SendPayload() (interface{}, error) {
+	// the server calculates the key authorization itself, an empty object
+	// only signals that we're ready
+	return struct{}{}, nil
+}
+
+func (responding *challengeTlsAlpn01Responding) ChallengeData() ChallengeData {
+	return ChallengeData{chDataImpl: &responding.data}
+}
+
+func (responding *challengeTlsAlpn01Responding) Challenge() Challenge {
+	return Challenge{chImpl: &responding.challenge}
+}
+
+func (responding *challengeTlsAlpn01Responding) Registration() *Registration {
+	return responding.registration
+}
+
+func (responding *challengeTlsAlpn01Responding) makeCertificate() (string, error) {
+	var out bytes.Buffer
+
+	var dnsNames []string
+	var ipAddresses []net.IP
+	if nil != responding.ip {
+		ipAddresses = []net.IP{responding.ip}
+	} else {
+		dnsNames = []string{responding.dnsIdentifier}
+	}
+
+	if value, err := responding.acmeIdentifierValue(); nil != err {
+		return "", err
+	} else if privKey, err := utils.CreateEcdsaPrivateKey(elliptic.P256()); nil != err {
+		return "", err
+	} else if block_cert, err := utils.MakeCertificate(
+		utils.CertificateParameters{
+			SigningKey:  privKey,
+			Subject:     pkix.Name{CommonName: responding.dnsIdentifier},
+			DNSNames:    dnsNames,
+			IPAddresses: ipAddresses,
+			Duration:    7 * 24 * time.Hour,
+			ExtraExtensions: []pkix.Extension{
+				pkix.Extension{
+					Id:       oidAcmeIdentifier,
+					Critical: true,
+					Value:    value,
+				},
+			},
+		}); nil != err {
+		return "", err
+	} else if block_pkey, err := utils.EncodePrivateKey(privKey); nil != err {
+		return "", err
+	} else if err := pem.Encode(&out, block_cert); nil != err {
+		return "", err
+	} else if err := pem.Encode(&out, block_pkey); nil != err {
+		return "", err
+	} else {
+		return out.String(), nil
+	}
+}
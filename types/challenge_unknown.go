@@ -10,7 +10,6 @@ type unknownChallenge struct {
 }
 
 func (c *unknownChallenge) MarshalJSON() ([]byte, error) {
-	c.data["resource"] = Resource_Challenge.String()
 	return json.Marshal(c.data)
 }
 
@@ -42,6 +41,10 @@ func (c *unknownChallenge) GetURI() string {
 	return c.basic.URI
 }
 
+func (c *unknownChallenge) GetError() *ProblemError {
+	return c.basic.Error
+}
+
 func (*unknownChallenge) initializeResponse(registration *Registration, authorization *Authorization) (ChallengeResponding, error) {
 	return nil, nil
 }
@@ -1,11 +1,21 @@
 package types
 
+type DirectoryMeta struct {
+	TermsOfService          string   `json:"termsOfService,omitempty"`
+	Website                 string   `json:"website,omitempty"`
+	CaaIdentities           []string `json:"caaIdentities,omitempty"`
+	ExternalAccountRequired bool     `json:"externalAccountRequired,omitempty"`
+}
+
 type DirectoryResource struct {
-	NewRegistration     string `json:"new-reg,omitempty"`
-	RecoverRegistration string `json:"recover-reg,omitempty"`
-	NewAuthorization    string `json:"new-authz,omitempty"`
-	NewCertificate      string `json:"new-cert,omitempty"`
-	RevokeCertificate   string `json:"revoke-cert,omitempty"`
+	NewNonce          string        `json:"newNonce,omitempty"`
+	NewRegistration   string        `json:"newAccount,omitempty"`
+	NewOrder          string        `json:"newOrder,omitempty"`
+	NewAuthorization  string        `json:"newAuthz,omitempty"` // optional
+	RevokeCertificate string        `json:"revokeCert,omitempty"`
+	KeyChange         string        `json:"keyChange,omitempty"`
+	RenewalInfo       string        `json:"renewalInfo,omitempty"` // optional (ARI)
+	Meta              DirectoryMeta `json:"meta,omitempty"`
 }
 
 type Directory struct {
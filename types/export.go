@@ -13,10 +13,13 @@ var UnexpectedPemBlock = errors.New("Unexpected PEM block")
 
 const pemTypeEcPrivateKey = "EC PRIVATE KEY"
 const pemTypeRsaPrivateKey = "RSA PRIVATE KEY"
+const pemTypePrivateKey = "PRIVATE KEY"
 const pemTypePublicKey = "PUBLIC KEY"
 const pemTypeCertificate = "CERTIFICATE"
 const pemTypeAcmeJsonRegistration = "ACME JSON REGISTRATION"
 const pemTypeAcmeJsonAuthorization = "ACME JSON AUTHORIZATION"
+const pemTypeAcmeJsonOrder = "ACME JSON ORDER"
+const pemTypeAcmeExternalSigningKey = "ACME EXTERNAL SIGNING KEY"
 
 type PasswordPrompt func() (string, error)
 
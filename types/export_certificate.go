@@ -13,6 +13,9 @@ type CertificateExport struct {
 	PrivateKeyPem  []byte
 	Location       string
 	LinkIssuer     string
+	ChainPem       []byte
+	// one (concatenated) PEM entry per chain
+	AlternateChainsPem [][]byte
 }
 
 func (cert *Certificate) Import(export CertificateExport, prompt PasswordPrompt) error {
@@ -26,18 +29,33 @@ func (cert *Certificate) Import(export CertificateExport, prompt PasswordPrompt)
 	}
 	var privateKeyBlock *pem.Block
 	if nil != export.PrivateKeyPem {
-		privateKeyBlock, err = importPem(export.PrivateKeyPem, prompt, pemTypeEcPrivateKey, pemTypeRsaPrivateKey)
+		privateKeyBlock, err = importPem(export.PrivateKeyPem, prompt, pemTypeEcPrivateKey, pemTypeRsaPrivateKey, pemTypePrivateKey)
 		if nil != err {
 			return err
 		}
 	}
 
+	chain, err := utils.ParseCertificatesPem(export.ChainPem)
+	if nil != err {
+		return err
+	}
+	var alternateChains [][]*x509.Certificate
+	for _, chainPem := range export.AlternateChainsPem {
+		if alternateChain, err := utils.ParseCertificatesPem(chainPem); nil != err {
+			return err
+		} else {
+			alternateChains = append(alternateChains, alternateChain)
+		}
+	}
+
 	cert.Name = export.Name
 	cert.Revoked = export.Revoked
 	cert.Certificate = certificate
 	cert.PrivateKey = privateKeyBlock
 	cert.Location = export.Location
 	cert.LinkIssuer = export.LinkIssuer
+	cert.Chain = chain
+	cert.AlternateChains = alternateChains
 
 	return nil
 }
@@ -52,6 +70,11 @@ func (cert Certificate) Export(password string) (*CertificateExport, error) {
 		privateKeyBlob = pem.EncodeToMemory(&privateKeyBlock)
 	}
 
+	var alternateChainsPem [][]byte
+	for _, chain := range cert.AlternateChains {
+		alternateChainsPem = append(alternateChainsPem, utils.CertificatesToPem(chain))
+	}
+
 	return &CertificateExport{
 		Name:           cert.Name,
 		Revoked:        cert.Revoked,
@@ -59,5 +82,8 @@ func (cert Certificate) Export(password string) (*CertificateExport, error) {
 		PrivateKeyPem:  privateKeyBlob,
 		Location:       cert.Location,
 		LinkIssuer:     cert.LinkIssuer,
+		ChainPem:       utils.CertificatesToPem(cert.Chain),
+
+		AlternateChainsPem: alternateChainsPem,
 	}, nil
 }
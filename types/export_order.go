@@ -0,0 +1,41 @@
+package types
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"github.com/stbuehler/go-acme-client/utils"
+)
+
+type OrderExport struct {
+	JsonPem []byte
+}
+
+func (order *Order) Import(export OrderExport, prompt PasswordPrompt) error {
+	if jsonBlock, err := importPem(export.JsonPem, prompt, pemTypeAcmeJsonOrder); nil != err {
+		return err
+	} else {
+		var importedOrder Order
+		if err := json.Unmarshal(jsonBlock.Bytes, &importedOrder); nil != err {
+			return err
+		}
+		*order = importedOrder
+		return nil
+	}
+}
+
+func (order Order) Export(password string) (*OrderExport, error) {
+	if jsonBytes, err := json.Marshal(order); nil != err {
+		return nil, err
+	} else {
+		jsonBlock := &pem.Block{
+			Type:  pemTypeAcmeJsonOrder,
+			Bytes: jsonBytes,
+		}
+		if err := utils.EncryptPemBlock(jsonBlock, password, utils.PemDefaultCipher); nil != err {
+			return nil, err
+		}
+		return &OrderExport{
+			JsonPem: pem.EncodeToMemory(jsonBlock),
+		}, nil
+	}
+}
@@ -16,18 +16,18 @@ type RegistrationExport struct {
 type rawRegistrationExportJson struct {
 	Resource           RegistrationResource
 	LinkTermsOfService string
-	RecoveryToken      string
+	AgreementURL       string
 }
 
 func (reg Registration) Export(password string) (*RegistrationExport, error) {
-	keyBlock, err := reg.SigningKey.EncryptPrivateKey(password, utils.PemDefaultCipher)
+	keyBlock, err := reg.SigningKey.ExportPem(password, utils.PemDefaultCipher)
 	if nil != err {
 		return nil, err
 	}
 	jsonBytes, err := json.Marshal(rawRegistrationExportJson{
 		Resource:           reg.Resource,
 		LinkTermsOfService: reg.LinkTermsOfService,
-		RecoveryToken:      reg.RecoveryToken,
+		AgreementURL:       reg.AgreementURL,
 	})
 	if nil != err {
 		return nil, err
@@ -52,7 +52,7 @@ func (reg *Registration) Import(export RegistrationExport, prompt PasswordPrompt
 	if nil != err {
 		return err
 	}
-	keyBlock, err := importPem(export.SigningKeyPem, prompt, pemTypeEcPrivateKey, pemTypeRsaPrivateKey)
+	keyBlock, err := importPem(export.SigningKeyPem, prompt, pemTypeEcPrivateKey, pemTypeRsaPrivateKey, pemTypePrivateKey, pemTypeAcmeExternalSigningKey)
 	if nil != err {
 		return err
 	}
@@ -70,7 +70,7 @@ func (reg *Registration) Import(export RegistrationExport, prompt PasswordPrompt
 	reg.SigningKey = signingKey
 	reg.Location = export.Location
 	reg.LinkTermsOfService = rawReg.LinkTermsOfService
-	reg.RecoveryToken = rawReg.RecoveryToken
+	reg.AgreementURL = rawReg.AgreementURL
 	reg.Name = export.Name
 
 	return nil
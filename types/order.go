@@ -0,0 +1,25 @@
+package types
+
+import (
+	"time"
+)
+
+type OrderResource struct {
+	Status         OrderStatus   `json:"status,omitempty"`
+	Expires        *time.Time    `json:"expires,omitempty"`
+	Identifiers    []Identifier  `json:"identifiers"`
+	NotBefore      *time.Time    `json:"notBefore,omitempty"`
+	NotAfter       *time.Time    `json:"notAfter,omitempty"`
+	Authorizations []string      `json:"authorizations,omitempty"`
+	Finalize       string        `json:"finalize,omitempty"`
+	Certificate    string        `json:"certificate,omitempty"`
+	Error          *ProblemError `json:"error,omitempty"`
+}
+
+type Order struct {
+	Resource OrderResource
+	Location string
+	// DER encoded certificate request the order was (or is going to be)
+	// finalized with; local only
+	CSR []byte `json:",omitempty"`
+}
@@ -0,0 +1,30 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type OrderStatus string
+
+func (status *OrderStatus) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); nil != err {
+		return err
+	}
+	switch str {
+	case "pending", "ready", "processing", "valid", "invalid":
+		*status = OrderStatus(str)
+	default:
+		return fmt.Errorf("Unknown order status %v", str)
+	}
+	return nil
+}
+
+func (status OrderStatus) MarshalJSON() (data []byte, err error) {
+	return json.Marshal(string(status))
+}
+
+func (status OrderStatus) String() string {
+	return string(status)
+}
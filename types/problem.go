@@ -0,0 +1,63 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+const problemTypePrefix = "urn:ietf:params:acme:error:"
+
+// pre-RFC 8555 servers used a different namespace
+const problemTypePrefixOld = "urn:acme:error:"
+
+type ProblemIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// RFC 7807 problem document as used by ACME (RFC 8555 section 6.7)
+type ProblemError struct {
+	Type        string             `json:"type"`
+	Detail      string             `json:"detail,omitempty"`
+	Status      int                `json:"status,omitempty"`
+	Identifier  *ProblemIdentifier `json:"identifier,omitempty"`
+	Subproblems []ProblemError     `json:"subproblems,omitempty"`
+	// JWS algorithms accepted by the server (badSignatureAlgorithm)
+	Algorithms []string `json:"algorithms,omitempty"`
+}
+
+// type without the ACME namespace prefix, e.g. "rateLimited"; other types
+// are returned unmodified
+func (problem *ProblemError) ShortType() string {
+	if strings.HasPrefix(problem.Type, problemTypePrefix) {
+		return problem.Type[len(problemTypePrefix):]
+	} else if strings.HasPrefix(problem.Type, problemTypePrefixOld) {
+		return problem.Type[len(problemTypePrefixOld):]
+	}
+	return problem.Type
+}
+
+// shortType can be given with or without the ACME namespace prefix
+func (problem *ProblemError) IsType(shortType string) bool {
+	return shortType == problem.Type || shortType == problem.ShortType()
+}
+
+func (problem *ProblemError) Error() string {
+	msg := problem.ShortType()
+	if 0 == len(msg) {
+		msg = fmt.Sprintf("HTTP status %d", problem.Status)
+	}
+	if nil != problem.Identifier {
+		msg = fmt.Sprintf("%s (%s %s)", msg, problem.Identifier.Type, problem.Identifier.Value)
+	}
+	if 0 != len(problem.Detail) {
+		msg = msg + ": " + problem.Detail
+	}
+	if 0 != len(problem.Algorithms) {
+		msg = fmt.Sprintf("%s (supported algorithms: %s)", msg, strings.Join(problem.Algorithms, ", "))
+	}
+	for _, sub := range problem.Subproblems {
+		msg = msg + "; " + sub.Error()
+	}
+	return msg
+}
@@ -1,12 +1,10 @@
 package types
 
 type RegistrationResource struct {
-	Resource          ResourceRegistrationTag `json:"resource"`
-	Contact           []string                `json:"contact,omitempty"`
-	AgreementURL      string                  `json:"agreement,omitempty"`
-	AuthorizationsURL string                  `json:"authorizations,omitempty"`
-	CertificatesURL   string                  `json:"certificates,omitempty"`
-	// recovery not supported yet
+	Status               string   `json:"status,omitempty"`
+	Contact              []string `json:"contact,omitempty"`
+	TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed,omitempty"`
+	OrdersURL            string   `json:"orders,omitempty"`
 }
 
 type Registration struct {
@@ -14,6 +12,8 @@ type Registration struct {
 	SigningKey         SigningKey
 	Location           string
 	LinkTermsOfService string
-	RecoveryToken      string
-	Name               string
+	// the server only remembers that we agreed to "the" terms of service;
+	// remember which URL they were at
+	AgreementURL string
+	Name         string
 }
@@ -0,0 +1,65 @@
+package types
+
+import (
+	"crypto/x509"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/utils"
+	"math/rand"
+	"time"
+)
+
+// ACME Renewal Information (draft-ietf-acme-ari)
+type RenewalWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type RenewalInfo struct {
+	SuggestedWindow RenewalWindow `json:"suggestedWindow"`
+	ExplanationURL  string        `json:"explanationURL,omitempty"`
+	// local data: don't ask the server again before this (Retry-After)
+	RetryAfter time.Time `json:"-"`
+	// local data: point in the window selected for the renewal; picked
+	// only once per window
+	RenewalTime time.Time `json:"-"`
+}
+
+// base64url(AKI keyIdentifier) "." base64url(DER serial number)
+func RenewalCertificateID(cert *x509.Certificate) (string, error) {
+	if 0 == len(cert.AuthorityKeyId) {
+		return "", fmt.Errorf("Certificate has no Authority Key Identifier")
+	}
+	serial := cert.SerialNumber.Bytes()
+	if 0 == len(serial) || 0 != serial[0]&0x80 {
+		// DER INTEGER encoding needs a leading zero for "positive" numbers
+		serial = append([]byte{0}, serial...)
+	}
+	return utils.Base64UrlEncode(cert.AuthorityKeyId) + "." + utils.Base64UrlEncode(serial), nil
+}
+
+// the info needs to be fetched again
+func (info *RenewalInfo) NeedsUpdate(now time.Time) bool {
+	return now.After(info.RetryAfter)
+}
+
+// pick a random point in the suggested window, as recommended by the
+// draft; renewal is due when it isn't in the future
+func (info *RenewalInfo) SelectRenewalTime() time.Time {
+	window := info.SuggestedWindow
+	if !window.End.After(window.Start) {
+		return window.Start
+	}
+	return window.Start.Add(time.Duration(rand.Int63n(int64(window.End.Sub(window.Start)))))
+}
+
+// keep the renewal time selected for the previous info if the window didn't
+// change, otherwise select a new one
+func (info *RenewalInfo) UpdateRenewalTime(previous *RenewalInfo) {
+	if nil != previous && !previous.RenewalTime.IsZero() &&
+		previous.SuggestedWindow.Start.Equal(info.SuggestedWindow.Start) &&
+		previous.SuggestedWindow.End.Equal(info.SuggestedWindow.End) {
+		info.RenewalTime = previous.RenewalTime
+	} else {
+		info.RenewalTime = info.SelectRenewalTime()
+	}
+}
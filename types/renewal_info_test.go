@@ -0,0 +1,101 @@
+package types
+
+import (
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestRenewalCertificateID(t *testing.T) {
+	// test vector from draft-ietf-acme-ari
+	cert := &x509.Certificate{
+		AuthorityKeyId: []byte{
+			0x69, 0x88, 0x5B, 0x6B, 0x87, 0x46, 0x40, 0x41, 0xE1, 0xB3,
+			0x7B, 0x84, 0x7B, 0xA0, 0xAE, 0x2C, 0xDE, 0x01, 0xC8, 0xD4,
+		},
+		SerialNumber: big.NewInt(0x87654321),
+	}
+	if id, err := RenewalCertificateID(cert); nil != err {
+		t.Fatal(err)
+	} else if "aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE" != id {
+		t.Errorf("Unexpected certificate ID %#v", id)
+	}
+
+	for _, test := range []struct {
+		serial int64
+		id     string
+	}{
+		// no leading zero if the high bit isn't set
+		{0x7f, "AQI.fw"},
+		{0x80, "AQI.AIA"},
+		{0x0100, "AQI.AQA"},
+		// zero is encoded as a single zero byte
+		{0, "AQI.AA"},
+	} {
+		cert := &x509.Certificate{AuthorityKeyId: []byte{1, 2}, SerialNumber: big.NewInt(test.serial)}
+		if id, err := RenewalCertificateID(cert); nil != err {
+			t.Fatal(err)
+		} else if test.id != id {
+			t.Errorf("Unexpected certificate ID for serial %#x: %#v, expected %#v", test.serial, id, test.id)
+		}
+	}
+
+	if _, err := RenewalCertificateID(&x509.Certificate{SerialNumber: big.NewInt(1)}); nil == err {
+		t.Errorf("Missing Authority Key Identifier wasn't reported")
+	}
+}
+
+func TestSelectRenewalTime(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	info := &RenewalInfo{SuggestedWindow: RenewalWindow{Start: start, End: start.Add(48 * time.Hour)}}
+	for i := 0; i < 100; i++ {
+		selected := info.SelectRenewalTime()
+		if selected.Before(info.SuggestedWindow.Start) || !selected.Before(info.SuggestedWindow.End) {
+			t.Fatalf("Selected renewal time %v not in window %v - %v", selected, info.SuggestedWindow.Start, info.SuggestedWindow.End)
+		}
+	}
+
+	// empty or inverted windows use the start
+	info.SuggestedWindow.End = start
+	if selected := info.SelectRenewalTime(); !selected.Equal(start) {
+		t.Errorf("Expected start of empty window, got %v", selected)
+	}
+	info.SuggestedWindow.End = start.Add(-time.Hour)
+	if selected := info.SelectRenewalTime(); !selected.Equal(start) {
+		t.Errorf("Expected start of inverted window, got %v", selected)
+	}
+}
+
+func TestUpdateRenewalTime(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := RenewalWindow{Start: start, End: start.Add(48 * time.Hour)}
+	previous := &RenewalInfo{SuggestedWindow: window, RenewalTime: start.Add(time.Hour)}
+
+	// same window: keep the selected time
+	info := &RenewalInfo{SuggestedWindow: window}
+	info.UpdateRenewalTime(previous)
+	if !info.RenewalTime.Equal(previous.RenewalTime) {
+		t.Errorf("Renewal time changed for the same window: %v", info.RenewalTime)
+	}
+
+	// changed window: select a new time in it
+	moved := RenewalWindow{Start: start.Add(-72 * time.Hour), End: start.Add(-71 * time.Hour)}
+	info = &RenewalInfo{SuggestedWindow: moved}
+	info.UpdateRenewalTime(previous)
+	if info.RenewalTime.Before(moved.Start) || info.RenewalTime.After(moved.End) {
+		t.Errorf("Renewal time %v not in changed window", info.RenewalTime)
+	}
+
+	// no previous time selected
+	info = &RenewalInfo{SuggestedWindow: window}
+	info.UpdateRenewalTime(&RenewalInfo{SuggestedWindow: window})
+	if info.RenewalTime.IsZero() {
+		t.Errorf("No renewal time selected")
+	}
+	info = &RenewalInfo{SuggestedWindow: window}
+	info.UpdateRenewalTime(nil)
+	if info.RenewalTime.IsZero() {
+		t.Errorf("No renewal time selected without previous info")
+	}
+}
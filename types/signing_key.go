@@ -1,19 +1,26 @@
 package types
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/json"
 	"encoding/pem"
+	"fmt"
+	"github.com/stbuehler/go-acme-client/signer"
 	"github.com/stbuehler/go-acme-client/utils"
-	jose "gopkg.in/square/go-jose.v1"
+	jose "gopkg.in/square/go-jose.v2"
+	"math/big"
 )
 
 // wrapper to marshal/unmarshal json
 type JSONSignature struct {
-	Signature *jose.JsonWebSignature
+	Signature *jose.JSONWebSignature
 }
 
 type staticNonceSource string
@@ -23,47 +30,188 @@ func (sns staticNonceSource) Nonce() (string, error) {
 }
 
 type SigningKey struct {
-	privateKey interface{}
+	// either a local private key (*rsa.PrivateKey, *ecdsa.PrivateKey or
+	// ed25519.PrivateKey) or an external signer
+	signer crypto.Signer
+	// reference for external signers (see package signer); stored instead
+	// of the private key
+	reference string
 }
 
-func (skey SigningKey) GetSignatureAlgorithm() jose.SignatureAlgorithm {
-	switch pkey := skey.privateKey.(type) {
-	case *ecdsa.PrivateKey:
+// JWS algorithm for a key; ECDSA algorithms are bound to a curve, so there
+// is none for P-224
+func signatureAlgorithm(publicKey crypto.PublicKey) (jose.SignatureAlgorithm, error) {
+	switch pkey := publicKey.(type) {
+	case *ecdsa.PublicKey:
 		switch pkey.Curve {
-		case elliptic.P224():
-			return jose.ES256
 		case elliptic.P256():
-			return jose.ES256
+			return jose.ES256, nil
 		case elliptic.P384():
-			return jose.ES384
+			return jose.ES384, nil
 		case elliptic.P521():
-			return jose.ES512
+			return jose.ES512, nil
 		default:
-			panic("Unknown elliptic curve")
+			return "", fmt.Errorf("Unsupported elliptic curve %s for account keys (use P-256, P-384 or P-521)", pkey.Curve.Params().Name)
 		}
-	case *rsa.PrivateKey:
-		return jose.RS256
+	case *rsa.PublicKey:
+		return jose.RS256, nil
+	case ed25519.PublicKey:
+		return jose.EdDSA, nil
 	default:
-		panic("Unkown private key type")
+		return "", utils.UnknownPrivateKey
+	}
+}
+
+// the constructors only accept keys with a known algorithm
+func (skey SigningKey) GetSignatureAlgorithm() jose.SignatureAlgorithm {
+	alg, err := signatureAlgorithm(skey.signer.Public())
+	if nil != err {
+		panic(err)
 	}
+	return alg
 }
 
-func (skey SigningKey) GetPublicKey() *jose.JsonWebKey {
-	return &jose.JsonWebKey{
-		Key:       utils.MustPublicKey(skey.privateKey),
+func (skey SigningKey) GetPublicKey() *jose.JSONWebKey {
+	return &jose.JSONWebKey{
+		Key:       skey.signer.Public(),
 		Algorithm: string(skey.GetSignatureAlgorithm()),
 	}
 }
+
+// empty for local keys
+func (skey SigningKey) Reference() string {
+	return skey.reference
+}
+
 func (skey SigningKey) EncryptPrivateKey(password string, alg x509.PEMCipher) (*pem.Block, error) {
-	return utils.EncryptPrivateKey(skey.privateKey, password, alg)
+	if 0 != len(skey.reference) {
+		return nil, fmt.Errorf("The private key of %s is not available", skey.reference)
+	}
+	return utils.EncryptPrivateKey(skey.signer, password, alg)
+}
+
+// go-jose handles local keys itself, external signers need a wrapper
+func (skey SigningKey) joseKey() interface{} {
+	if 0 != len(skey.reference) {
+		return externalJoseSigner{skey: skey}
+	}
+	return skey.signer
+}
+
+// implements jose.OpaqueSigner
+type externalJoseSigner struct {
+	skey SigningKey
+}
+
+func (es externalJoseSigner) Public() *jose.JSONWebKey {
+	return es.skey.GetPublicKey()
+}
+
+func (es externalJoseSigner) Algs() []jose.SignatureAlgorithm {
+	return []jose.SignatureAlgorithm{es.skey.GetSignatureAlgorithm()}
+}
+
+func (es externalJoseSigner) SignPayload(payload []byte, alg jose.SignatureAlgorithm) ([]byte, error) {
+	var hash crypto.Hash
+	switch alg {
+	case jose.RS256, jose.ES256:
+		hash = crypto.SHA256
+	case jose.ES384:
+		hash = crypto.SHA384
+	case jose.ES512:
+		hash = crypto.SHA512
+	case jose.EdDSA:
+		// Ed25519 signs the message itself
+	default:
+		return nil, fmt.Errorf("Unsupported signature algorithm %s", alg)
+	}
+
+	digest := payload
+	if 0 != hash {
+		h := hash.New()
+		h.Write(payload)
+		digest = h.Sum(nil)
+	}
+	signature, err := es.skey.signer.Sign(rand.Reader, digest, hash)
+	if nil != err {
+		return nil, err
+	}
+
+	// crypto.Signer returns ASN.1 encoded ECDSA signatures, JWS uses the
+	// fixed size concatenation of r and s
+	if pubKey, ok := es.skey.signer.Public().(*ecdsa.PublicKey); ok {
+		var ecSig struct {
+			R, S *big.Int
+		}
+		if _, err := asn1.Unmarshal(signature, &ecSig); nil != err {
+			return nil, fmt.Errorf("Couldn't decode ECDSA signature: %s", err)
+		}
+		size := (pubKey.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		ecSig.R.FillBytes(signature[:size])
+		ecSig.S.FillBytes(signature[size:])
+	}
+	return signature, nil
+}
+
+func (skey SigningKey) Sign(payload []byte, nonce string) (*jose.JSONWebSignature, error) {
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: skey.GetSignatureAlgorithm(),
+		Key:       skey.joseKey(),
+	}, &jose.SignerOptions{
+		NonceSource: staticNonceSource(nonce),
+		EmbedJWK:    true,
+	})
+	if nil != err {
+		return nil, err
+	}
+	return signer.Sign(payload)
+}
+
+// sign an ACME request: the protected header contains the target url and
+// either the account URL as "kid" or (if keyID is empty) the public key as
+// "jwk"
+func (skey SigningKey) SignRequest(payload []byte, nonce string, url string, keyID string) (*jose.JSONWebSignature, error) {
+	options := &jose.SignerOptions{
+		NonceSource: staticNonceSource(nonce),
+	}
+	options.WithHeader("url", url)
+
+	var key interface{} = skey.joseKey()
+	if 0 != len(keyID) {
+		key = jose.JSONWebKey{
+			Key:   skey.joseKey(),
+			KeyID: keyID,
+		}
+	} else {
+		options.EmbedJWK = true
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: skey.GetSignatureAlgorithm(),
+		Key:       key,
+	}, options)
+	if nil != err {
+		return nil, err
+	}
+	return signer.Sign(payload)
 }
 
-func (skey SigningKey) Sign(payload []byte, nonce string) (*jose.JsonWebSignature, error) {
-	signer, err := jose.NewSigner(skey.GetSignatureAlgorithm(), skey.privateKey)
+// sign the inner JWS of a keyChange request with the new key: it embeds
+// the new public key as "jwk" and has no nonce
+func (skey SigningKey) SignKeyChange(payload []byte, url string) (*jose.JSONWebSignature, error) {
+	options := &jose.SignerOptions{
+		EmbedJWK: true,
+	}
+	options.WithHeader("url", url)
+
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: skey.GetSignatureAlgorithm(),
+		Key:       skey.joseKey(),
+	}, options)
 	if nil != err {
 		return nil, err
 	}
-	signer.SetNonceSource(staticNonceSource(nonce))
 	return signer.Sign(payload)
 }
 
@@ -88,15 +236,87 @@ func CreateSigningKey(keyType utils.KeyType, curve utils.Curve, rsaBits *int) (S
 	if nil != err {
 		return SigningKey{}, err
 	}
-	return SigningKey{privateKey: pkey}, nil
+	return NewSigningKey(pkey)
+}
+
+func NewSigningKey(privateKey interface{}) (SigningKey, error) {
+	switch privateKey.(type) {
+	case *ecdsa.PrivateKey, *rsa.PrivateKey, ed25519.PrivateKey:
+	default:
+		return SigningKey{}, utils.UnknownPrivateKey
+	}
+	pkey := privateKey.(crypto.Signer)
+	if _, err := signatureAlgorithm(pkey.Public()); nil != err {
+		return SigningKey{}, err
+	}
+	return SigningKey{signer: pkey}, nil
+}
+
+func NewExternalSigningKey(reference string) (SigningKey, error) {
+	pkey, err := signer.Open(reference)
+	if nil != err {
+		return SigningKey{}, err
+	}
+	if _, err := signatureAlgorithm(pkey.Public()); nil != err {
+		return SigningKey{}, err
+	}
+	return SigningKey{signer: pkey, reference: reference}, nil
+}
+
+type externalSigningKeyJson struct {
+	Reference string
+	PublicKey *jose.JSONWebKey
+}
+
+// local keys are exported as (encrypted) private key, external signers as
+// reference and public key
+func (skey SigningKey) ExportPem(password string, alg x509.PEMCipher) (*pem.Block, error) {
+	if 0 == len(skey.reference) {
+		return skey.EncryptPrivateKey(password, alg)
+	}
+	data, err := json.Marshal(externalSigningKeyJson{
+		Reference: skey.reference,
+		PublicKey: skey.GetPublicKey(),
+	})
+	if nil != err {
+		return nil, err
+	}
+	block := &pem.Block{
+		Type:  pemTypeAcmeExternalSigningKey,
+		Bytes: data,
+	}
+	if err := utils.EncryptPemBlock(block, password, alg); nil != err {
+		return nil, err
+	}
+	return block, nil
 }
 
 func LoadSigningKey(block pem.Block) (SigningKey, error) {
+	if pemTypeAcmeExternalSigningKey == block.Type {
+		var external externalSigningKeyJson
+		if err := json.Unmarshal(block.Bytes, &external); nil != err {
+			return SigningKey{}, err
+		}
+		if nil == external.PublicKey {
+			// the public key is needed right away
+			return NewExternalSigningKey(external.Reference)
+		}
+		// commands which don't sign anything shouldn't need the token;
+		// the signer is opened on first use (and must still have the
+		// registered key)
+		if _, err := signatureAlgorithm(external.PublicKey.Key); nil != err {
+			return SigningKey{}, err
+		}
+		return SigningKey{
+			signer:    signer.OpenLazy(external.Reference, external.PublicKey.Key),
+			reference: external.Reference,
+		}, nil
+	}
 	privateKey, err := utils.DecodePrivateKey(block)
 	if nil != err {
 		return SigningKey{}, err
 	}
-	return SigningKey{privateKey: privateKey}, nil
+	return NewSigningKey(privateKey)
 }
 
 func (sig JSONSignature) MarshalJSON() ([]byte, error) {
@@ -0,0 +1,153 @@
+package types
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/stbuehler/go-acme-client/signer"
+	"github.com/stbuehler/go-acme-client/utils"
+	jose "gopkg.in/square/go-jose.v2"
+	"testing"
+)
+
+func TestEd25519SignRequest(t *testing.T) {
+	skey, err := CreateSigningKey(utils.KeyEd25519, utils.CurveP256, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if jose.EdDSA != skey.GetSignatureAlgorithm() {
+		t.Errorf("Expected EdDSA, got %s", skey.GetSignatureAlgorithm())
+	}
+
+	sig, err := skey.SignRequest([]byte(`{"test":true}`), "nonce", "https://example.com/acme/new-order", "https://example.com/acme/acct/1")
+	if nil != err {
+		t.Fatalf("Couldn't sign request: %s", err)
+	}
+	parsed, err := jose.ParseSigned(sig.FullSerialize())
+	if nil != err {
+		t.Fatalf("Couldn't parse signed request: %s", err)
+	}
+	header := parsed.Signatures[0].Protected
+	if "https://example.com/acme/acct/1" != header.KeyID || nil != header.JSONWebKey {
+		t.Errorf("Request should be signed with kid and without jwk, got kid %#v", header.KeyID)
+	}
+	if url, _ := header.ExtraHeaders["url"].(string); "https://example.com/acme/new-order" != url {
+		t.Errorf("Wrong url in protected header: %#v", header.ExtraHeaders["url"])
+	}
+
+	var payload []byte
+	var nonce string
+	if err := skey.Verify(sig.FullSerialize(), &payload, &nonce); nil != err {
+		t.Fatalf("Couldn't verify signature: %s", err)
+	}
+	if `{"test":true}` != string(payload) || "nonce" != nonce {
+		t.Errorf("Unexpected payload %s or nonce %s", payload, nonce)
+	}
+
+	other, err := CreateSigningKey(utils.KeyEd25519, utils.CurveP256, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err := other.Verify(sig.FullSerialize(), nil, nil); nil == err {
+		t.Errorf("Signature verified with a different key")
+	}
+}
+
+func TestSigningKeyPKCS8RoundTrip(t *testing.T) {
+	rsaBits := 2048
+	for _, keyType := range []utils.KeyType{utils.KeyRSA, utils.KeyEcdsa, utils.KeyEd25519} {
+		pkey, err := utils.CreatePrivateKey(keyType, utils.CurveP256, &rsaBits)
+		if nil != err {
+			t.Fatal(err)
+		}
+		block, err := utils.EncodePrivateKeyPKCS8(pkey)
+		if nil != err {
+			t.Fatalf("Couldn't encode %s key as PKCS#8: %s", keyType, err)
+		}
+
+		// imported like a registration key in a PEM file
+		skey, err := LoadSigningKey(*block)
+		if nil != err {
+			t.Fatalf("Couldn't load PKCS#8 %s key: %s", keyType, err)
+		}
+		exported, err := skey.ExportPem("", utils.PemDefaultCipher)
+		if nil != err {
+			t.Fatalf("Couldn't export %s key: %s", keyType, err)
+		}
+		reloaded, err := LoadSigningKey(*exported)
+		if nil != err {
+			t.Fatalf("Couldn't reload exported %s key (%s): %s", keyType, exported.Type, err)
+		}
+
+		expected, _ := skey.GetPublicKey().Thumbprint(crypto.SHA256)
+		actual, _ := reloaded.GetPublicKey().Thumbprint(crypto.SHA256)
+		if string(expected) != string(actual) {
+			t.Errorf("%s key changed in round trip:\n%s", keyType, pem.EncodeToMemory(exported))
+		}
+	}
+}
+
+func TestRejectP224SigningKey(t *testing.T) {
+	pkey, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := NewSigningKey(pkey); nil == err {
+		t.Errorf("P-224 account keys should be rejected")
+	}
+	block, err := utils.EncodePrivateKeyPKCS8(pkey)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := LoadSigningKey(*block); nil == err {
+		t.Errorf("Loading P-224 account keys should fail")
+	}
+}
+
+// loading a registration must not open (and ask the PIN for) the token
+func TestLoadExternalSigningKeyLazy(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	opened := 0
+	signer.Register("test-lazy", func(reference string) (crypto.Signer, error) {
+		opened++
+		return key, nil
+	})
+
+	external, err := NewExternalSigningKey("test-lazy:account")
+	if nil != err {
+		t.Fatal(err)
+	}
+	block, err := external.ExportPem("", x509.PEMCipherAES256)
+	if nil != err {
+		t.Fatal(err)
+	}
+	opened = 0
+
+	skey, err := LoadSigningKey(*block)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 0 != opened {
+		t.Errorf("External signer opened while loading")
+	}
+	if "test-lazy:account" != skey.Reference() || jose.ES256 != skey.GetSignatureAlgorithm() {
+		t.Errorf("Unexpected reference %#v or algorithm %s", skey.Reference(), skey.GetSignatureAlgorithm())
+	}
+
+	sig, err := skey.Sign([]byte(`{"test":true}`), "nonce")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 1 != opened {
+		t.Errorf("External signer opened %d times, expected once", opened)
+	}
+	if err := external.Verify(sig.FullSerialize(), nil, nil); nil != err {
+		t.Errorf("Couldn't verify signature: %s", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"math/big"
+	"net"
 	"time"
 )
 
@@ -22,7 +23,9 @@ type CertificateParameters struct {
 	Subject                   pkix.Name
 	Duration                  time.Duration
 	DNSNames                  []string
+	IPAddresses               []net.IP
 	SerialNumber              *big.Int
+	ExtraExtensions           []pkix.Extension
 }
 
 func CertificateToPem(cert *x509.Certificate) *pem.Block {
@@ -32,6 +35,34 @@ func CertificateToPem(cert *x509.Certificate) *pem.Block {
 	}
 }
 
+// concatenated PEM blocks of all certificates
+func CertificatesToPem(certs []*x509.Certificate) []byte {
+	var data []byte
+	for _, cert := range certs {
+		data = append(data, pem.EncodeToMemory(CertificateToPem(cert))...)
+	}
+	return data
+}
+
+// parse all CERTIFICATE blocks (other blocks are ignored)
+func ParseCertificatesPem(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if nil == block {
+			return certs, nil
+		} else if pemTypeCertificate != block.Type {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); nil != err {
+			return nil, err
+		} else {
+			certs = append(certs, cert)
+		}
+	}
+}
+
 func MakeSerialNumber() (*big.Int, error) {
 	return rand.Int(rand.Reader, serialNumberLimit)
 }
@@ -50,7 +81,10 @@ func MakeCertificate(parameters CertificateParameters) (*pem.Block, error) {
 	if x509.UnknownSignatureAlgorithm == parameters.DefaultSignatureAlgorithm {
 		parameters.DefaultSignatureAlgorithm = x509.SHA512WithRSA
 	}
-	sigAlg := PickSignatureAlgorithm(parameters.SigningKey, parameters.DefaultSignatureAlgorithm)
+	sigAlg, err := PickSignatureAlgorithm(parameters.SigningKey, parameters.DefaultSignatureAlgorithm)
+	if nil != err {
+		return nil, err
+	}
 	if 0 == len(parameters.Subject.CommonName) {
 		if 0 == len(parameters.DNSNames) {
 			return nil, fmt.Errorf("Need either CommonName or at least one domain for certificate")
@@ -83,8 +117,10 @@ func MakeCertificate(parameters CertificateParameters) (*pem.Block, error) {
 		IsCA:                        false,
 		MaxPathLen:                  0,
 		DNSNames:                    parameters.DNSNames,
+		IPAddresses:                 parameters.IPAddresses,
 		PermittedDNSDomainsCritical: false,
 		PermittedDNSDomains:         []string{},
+		ExtraExtensions:             parameters.ExtraExtensions,
 	}
 
 	parent := parameters.ParentCertificate
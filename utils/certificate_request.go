@@ -5,6 +5,7 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"net"
 )
 
 const pemTypeCertificateRequest = "CERTIFICATE REQUEST"
@@ -14,6 +15,7 @@ type CertificateRequestParameters struct {
 	DefaultSignatureAlgorithm x509.SignatureAlgorithm
 	Subject                   pkix.Name
 	DNSNames                  []string
+	IPAddresses               []net.IP
 }
 
 func MakeCertificateRequest(parameters CertificateRequestParameters) (*pem.Block, error) {
@@ -24,7 +26,10 @@ func MakeCertificateRequest(parameters CertificateRequestParameters) (*pem.Block
 	if x509.UnknownSignatureAlgorithm == parameters.DefaultSignatureAlgorithm {
 		parameters.DefaultSignatureAlgorithm = x509.SHA256WithRSA
 	}
-	sigAlg := PickSignatureAlgorithm(parameters.PrivateKey, parameters.DefaultSignatureAlgorithm)
+	sigAlg, err := PickSignatureAlgorithm(parameters.PrivateKey, parameters.DefaultSignatureAlgorithm)
+	if nil != err {
+		return nil, err
+	}
 
 	if 0 == len(parameters.Subject.CommonName) {
 		if 0 != len(parameters.DNSNames) {
@@ -37,6 +42,7 @@ func MakeCertificateRequest(parameters CertificateRequestParameters) (*pem.Block
 		PublicKey:          publicKey,
 		Subject:            parameters.Subject,
 		DNSNames:           parameters.DNSNames,
+		IPAddresses:        parameters.IPAddresses,
 	}
 
 	csr, err := x509.CreateCertificateRequest(rand.Reader, &req, parameters.PrivateKey)
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"context"
+	"flag"
+	"net"
+)
+
+// DNS server ("host" or "host:port") to use for looking up challenge
+// records; empty uses the system resolver
+var DNSResolver string
+
+func AddDNSFlags(flagset *flag.FlagSet) {
+	flagset.StringVar(&DNSResolver, "dns-resolver", "", "DNS server (host or host:port) to verify dns-01 challenges with (default: system resolver)")
+}
+
+func resolver() *net.Resolver {
+	if 0 == len(DNSResolver) {
+		return net.DefaultResolver
+	}
+	server := DNSResolver
+	if _, _, err := net.SplitHostPort(server); nil != err {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+func LookupTXT(name string) ([]string, error) {
+	return resolver().LookupTXT(context.Background(), name)
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// resolve user and group names (or numeric ids); empty names result in -1
+// (don't change)
+func LookupOwner(userName string, groupName string) (uid int, gid int, err error) {
+	uid, gid = -1, -1
+	if 0 != len(userName) {
+		if u, err := user.Lookup(userName); nil == err {
+			userName = u.Uid
+		} else if _, err := user.LookupId(userName); nil != err {
+			return -1, -1, fmt.Errorf("Unknown user %#v", userName)
+		}
+		if uid, err = strconv.Atoi(userName); nil != err {
+			return -1, -1, fmt.Errorf("Invalid uid %#v", userName)
+		}
+	}
+	if 0 != len(groupName) {
+		if g, err := user.LookupGroup(groupName); nil == err {
+			groupName = g.Gid
+		} else if _, err := user.LookupGroupId(groupName); nil != err {
+			return -1, -1, fmt.Errorf("Unknown group %#v", groupName)
+		}
+		if gid, err = strconv.Atoi(groupName); nil != err {
+			return -1, -1, fmt.Errorf("Invalid gid %#v", groupName)
+		}
+	}
+	return uid, gid, nil
+}
+
+// replace file atomically (through a temporary file in the same directory
+// and rename); doesn't touch the file if content, mode and owner already
+// match. uid/gid -1 don't change the owner.
+func WriteFileAtomic(filename string, data []byte, mode os.FileMode, uid int, gid int) (changed bool, err error) {
+	if unchangedFile(filename, data, mode, uid, gid) {
+		return false, nil
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if nil != err {
+		return false, err
+	}
+	tmpName := tmpFile.Name()
+	defer func() {
+		if nil != err {
+			os.Remove(tmpName)
+		}
+	}()
+
+	if _, err = tmpFile.Write(data); nil != err {
+		tmpFile.Close()
+		return false, err
+	}
+	if err = tmpFile.Sync(); nil != err {
+		tmpFile.Close()
+		return false, err
+	}
+	if err = tmpFile.Close(); nil != err {
+		return false, err
+	}
+	if err = os.Chmod(tmpName, mode); nil != err {
+		return false, err
+	}
+	if -1 != uid || -1 != gid {
+		if err = os.Chown(tmpName, uid, gid); nil != err {
+			return false, err
+		}
+	}
+	if err = os.Rename(tmpName, filename); nil != err {
+		return false, err
+	}
+	return true, nil
+}
+
+func unchangedFile(filename string, data []byte, mode os.FileMode, uid int, gid int) bool {
+	if stat, err := os.Stat(filename); nil != err || stat.Mode().Perm() != mode.Perm() {
+		return false
+	} else if !fileOwnedBy(stat, uid, gid) {
+		return false
+	} else if content, err := ioutil.ReadFile(filename); nil != err {
+		return false
+	} else {
+		return bytes.Equal(content, data)
+	}
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cert.pem")
+
+	if changed, err := WriteFileAtomic(filename, []byte("first"), 0644, -1, -1); nil != err || !changed {
+		t.Fatalf("Creating file failed: changed=%v, %v", changed, err)
+	}
+	if content, err := ioutil.ReadFile(filename); nil != err || "first" != string(content) {
+		t.Fatalf("Unexpected content %#v (%v)", string(content), err)
+	}
+
+	// same content and mode: file isn't touched
+	stat, _ := os.Stat(filename)
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes(filename, past, past)
+	if changed, err := WriteFileAtomic(filename, []byte("first"), 0644, -1, -1); nil != err || changed {
+		t.Fatalf("Unchanged file was written: changed=%v, %v", changed, err)
+	}
+	if newStat, _ := os.Stat(filename); !newStat.ModTime().Equal(past) || !os.SameFile(stat, newStat) {
+		t.Errorf("Unchanged file was replaced")
+	}
+
+	if changed, err := WriteFileAtomic(filename, []byte("second"), 0644, -1, -1); nil != err || !changed {
+		t.Fatalf("Changed content wasn't written: changed=%v, %v", changed, err)
+	}
+	if content, _ := ioutil.ReadFile(filename); "second" != string(content) {
+		t.Errorf("Unexpected content %#v", string(content))
+	}
+
+	if "windows" != runtime.GOOS {
+		// mode change only
+		if changed, err := WriteFileAtomic(filename, []byte("second"), 0600, -1, -1); nil != err || !changed {
+			t.Fatalf("Changed mode wasn't applied: changed=%v, %v", changed, err)
+		}
+		if stat, _ := os.Stat(filename); 0600 != stat.Mode().Perm() {
+			t.Errorf("Unexpected mode %v", stat.Mode().Perm())
+		}
+	}
+
+	// no temporary files left behind
+	if entries, _ := ioutil.ReadDir(filepath.Dir(filename)); 1 != len(entries) {
+		t.Errorf("Expected a single file in the directory, got %d", len(entries))
+	}
+}
+
+func TestLookupOwner(t *testing.T) {
+	if uid, gid, err := LookupOwner("", ""); nil != err || -1 != uid || -1 != gid {
+		t.Errorf("Expected -1/-1 for empty names, got %d/%d (%v)", uid, gid, err)
+	}
+
+	current, err := user.Current()
+	if nil != err {
+		t.Skipf("Couldn't lookup current user: %s", err)
+	}
+	expectedUid, err := strconv.Atoi(current.Uid)
+	if nil != err {
+		t.Skipf("Non-numeric uid %#v", current.Uid)
+	}
+	expectedGid, _ := strconv.Atoi(current.Gid)
+	group, err := user.LookupGroupId(current.Gid)
+	if nil != err {
+		t.Skipf("Couldn't lookup group of current user: %s", err)
+	}
+
+	// names and numeric ids
+	if uid, gid, err := LookupOwner(current.Username, group.Name); nil != err || expectedUid != uid || expectedGid != gid {
+		t.Errorf("Lookup by name: got %d/%d (%v), expected %d/%d", uid, gid, err, expectedUid, expectedGid)
+	}
+	if uid, gid, err := LookupOwner(current.Uid, current.Gid); nil != err || expectedUid != uid || expectedGid != gid {
+		t.Errorf("Lookup by id: got %d/%d (%v), expected %d/%d", uid, gid, err, expectedUid, expectedGid)
+	}
+	if uid, gid, err := LookupOwner("", current.Gid); nil != err || -1 != uid || expectedGid != gid {
+		t.Errorf("Lookup group only: got %d/%d (%v)", uid, gid, err)
+	}
+
+	if _, _, err := LookupOwner("no-such-user-acme-test", ""); nil == err {
+		t.Errorf("Unknown user wasn't reported")
+	}
+	if _, _, err := LookupOwner("", "no-such-group-acme-test"); nil == err {
+		t.Errorf("Unknown group wasn't reported")
+	}
+}
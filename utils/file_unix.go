@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package utils
+
+import (
+	"os"
+	"syscall"
+)
+
+func fileOwnedBy(stat os.FileInfo, uid int, gid int) bool {
+	if sys, ok := stat.Sys().(*syscall.Stat_t); ok {
+		return (-1 == uid || uint32(uid) == sys.Uid) && (-1 == gid || uint32(gid) == sys.Gid)
+	}
+	return true
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+// +build !windows
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestWriteFileAtomicOwner(t *testing.T) {
+	if 0 != os.Getuid() {
+		t.Skip("Changing the owner requires root")
+	}
+	filename := filepath.Join(t.TempDir(), "key.pem")
+	if _, err := WriteFileAtomic(filename, []byte("key"), 0600, 0, 0); nil != err {
+		t.Fatal(err)
+	}
+	// owner change only
+	if changed, err := WriteFileAtomic(filename, []byte("key"), 0600, 1, 1); nil != err || !changed {
+		t.Fatalf("Changed owner wasn't applied: changed=%v, %v", changed, err)
+	}
+	stat, _ := os.Stat(filename)
+	if sys := stat.Sys().(*syscall.Stat_t); 1 != sys.Uid || 1 != sys.Gid {
+		t.Errorf("Unexpected owner %d:%d", sys.Uid, sys.Gid)
+	}
+	if changed, err := WriteFileAtomic(filename, []byte("key"), 0600, 1, -1); nil != err || changed {
+		t.Errorf("Unchanged owner caused write: changed=%v, %v", changed, err)
+	}
+}
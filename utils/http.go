@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"regexp"
+	"strings"
 )
 
 type HttpRequestHeader struct {
@@ -33,11 +34,25 @@ type HttpResponse struct {
 	Status      string
 	Location    string
 	ContentType string
-	Links       map[string]HttpLink
+	// all links by relation type, in the order they were sent
+	Links map[string][]HttpLink
 }
 
-var parseLinkHeader = regexp.MustCompile(`^\s*<([^>]*)>\s*(.*)$`)
-var parseLinkHeaderProps = regexp.MustCompile(`\s*;([^=]+)\s*=\s*"([^"]*)"`)
+// first link with the given relation type (or "")
+func (resp *HttpResponse) Link(rel string) string {
+	if links := resp.Links[rel]; 0 != len(links) {
+		return links[0].URL
+	}
+	return ""
+}
+
+// a single header can contain multiple (comma separated) links
+var parseLinkHeader = regexp.MustCompile(`<([^>]*)>([^<]*)`)
+var parseLinkHeaderProps = regexp.MustCompile(`;\s*([^=;,\s]+)\s*=\s*(?:"([^"]*)"|([^;,\s]*))`)
+
+// if set, requests are passed to it instead of being sent over the
+// network (see requests.EnableOfflineBundle)
+var HttpTransport func(req *HttpRequest) (*http.Response, error)
 
 func (req *HttpRequest) Run() (*HttpResponse, error) {
 	var body io.Reader
@@ -57,34 +72,47 @@ func (req *HttpRequest) Run() (*HttpResponse, error) {
 	}
 	DebugLogHttpRequest(req, hReq)
 
-	resp := HttpResponse{
-		Links: make(map[string]HttpLink),
+	var rawResponse *http.Response
+	if nil != HttpTransport {
+		rawResponse, err = HttpTransport(req)
+	} else {
+		rawResponse, err = http.DefaultClient.Do(hReq)
 	}
-	if resp.RawResponse, err = http.DefaultClient.Do(hReq); nil != err {
+	if nil != err {
 		return nil, err
 	}
+	return ReadHttpResponse(rawResponse)
+}
+
+// parse headers and read the body of a response; closes the body
+func ReadHttpResponse(rawResponse *http.Response) (*HttpResponse, error) {
+	resp := HttpResponse{
+		RawResponse: rawResponse,
+		Links:       make(map[string][]HttpLink),
+	}
 	defer resp.RawResponse.Body.Close()
 	resp.StatusCode = resp.RawResponse.StatusCode
 	resp.Status = resp.RawResponse.Status
 	resp.Location = resp.RawResponse.Header.Get("Location")
 	resp.ContentType = resp.RawResponse.Header.Get("Content-Type")
 
-	for _, link := range resp.RawResponse.Header["Link"] {
-		if matches := parseLinkHeader.FindStringSubmatch(link); nil != matches {
+	for _, header := range resp.RawResponse.Header["Link"] {
+		for _, matches := range parseLinkHeader.FindAllStringSubmatch(header, -1) {
 			link := HttpLink{
 				URL:        matches[1],
 				Properties: make(map[string]string),
 			}
 			for _, propMatches := range parseLinkHeaderProps.FindAllStringSubmatch(matches[2], -1) {
-				link.Properties[propMatches[1]] = propMatches[2]
+				link.Properties[propMatches[1]] = propMatches[2] + propMatches[3]
 			}
-			rel := link.Properties["rel"]
-			if 0 != len(rel) {
-				resp.Links[rel] = link
+			// rel can list multiple relation types
+			for _, rel := range strings.Fields(link.Properties["rel"]) {
+				resp.Links[rel] = append(resp.Links[rel], link)
 			}
 		}
 	}
 
+	var err error
 	if resp.Body, err = ioutil.ReadAll(resp.RawResponse.Body); nil != err {
 		resp.Body = []byte{}
 		DebugLogHttpResponse(&resp)
@@ -93,7 +121,9 @@ func (req *HttpRequest) Run() (*HttpResponse, error) {
 	DebugLogHttpResponse(&resp)
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("HTTP error code: %s", resp.Status)
+		// also return the response: the caller might want to look at the
+		// headers or the error document
+		return &resp, fmt.Errorf("HTTP error code: %s", resp.Status)
 	}
 
 	return &resp, nil
@@ -2,20 +2,23 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
 )
 
 type KeyType string
 
 const (
-	KeyEcdsa KeyType = "ECDSA"
-	KeyRSA   KeyType = "RSA"
+	KeyEcdsa   KeyType = "ECDSA"
+	KeyRSA     KeyType = "RSA"
+	KeyEd25519 KeyType = "Ed25519"
 )
 
 type Curve string
@@ -34,6 +37,7 @@ var InvalidRsaBits = errors.New("Invalid RSA bits (must be in range 2048..4096)"
 
 const pemTypeEcPrivateKey = "EC PRIVATE KEY"
 const pemTypeRsaPrivateKey = "RSA PRIVATE KEY"
+const pemTypePrivateKey = "PRIVATE KEY"
 const pemTypePublicKey = "PUBLIC KEY"
 
 func CreateEcdsaPrivateKey(curve elliptic.Curve) (*ecdsa.PrivateKey, error) {
@@ -44,13 +48,16 @@ func CreateRsaPrivateKey(bits int) (*rsa.PrivateKey, error) {
 	return rsa.GenerateKey(rand.Reader, bits)
 }
 
+func CreateEd25519PrivateKey() (ed25519.PrivateKey, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	return privateKey, err
+}
+
 func CreatePrivateKey(keyType KeyType, curve Curve, rsaBits *int) (interface{}, error) {
 	switch keyType {
 	case KeyEcdsa:
 		switch curve {
-		case curveDefault:
-			return CreateEcdsaPrivateKey(elliptic.P521())
-		case CurveP256:
+		case curveDefault, CurveP256:
 			return CreateEcdsaPrivateKey(elliptic.P256())
 		case CurveP384:
 			return CreateEcdsaPrivateKey(elliptic.P384())
@@ -68,6 +75,8 @@ func CreatePrivateKey(keyType KeyType, curve Curve, rsaBits *int) (interface{},
 			return nil, InvalidRsaBits
 		}
 		return CreateRsaPrivateKey(bits)
+	case KeyEd25519:
+		return CreateEd25519PrivateKey()
 	default:
 		return nil, UnknownKeyType
 	}
@@ -86,6 +95,10 @@ func PublicKey(privateKey interface{}) (pubKey interface{}, err error) {
 		pubKey = pkey
 	case *rsa.PrivateKey:
 		pubKey = &pkey.PublicKey
+	case ed25519.PublicKey:
+		pubKey = pkey
+	case ed25519.PrivateKey:
+		pubKey = pkey.Public()
 	default:
 		err = UnknownPrivateKey
 	}
@@ -100,21 +113,25 @@ func MustPublicKey(privateKey interface{}) interface{} {
 	return pubKey
 }
 
-func PickSignatureAlgorithm(privateKey interface{}, defaultAlg x509.SignatureAlgorithm) x509.SignatureAlgorithm {
+// only the curves accepted for account keys (P-256, P-384 and P-521) are
+// supported; CAs don't issue certificates for P-224 keys either
+func PickSignatureAlgorithm(privateKey interface{}, defaultAlg x509.SignatureAlgorithm) (x509.SignatureAlgorithm, error) {
 	switch pkey := privateKey.(type) {
 	case *ecdsa.PrivateKey:
 		switch pkey.Curve {
-		case elliptic.P224():
-			return x509.ECDSAWithSHA256
 		case elliptic.P256():
-			return x509.ECDSAWithSHA256
+			return x509.ECDSAWithSHA256, nil
 		case elliptic.P384():
-			return x509.ECDSAWithSHA384
+			return x509.ECDSAWithSHA384, nil
 		case elliptic.P521():
-			return x509.ECDSAWithSHA512
+			return x509.ECDSAWithSHA512, nil
+		default:
+			return x509.UnknownSignatureAlgorithm, fmt.Errorf("Unsupported elliptic curve %s (use P-256, P-384 or P-521)", pkey.Curve.Params().Name)
 		}
+	case ed25519.PrivateKey:
+		return x509.PureEd25519, nil
 	}
-	return defaultAlg
+	return defaultAlg, nil
 }
 
 func EncodePrivateKey(privateKey interface{}) (*pem.Block, error) {
@@ -133,17 +150,34 @@ func EncodePrivateKey(privateKey interface{}) (*pem.Block, error) {
 			Type:  pemTypeRsaPrivateKey,
 			Bytes: x509.MarshalPKCS1PrivateKey(pkey),
 		}, nil
+	case ed25519.PrivateKey:
+		// there is no key type specific format for Ed25519
+		return EncodePrivateKeyPKCS8(pkey)
 	default:
 		return nil, UnknownPrivateKey
 	}
 }
 
+// "PRIVATE KEY" block (PKCS#8) for any supported key type
+func EncodePrivateKeyPKCS8(privateKey interface{}) (*pem.Block, error) {
+	data, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if nil != err {
+		return nil, err
+	}
+	return &pem.Block{
+		Type:  pemTypePrivateKey,
+		Bytes: data,
+	}, nil
+}
+
 func DecodePrivateKey(block pem.Block) (interface{}, error) {
 	switch block.Type {
 	case pemTypeEcPrivateKey:
 		return x509.ParseECPrivateKey(block.Bytes)
 	case pemTypeRsaPrivateKey:
 		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case pemTypePrivateKey:
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
 	default:
 		return nil, UnknownPrivateKey
 	}
@@ -160,7 +194,7 @@ func EncryptPrivateKey(privateKey interface{}, password string, alg x509.PEMCiph
 }
 
 func LoadFirstPrivateKey(r io.Reader, prompt func() (string, error)) (interface{}, error) {
-	if block, err := FirstPemBlock(r, pemTypeEcPrivateKey, pemTypeRsaPrivateKey); nil != err {
+	if block, err := FirstPemBlock(r, pemTypeEcPrivateKey, pemTypeRsaPrivateKey, pemTypePrivateKey); nil != err {
 		return nil, err
 	} else if err := DecryptPemBlock(block, prompt); nil != err {
 		return nil, err
@@ -236,6 +270,8 @@ func (keyType KeyType) IsValid() bool {
 		return true
 	case KeyRSA:
 		return true
+	case KeyEd25519:
+		return true
 	default:
 		return false
 	}
@@ -254,3 +290,21 @@ func (keyType *KeyType) Set(v string) error {
 		return UnknownKeyType
 	}
 }
+
+// whether an existing private key has the given parameters (rsaBits and
+// curve are only checked for the respective key type)
+func PrivateKeyMatches(privateKey interface{}, keyType KeyType, curve Curve, rsaBits int) bool {
+	switch pkey := privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		if curveDefault == curve {
+			curve = CurveP256
+		}
+		return KeyEcdsa == keyType && string(curve) == pkey.Curve.Params().Name
+	case *rsa.PrivateKey:
+		return KeyRSA == keyType && rsaBits == pkey.N.BitLen()
+	case ed25519.PrivateKey:
+		return KeyEd25519 == keyType
+	default:
+		return false
+	}
+}
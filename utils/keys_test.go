@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"testing"
+)
+
+type privateKeyEqual interface {
+	Equal(x crypto.PrivateKey) bool
+}
+
+func testPrivateKeys(t *testing.T) map[string]interface{} {
+	keys := make(map[string]interface{})
+	rsaBits := 2048
+	for name, create := range map[string]func() (interface{}, error){
+		"RSA":     func() (interface{}, error) { return CreatePrivateKey(KeyRSA, CurveP256, &rsaBits) },
+		"ECDSA":   func() (interface{}, error) { return CreatePrivateKey(KeyEcdsa, CurveP384, nil) },
+		"Ed25519": func() (interface{}, error) { return CreatePrivateKey(KeyEd25519, CurveP256, nil) },
+	} {
+		key, err := create()
+		if nil != err {
+			t.Fatalf("Couldn't create %s key: %s", name, err)
+		}
+		keys[name] = key
+	}
+	return keys
+}
+
+func TestPrivateKeyRoundTrip(t *testing.T) {
+	for name, key := range testPrivateKeys(t) {
+		block, err := EncodePrivateKey(key)
+		if nil != err {
+			t.Fatalf("Couldn't encode %s key: %s", name, err)
+		}
+		decoded, err := DecodePrivateKey(*block)
+		if nil != err {
+			t.Fatalf("Couldn't decode %s key (%s): %s", name, block.Type, err)
+		}
+		if !key.(privateKeyEqual).Equal(decoded) {
+			t.Errorf("%s key changed in %s round trip", name, block.Type)
+		}
+	}
+}
+
+func TestPrivateKeyPKCS8RoundTrip(t *testing.T) {
+	for name, key := range testPrivateKeys(t) {
+		block, err := EncodePrivateKeyPKCS8(key)
+		if nil != err {
+			t.Fatalf("Couldn't encode %s key: %s", name, err)
+		}
+		if "PRIVATE KEY" != block.Type {
+			t.Errorf("Unexpected PKCS#8 block type %s", block.Type)
+		}
+		decoded, err := DecodePrivateKey(*block)
+		if nil != err {
+			t.Fatalf("Couldn't decode PKCS#8 %s key: %s", name, err)
+		}
+		if !key.(privateKeyEqual).Equal(decoded) {
+			t.Errorf("%s key changed in PKCS#8 round trip", name)
+		}
+	}
+}
+
+func TestPickSignatureAlgorithm(t *testing.T) {
+	keys := testPrivateKeys(t)
+	if alg, err := PickSignatureAlgorithm(keys["ECDSA"], x509.SHA256WithRSA); nil != err || x509.ECDSAWithSHA384 != alg {
+		t.Errorf("Expected ECDSAWithSHA384 for P-384, got %v (%v)", alg, err)
+	}
+	if alg, err := PickSignatureAlgorithm(keys["Ed25519"], x509.SHA256WithRSA); nil != err || x509.PureEd25519 != alg {
+		t.Errorf("Expected PureEd25519 for Ed25519, got %v (%v)", alg, err)
+	}
+	if alg, err := PickSignatureAlgorithm(keys["RSA"], x509.SHA384WithRSA); nil != err || x509.SHA384WithRSA != alg {
+		t.Errorf("Expected the default algorithm for RSA, got %v (%v)", alg, err)
+	}
+}
+
+func TestRejectP224(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := PickSignatureAlgorithm(key, x509.SHA256WithRSA); nil == err {
+		t.Errorf("P-224 keys should be rejected")
+	}
+	if _, err := MakeCertificateRequest(CertificateRequestParameters{
+		PrivateKey: key,
+		DNSNames:   []string{"example.com"},
+	}); nil == err {
+		t.Errorf("Certificate requests for P-224 keys should be rejected")
+	}
+}
+
+// make sure the generated Ed25519 keys are usable
+func TestEd25519PublicKey(t *testing.T) {
+	key, err := CreateEd25519PrivateKey()
+	if nil != err {
+		t.Fatal(err)
+	}
+	pubKey, err := PublicKey(key)
+	if nil != err {
+		t.Fatal(err)
+	}
+	sig := ed25519.Sign(key, []byte("test"))
+	if !ed25519.Verify(pubKey.(ed25519.PublicKey), []byte("test"), sig) {
+		t.Errorf("Ed25519 signature didn't verify")
+	}
+}
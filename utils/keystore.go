@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"software.sslmate.com/src/go-pkcs12"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// PKCS#12 file with the private key, the certificate and its chain. legacy
+// uses 3DES/RC2 and SHA1 (for old Java and Windows versions) instead of
+// AES and SHA256.
+func EncodePKCS12(privateKey interface{}, cert *x509.Certificate, chain []*x509.Certificate, password string, legacy bool) ([]byte, error) {
+	encoder := pkcs12.Modern
+	if legacy {
+		encoder = pkcs12.Legacy
+	}
+	return encoder.Encode(privateKey, cert, chain, password)
+}
+
+// the proprietary key protection algorithm of the Sun JKS keystore
+var oidJKSKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+const jksMagic = 0xfeedfeed
+const jksVersion = 2
+const jksPrivateKeyTag = 1
+
+type jksEncryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// java uses UTF-16BE bytes of the password
+func jksPasswordBytes(password string) []byte {
+	var result []byte
+	for _, c := range utf16.Encode([]rune(password)) {
+		result = append(result, byte(c>>8), byte(c))
+	}
+	return result
+}
+
+// salt, plain key XOR a SHA1 based keystream, SHA1 check of the plain key
+func jksProtectKey(privateKey interface{}, password []byte) ([]byte, error) {
+	plainKey, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if nil != err {
+		return nil, err
+	}
+
+	salt := make([]byte, sha1.Size)
+	if _, err := rand.Read(salt); nil != err {
+		return nil, err
+	}
+
+	protected := append([]byte{}, salt...)
+	digest := salt
+	for pos := 0; pos < len(plainKey); pos += sha1.Size {
+		h := sha1.New()
+		h.Write(password)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		for i := 0; i < sha1.Size && pos+i < len(plainKey); i++ {
+			protected = append(protected, plainKey[pos+i]^digest[i])
+		}
+	}
+
+	h := sha1.New()
+	h.Write(password)
+	h.Write(plainKey)
+	protected = h.Sum(protected)
+
+	return asn1.Marshal(jksEncryptedPrivateKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oidJKSKeyProtector,
+			Parameters: asn1.RawValue{Tag: asn1.TagNull},
+		},
+		EncryptedData: protected,
+	})
+}
+
+func jksWriteUTF(buf *bytes.Buffer, s string) error {
+	// java "modified UTF-8" equals UTF-8 for the strings we write (no NUL
+	// characters, no characters outside the BMP)
+	if len(s) > 0xffff {
+		return fmt.Errorf("String too long for JKS: %#v", s)
+	}
+	binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+	return nil
+}
+
+// JKS keystore with a single private key entry (the key is protected with
+// the keystore password)
+func EncodeJKS(alias string, privateKey interface{}, cert *x509.Certificate, chain []*x509.Certificate, password string, timestamp time.Time) ([]byte, error) {
+	// java lowercases aliases on lookup, but not when loading the keystore
+	alias = strings.ToLower(alias)
+	passwordBytes := jksPasswordBytes(password)
+	protectedKey, err := jksProtectKey(privateKey, passwordBytes)
+	if nil != err {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint32(jksMagic))
+	binary.Write(&buf, binary.BigEndian, uint32(jksVersion))
+	binary.Write(&buf, binary.BigEndian, uint32(1))
+
+	binary.Write(&buf, binary.BigEndian, uint32(jksPrivateKeyTag))
+	if err := jksWriteUTF(&buf, alias); nil != err {
+		return nil, err
+	}
+	binary.Write(&buf, binary.BigEndian, uint64(timestamp.UnixNano()/int64(time.Millisecond)))
+	binary.Write(&buf, binary.BigEndian, uint32(len(protectedKey)))
+	buf.Write(protectedKey)
+
+	certs := append([]*x509.Certificate{cert}, chain...)
+	binary.Write(&buf, binary.BigEndian, uint32(len(certs)))
+	for _, c := range certs {
+		jksWriteUTF(&buf, "X.509")
+		binary.Write(&buf, binary.BigEndian, uint32(len(c.Raw)))
+		buf.Write(c.Raw)
+	}
+
+	// integrity check over the whole keystore
+	h := sha1.New()
+	h.Write(passwordBytes)
+	h.Write([]byte("Mighty Aphrodite"))
+	h.Write(buf.Bytes())
+	buf.Write(h.Sum(nil))
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type jksTestEntry struct {
+	alias     string
+	timestamp time.Time
+	key       interface{}
+	certs     []*x509.Certificate
+}
+
+func jksReadUTF(t *testing.T, r io.Reader) string {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); nil != err {
+		t.Fatalf("Couldn't read string length: %s", err)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); nil != err {
+		t.Fatalf("Couldn't read string: %s", err)
+	}
+	return string(data)
+}
+
+func jksReadBytes(t *testing.T, r io.Reader) []byte {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); nil != err {
+		t.Fatalf("Couldn't read length: %s", err)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); nil != err {
+		t.Fatalf("Couldn't read data: %s", err)
+	}
+	return data
+}
+
+// the reverse of jksProtectKey, following sun.security.provider.KeyProtector
+func jksRecoverKey(t *testing.T, protectedKey []byte, password []byte) interface{} {
+	var info jksEncryptedPrivateKeyInfo
+	if rest, err := asn1.Unmarshal(protectedKey, &info); nil != err || 0 != len(rest) {
+		t.Fatalf("Couldn't parse EncryptedPrivateKeyInfo: %v", err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidJKSKeyProtector) {
+		t.Fatalf("Unexpected key protection algorithm %v", info.Algorithm.Algorithm)
+	}
+	data := info.EncryptedData
+	if len(data) < 2*sha1.Size {
+		t.Fatalf("Protected key too short")
+	}
+	salt, encrypted, check := data[:sha1.Size], data[sha1.Size:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+
+	plainKey := make([]byte, len(encrypted))
+	digest := salt
+	for pos := 0; pos < len(encrypted); pos += sha1.Size {
+		digestArray := sha1.Sum(append(append([]byte{}, password...), digest...))
+		digest = digestArray[:]
+		for i := 0; i < sha1.Size && pos+i < len(encrypted); i++ {
+			plainKey[pos+i] = encrypted[pos+i] ^ digest[i]
+		}
+	}
+	if expected := sha1.Sum(append(append([]byte{}, password...), plainKey...)); !bytes.Equal(expected[:], check) {
+		t.Fatalf("Key integrity check failed")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(plainKey)
+	if nil != err {
+		t.Fatalf("Couldn't parse recovered key: %s", err)
+	}
+	return key
+}
+
+// a minimal JKS reader, following sun.security.provider.JavaKeyStore
+func decodeJKS(t *testing.T, data []byte, password string) []jksTestEntry {
+	passwordBytes := jksPasswordBytes(password)
+	if len(data) < sha1.Size {
+		t.Fatalf("Keystore too short")
+	}
+	content, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	h := sha1.New()
+	h.Write(passwordBytes)
+	h.Write([]byte("Mighty Aphrodite"))
+	h.Write(content)
+	if !bytes.Equal(h.Sum(nil), digest) {
+		t.Fatalf("Keystore integrity check failed")
+	}
+
+	r := bytes.NewReader(content)
+	var header struct {
+		Magic, Version, Count uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); nil != err {
+		t.Fatalf("Couldn't read header: %s", err)
+	}
+	if jksMagic != header.Magic || jksVersion != header.Version {
+		t.Fatalf("Unexpected magic %#x / version %d", header.Magic, header.Version)
+	}
+
+	var entries []jksTestEntry
+	for i := uint32(0); i < header.Count; i++ {
+		var tag uint32
+		binary.Read(r, binary.BigEndian, &tag)
+		if jksPrivateKeyTag != tag {
+			t.Fatalf("Unexpected entry tag %d", tag)
+		}
+		var entry jksTestEntry
+		entry.alias = jksReadUTF(t, r)
+		var millis uint64
+		binary.Read(r, binary.BigEndian, &millis)
+		entry.timestamp = time.Unix(0, int64(millis)*int64(time.Millisecond))
+		entry.key = jksRecoverKey(t, jksReadBytes(t, r), passwordBytes)
+
+		var certCount uint32
+		binary.Read(r, binary.BigEndian, &certCount)
+		for j := uint32(0); j < certCount; j++ {
+			if certType := jksReadUTF(t, r); "X.509" != certType {
+				t.Fatalf("Unexpected certificate type %#v", certType)
+			}
+			cert, err := x509.ParseCertificate(jksReadBytes(t, r))
+			if nil != err {
+				t.Fatalf("Couldn't parse certificate: %s", err)
+			}
+			entry.certs = append(entry.certs, cert)
+		}
+		entries = append(entries, entry)
+	}
+	if 0 != r.Len() {
+		t.Fatalf("%d bytes of trailing data", r.Len())
+	}
+	return entries
+}
+
+func testCertificate(t *testing.T, name string, key interface{}, issuer *x509.Certificate, issuerKey interface{}) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{name},
+	}
+	if nil == issuer {
+		issuer, issuerKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, MustPublicKey(key), issuerKey)
+	if nil != err {
+		t.Fatalf("Couldn't create certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestEncodeJKS(t *testing.T) {
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	for name, key := range testPrivateKeys(t) {
+		caKey, err := CreatePrivateKey(KeyEcdsa, CurveP256, nil)
+		if nil != err {
+			t.Fatal(err)
+		}
+		ca := testCertificate(t, "ca.example.com", caKey, nil, nil)
+		cert := testCertificate(t, "www.example.com", key, ca, caKey)
+
+		data, err := EncodeJKS("WWW.Example.com", key, cert, []*x509.Certificate{ca}, "pässword", timestamp)
+		if nil != err {
+			t.Fatalf("Couldn't encode %s keystore: %s", name, err)
+		}
+		entries := decodeJKS(t, data, "pässword")
+		if 1 != len(entries) {
+			t.Fatalf("Expected a single entry, got %d", len(entries))
+		}
+		entry := entries[0]
+		// java only finds lowercase aliases
+		if "www.example.com" != entry.alias {
+			t.Errorf("Unexpected alias %#v", entry.alias)
+		}
+		if !entry.timestamp.Equal(timestamp.Truncate(time.Millisecond)) {
+			t.Errorf("Unexpected timestamp %v", entry.timestamp)
+		}
+		if !entry.key.(privateKeyEqual).Equal(key) {
+			t.Errorf("Recovered %s key doesn't match", name)
+		}
+		if 2 != len(entry.certs) || !entry.certs[0].Equal(cert) || !entry.certs[1].Equal(ca) {
+			t.Errorf("Unexpected certificate chain in %s keystore", name)
+		}
+	}
+}